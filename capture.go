@@ -0,0 +1,346 @@
+package grazer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// redactedHeaders are replaced by redactedValue in captured requests.
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+const redactedValue = "REDACTED"
+
+// CaptureRecord is a captured request, capture files contain one record per line.
+type CaptureRecord struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	// URI is the request URI with path and query
+	URI        string      `json:"uri"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	RemoteAddr string      `json:"remoteAddr,omitempty"`
+	// Token is the name of the token of the request
+	Token string `json:"token,omitempty"`
+	// Status of the response
+	Status int `json:"status"`
+	// Unknown is true for requests to unknown endpoints
+	Unknown bool `json:"unknown,omitempty"`
+}
+
+// DefaultMaxCaptureFileBytes is the size of a capture file before it is rotated.
+const DefaultMaxCaptureFileBytes = 100 << 20
+
+type CaptureOpts struct {
+	Filename string
+	// MaxBodyBytes limits the captured body of a request (DefaultMaxRequestBodyBytes if 0)
+	MaxBodyBytes int64
+	// MaxFileBytes rotates the capture file to Filename with a ".1" suffix when it would grow larger, replacing an older rotated file (DefaultMaxCaptureFileBytes if 0)
+	MaxFileBytes int64
+	// Unknown also captures requests to unknown endpoints
+	Unknown bool
+	// Unauthenticated also captures requests without a valid token
+	Unauthenticated bool
+}
+
+// Capture appends invalidation requests and optionally unknown requests to a file for replaying them later.
+type Capture struct {
+	filename        string
+	maxBodyBytes    int64
+	maxFileBytes    int64
+	unknown         bool
+	unauthenticated bool
+
+	mx   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func NewCapture(opts CaptureOpts) (*Capture, error) {
+	if opts.MaxBodyBytes == 0 {
+		opts.MaxBodyBytes = DefaultMaxRequestBodyBytes
+	}
+	if opts.MaxFileBytes == 0 {
+		opts.MaxFileBytes = DefaultMaxCaptureFileBytes
+	}
+
+	c := &Capture{
+		filename:        opts.Filename,
+		maxBodyBytes:    opts.MaxBodyBytes,
+		maxFileBytes:    opts.MaxFileBytes,
+		unknown:         opts.Unknown,
+		unauthenticated: opts.Unauthenticated,
+	}
+	if err := c.open(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Capture) open() error {
+	f, err := os.OpenFile(c.filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening capture file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("reading capture file size: %w", err)
+	}
+
+	c.file = f
+	c.size = info.Size()
+	return nil
+}
+
+// rotate replaces the rotated capture file with the current one and opens a new file.
+func (c *Capture) rotate() error {
+	if err := c.file.Close(); err != nil {
+		return fmt.Errorf("closing capture file: %w", err)
+	}
+	c.file = nil
+	if err := os.Rename(c.filename, c.filename+".1"); err != nil {
+		return fmt.Errorf("rotating capture file: %w", err)
+	}
+	return c.open()
+}
+
+// Add appends a record to the capture file, the file is rotated before it would grow larger than the maximum size.
+func (c *Capture) Add(record CaptureRecord) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	data = append(data, '\n')
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if c.file == nil {
+		return
+	}
+	if c.size > 0 && c.size+int64(len(data)) > c.maxFileBytes {
+		if err := c.rotate(); err != nil {
+			log.
+				WithField("component", "capture").
+				WithError(err).
+				Warn("Rotating capture file failed, stopping capture")
+			return
+		}
+	}
+	n, err := c.file.Write(data)
+	c.size += int64(n)
+	if err != nil {
+		log.
+			WithField("component", "capture").
+			WithError(err).
+			Warn("Writing capture record failed")
+	}
+}
+
+func (c *Capture) Close() error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file = nil
+	return err
+}
+
+// ReadCapture reads all records of a capture file, broken lines are skipped.
+func ReadCapture(r io.Reader) ([]CaptureRecord, error) {
+	var records []CaptureRecord
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var record CaptureRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// Skip broken lines (e.g. a partially written last line)
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading capture: %w", err)
+	}
+
+	return records, nil
+}
+
+// statusRecorder keeps the status code of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// captured records the request and response status of the handler if a capture is configured.
+// Requests to unknown endpoints and requests without a valid token are only recorded if enabled for the capture.
+func (h *Handler) captured(unknown bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.capture == nil || (unknown && !h.capture.unknown) {
+			next(w, r)
+			return
+		}
+		token, authenticated := h.lookupToken(r)
+		if !authenticated && !h.capture.unauthenticated {
+			next(w, r)
+			return
+		}
+
+		// Read the captured part of the body and pass the complete body on, so the handler still detects too large bodies
+		body, _ := io.ReadAll(io.LimitReader(r.Body, h.capture.maxBodyBytes))
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+
+		record := CaptureRecord{
+			Time:       time.Now(),
+			Method:     r.Method,
+			URI:        r.URL.RequestURI(),
+			Header:     redactHeader(r.Header),
+			Body:       string(body),
+			RemoteAddr: r.RemoteAddr,
+			Unknown:    unknown,
+		}
+		if authenticated {
+			record.Token = token.Name
+		}
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next(rec, r)
+
+		record.Status = rec.status
+		if record.Status == 0 {
+			record.Status = http.StatusOK
+		}
+		h.capture.Add(record)
+	}
+}
+
+func redactHeader(header http.Header) http.Header {
+	header = header.Clone()
+	for _, name := range redactedHeaders {
+		if values := header.Values(name); len(values) > 0 {
+			header.Set(name, redactedValue)
+		}
+	}
+	return header
+}
+
+type ReplayOpts struct {
+	// BaseURL of the grazer instance receiving the requests
+	BaseURL string
+	// Token is sent as bearer token instead of the redacted authorization
+	Token string
+	// Speed scales the delays between the captured requests (e.g. 2 replays twice as fast), requests are sent without delays if 0
+	Speed float64
+	// Unknown also replays requests to unknown endpoints
+	Unknown bool
+
+	Transport http.RoundTripper
+}
+
+// ReplayResult is the result of a replayed request.
+type ReplayResult struct {
+	Record CaptureRecord
+	// Status of the response to the replayed request
+	Status int
+	Err    error
+}
+
+// Replay sends the captured requests in order to another grazer instance, keeping the delays between requests.
+// The result of each request is passed to fn.
+func Replay(ctx context.Context, records []CaptureRecord, opts ReplayOpts, fn func(ReplayResult)) error {
+	client := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: opts.Transport,
+	}
+	baseURL := strings.TrimSuffix(opts.BaseURL, "/")
+
+	var previous time.Time
+	for _, record := range records {
+		if record.Unknown && !opts.Unknown {
+			continue
+		}
+
+		if opts.Speed > 0 && !previous.IsZero() {
+			if delay := time.Duration(float64(record.Time.Sub(previous)) / opts.Speed); delay > 0 {
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+		previous = record.Time
+
+		status, err := replayRequest(ctx, client, baseURL, opts.Token, record)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		fn(ReplayResult{
+			Record: record,
+			Status: status,
+			Err:    err,
+		})
+	}
+
+	return nil
+}
+
+func replayRequest(ctx context.Context, client *http.Client, baseURL, token string, record CaptureRecord) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, record.Method, baseURL+record.URI, strings.NewReader(record.Body))
+	if err != nil {
+		return 0, fmt.Errorf("building request: %w", err)
+	}
+	for name, values := range record.Header {
+		switch http.CanonicalHeaderKey(name) {
+		case "Authorization", "Proxy-Authorization", "Cookie", "Content-Length", "Connection", "Host":
+			continue
+		}
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	if token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("sending request: %w", err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
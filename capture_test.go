@@ -0,0 +1,147 @@
+package grazer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func TestHandler_capture(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "capture.ndjson")
+	capture, err := NewCapture(CaptureOpts{Filename: filename, Unknown: true})
+	require.NoError(t, err)
+
+	h := NewHandler(HandlerOpts{
+		RevalidateToken: "a-token",
+		Revalidator:     NewRevalidator(RevalidatorOpts{URL: "http://localhost", DryRun: true}),
+		DocumentSource:  staticDocuments{{RoutePath: "/"}},
+		Capture:         capture,
+	})
+	defer h.ShutdownAndWait()
+
+	send := func(method, target, body string) {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer a-token")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	// Unauthenticated requests are not captured
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/revalidate", strings.NewReader(`{"documents":[{"routePath":"/"}]}`)))
+	send(http.MethodPost, "/api/revalidate", `{"documents":[{"routePath":"/"}],"skipFullRevalidate":true}`)
+	send(http.MethodPost, "/api/revalidate", `{"documents":[{"routePath":"invalid"}]}`)
+	send(http.MethodGet, "/neos/webhook?x=1", "")
+	// Other endpoints are not captured
+	send(http.MethodGet, "/api/status", "")
+	require.NoError(t, capture.Close())
+
+	f, err := os.Open(filename)
+	require.NoError(t, err)
+	defer f.Close()
+	records, err := ReadCapture(f)
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+
+	assert.Equal(t, "/api/revalidate", records[0].URI)
+	assert.Equal(t, `{"documents":[{"routePath":"/"}],"skipFullRevalidate":true}`, records[0].Body)
+	assert.Equal(t, http.StatusOK, records[0].Status)
+	assert.Equal(t, "revalidate-token", records[0].Token)
+	assert.Equal(t, redactedValue, records[0].Header.Get("Authorization"))
+	assert.False(t, records[0].Unknown)
+
+	assert.Equal(t, http.StatusBadRequest, records[1].Status)
+
+	assert.Equal(t, "/neos/webhook?x=1", records[2].URI)
+	assert.True(t, records[2].Unknown)
+
+	// Replay against another instance
+	var received []string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer other-token", r.Header.Get("Authorization"))
+		received = append(received, r.URL.RequestURI())
+	}))
+	defer target.Close()
+
+	var results []ReplayResult
+	err = Replay(context.Background(), records, ReplayOpts{BaseURL: target.URL, Token: "other-token"}, func(result ReplayResult) {
+		results = append(results, result)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/api/revalidate", "/api/revalidate"}, received)
+	require.Len(t, results, 2)
+	assert.Equal(t, http.StatusOK, results[0].Status)
+	assert.NoError(t, results[0].Err)
+}
+
+func TestHandler_captureDefaults(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "capture.ndjson")
+	capture, err := NewCapture(CaptureOpts{Filename: filename})
+	require.NoError(t, err)
+
+	h := NewHandler(HandlerOpts{
+		RevalidateToken: "a-token",
+		Revalidator:     NewRevalidator(RevalidatorOpts{URL: "http://localhost", DryRun: true}),
+		DocumentSource:  staticDocuments{{RoutePath: "/"}},
+		Capture:         capture,
+	})
+	defer h.ShutdownAndWait()
+
+	// Requests to unknown endpoints are not captured by default
+	req := httptest.NewRequest(http.MethodGet, "/neos/webhook", nil)
+	req.Header.Set("Authorization", "Bearer a-token")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/wp-login.php", nil))
+	require.NoError(t, capture.Close())
+
+	data, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Empty(t, data)
+}
+
+func TestCapture_rotate(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "capture.ndjson")
+	capture, err := NewCapture(CaptureOpts{Filename: filename, MaxFileBytes: 200})
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		capture.Add(CaptureRecord{Method: http.MethodPost, URI: fmt.Sprintf("/api/revalidate?i=%d", i), Status: http.StatusOK})
+	}
+	require.NoError(t, capture.Close())
+
+	readURIs := func(filename string) []string {
+		f, err := os.Open(filename)
+		require.NoError(t, err)
+		defer f.Close()
+		records, err := ReadCapture(f)
+		require.NoError(t, err)
+		var uris []string
+		for _, record := range records {
+			uris = append(uris, record.URI)
+		}
+		return uris
+	}
+
+	// Each record has about 90 bytes, so a file keeps 2 records and older files are replaced
+	for _, name := range []string{filename, filename + ".1"} {
+		info, err := os.Stat(name)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, info.Size(), int64(200))
+	}
+	assert.Equal(t, []string{"/api/revalidate?i=4"}, readURIs(filename))
+	assert.Equal(t, []string{"/api/revalidate?i=2", "/api/revalidate?i=3"}, readURIs(filename+".1"))
+
+	// The size of an existing file counts when appending
+	capture, err = NewCapture(CaptureOpts{Filename: filename, MaxFileBytes: 200})
+	require.NoError(t, err)
+	capture.Add(CaptureRecord{Method: http.MethodPost, URI: "/api/revalidate?i=5", Status: http.StatusOK})
+	capture.Add(CaptureRecord{Method: http.MethodPost, URI: "/api/revalidate?i=6", Status: http.StatusOK})
+	require.NoError(t, capture.Close())
+	assert.Equal(t, []string{"/api/revalidate?i=6"}, readURIs(filename))
+	assert.Equal(t, []string{"/api/revalidate?i=4", "/api/revalidate?i=5"}, readURIs(filename+".1"))
+}
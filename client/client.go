@@ -0,0 +1,350 @@
+// Package client is a Go client for the grazer API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+
+	"github.com/networkteam/grazer"
+)
+
+// DefaultMaxRetries is the number of retries if no other value is set.
+const DefaultMaxRetries = 3
+
+type Opts struct {
+	// BaseURL of the grazer instance (e.g. "http://grazer:3100")
+	BaseURL string
+	// Token is sent as bearer token, it needs the scopes of the called endpoints
+	Token string
+	// Timeout of a single request (30 seconds if 0), it is extended by the wait timeout for RevalidateAndWait
+	Timeout time.Duration
+	// MaxRetries of requests that failed with a temporary error (DefaultMaxRetries if 0)
+	MaxRetries int
+	// DisableRetries disables retries of failed requests
+	DisableRetries bool
+
+	Transport http.RoundTripper
+}
+
+// Client calls the grazer API.
+// Failed requests are retried with exponential backoff if the error is temporary.
+// POST requests are only retried if they were not sent or rejected before processing (429 or 503), since an invalidation creates a new job each time.
+type Client struct {
+	baseURL    string
+	token      string
+	timeout    time.Duration
+	maxRetries int
+	client     *http.Client
+}
+
+func New(opts Opts) *Client {
+	if opts.Timeout == 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = DefaultMaxRetries
+	}
+	if opts.DisableRetries {
+		opts.MaxRetries = 0
+	}
+
+	return &Client{
+		baseURL:    strings.TrimSuffix(opts.BaseURL, "/"),
+		token:      opts.Token,
+		timeout:    opts.Timeout,
+		maxRetries: opts.MaxRetries,
+		client: &http.Client{
+			Transport: opts.Transport,
+		},
+	}
+}
+
+// Document is an invalidated document, exactly one of RoutePath or RoutePathPrefix must be set.
+type Document struct {
+	// RoutePath of the document, a glob (e.g. "/blog/*" or "/blog/**") invalidates all matching documents
+	RoutePath string `json:"routePath,omitempty"`
+	// RoutePathPrefix invalidates all documents with the prefix as route path or as its leading segments
+	RoutePathPrefix string `json:"routePathPrefix,omitempty"`
+}
+
+// RevalidateRequest invalidates documents.
+type RevalidateRequest struct {
+	Documents []Document `json:"documents"`
+	// Priority of the invalidation (normal if empty)
+	Priority grazer.Priority `json:"priority,omitempty"`
+	// SkipFullRevalidate only enqueues the documents of the invalidation without all other documents
+	SkipFullRevalidate bool `json:"skipFullRevalidate,omitempty"`
+	// NotBefore delays the invalidation until the time
+	NotBefore *time.Time `json:"notBefore,omitempty"`
+}
+
+// ExpandedPattern reports how many route paths a pattern of an invalidation was expanded to.
+type ExpandedPattern struct {
+	Pattern string `json:"pattern"`
+	Count   int    `json:"count"`
+}
+
+// RevalidateResponse is the result of an invalidation.
+type RevalidateResponse struct {
+	JobID    string            `json:"jobId"`
+	Expanded []ExpandedPattern `json:"expanded,omitempty"`
+	// Job is the status of the job for RevalidateAndWait
+	Job *grazer.JobStatus `json:"job,omitempty"`
+}
+
+// Revalidate invalidates documents, the job can be queried with Job. It needs the invalidate scope.
+func (c *Client) Revalidate(ctx context.Context, req RevalidateRequest) (*RevalidateResponse, error) {
+	var resp RevalidateResponse
+	_, err := c.do(ctx, http.MethodPost, "/api/revalidate", nil, req, &resp, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// RevalidateAndWait invalidates documents and waits until the job is finished or the timeout is reached (at most 5 minutes).
+// The job of the response is still running if finished is false. It needs the invalidate scope.
+func (c *Client) RevalidateAndWait(ctx context.Context, req RevalidateRequest, timeout time.Duration) (resp *RevalidateResponse, finished bool, err error) {
+	query := url.Values{
+		"wait":    {"true"},
+		"timeout": {timeout.String()},
+	}
+
+	resp = new(RevalidateResponse)
+	statusCode, err := c.do(ctx, http.MethodPost, "/api/revalidate", query, req, resp, c.timeout+timeout)
+	if err != nil {
+		return nil, false, err
+	}
+	return resp, statusCode == http.StatusOK, nil
+}
+
+// FullRevalidate lists and enqueues all documents. It needs the full-revalidate or admin:write scope.
+func (c *Client) FullRevalidate(ctx context.Context) error {
+	_, err := c.do(ctx, http.MethodPost, "/api/full-revalidate", nil, nil, nil, c.timeout)
+	return err
+}
+
+// Job returns the status of a job. It needs the invalidate or admin:read scope.
+// An error matching ErrNotFound is returned if the job does not exist (anymore).
+func (c *Client) Job(ctx context.Context, id string) (*grazer.JobStatus, error) {
+	var status grazer.JobStatus
+	_, err := c.do(ctx, http.MethodGet, "/api/jobs/"+url.PathEscape(id), nil, nil, &status, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// DocumentsDiff returns the last detected document changes or nil if no changes were detected yet.
+// It needs the invalidate or admin:read scope.
+func (c *Client) DocumentsDiff(ctx context.Context) (*grazer.DocumentsDiff, error) {
+	var diff grazer.DocumentsDiff
+	statusCode, err := c.do(ctx, http.MethodGet, "/api/documents/diff", nil, nil, &diff, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	if statusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	return &diff, nil
+}
+
+// Status returns the status of the queue, revalidations and upstreams. It needs the admin:read scope.
+func (c *Client) Status(ctx context.Context) (*grazer.Status, error) {
+	var status grazer.Status
+	_, err := c.do(ctx, http.MethodGet, "/api/status", nil, nil, &status, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// History returns matching history records, newest first. It needs the admin:read scope.
+func (c *Client) History(ctx context.Context, q grazer.HistoryQuery) ([]grazer.HistoryRecord, error) {
+	query := url.Values{}
+	if q.RoutePath != "" {
+		query.Set("routePath", q.RoutePath)
+	}
+	if !q.Since.IsZero() {
+		query.Set("since", q.Since.Format(time.RFC3339))
+	}
+	if !q.Until.IsZero() {
+		query.Set("until", q.Until.Format(time.RFC3339))
+	}
+	if q.Type != "" {
+		query.Set("type", string(q.Type))
+	}
+	if q.Outcome != "" {
+		query.Set("outcome", q.Outcome)
+	}
+	if q.Limit != 0 {
+		query.Set("limit", strconv.Itoa(q.Limit))
+	}
+
+	var records []grazer.HistoryRecord
+	_, err := c.do(ctx, http.MethodGet, "/api/history", query, nil, &records, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// PauseQueue stops dispatching revalidations. It needs the admin:write scope.
+func (c *Client) PauseQueue(ctx context.Context) (*grazer.QueueControlResponse, error) {
+	return c.queueControl(ctx, "pause")
+}
+
+// ResumeQueue continues dispatching revalidations. It needs the admin:write scope.
+func (c *Client) ResumeQueue(ctx context.Context) (*grazer.QueueControlResponse, error) {
+	return c.queueControl(ctx, "resume")
+}
+
+// DrainSweep drops route paths of full revalidations from the queue. It needs the admin:write scope.
+func (c *Client) DrainSweep(ctx context.Context) (*grazer.QueueControlResponse, error) {
+	return c.queueControl(ctx, "drain")
+}
+
+// CancelQueue drops all route paths from the queue. It needs the admin:write scope.
+func (c *Client) CancelQueue(ctx context.Context) (*grazer.QueueControlResponse, error) {
+	return c.queueControl(ctx, "cancel")
+}
+
+func (c *Client) queueControl(ctx context.Context, action string) (*grazer.QueueControlResponse, error) {
+	var resp grazer.QueueControlResponse
+	_, err := c.do(ctx, http.MethodPost, "/api/queue/"+action, nil, nil, &resp, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// do sends a request with retries and decodes a JSON response into result (if not nil).
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body any, result any, timeout time.Duration) (int, error) {
+	var bodyBytes []byte
+	if body != nil {
+		var err error
+		bodyBytes, err = json.Marshal(body)
+		if err != nil {
+			return 0, fmt.Errorf("encoding request body: %w", err)
+		}
+	}
+
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var b backoff.BackOff = backoff.NewExponentialBackOff()
+	b = backoff.WithMaxRetries(b, uint64(c.maxRetries))
+	b = backoff.WithContext(b, ctx)
+
+	return backoff.RetryWithData(func() (int, error) {
+		statusCode, err := c.doOnce(ctx, method, u, bodyBytes, result, timeout)
+		if err != nil && !isTemporary(err, method) {
+			return statusCode, backoff.Permanent(err)
+		}
+		return statusCode, err
+	}, b)
+}
+
+func (c *Client) doOnce(ctx context.Context, method, u string, bodyBytes []byte, result any, timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var body io.Reader
+	if bodyBytes != nil {
+		body = bytes.NewReader(bodyBytes)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return 0, fmt.Errorf("building request: %w", err)
+	}
+	if bodyBytes != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
+
+	// The trace tells if a failed request reached the server
+	var sent atomic.Bool
+	req = req.WithContext(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			sent.Store(true)
+		},
+	}))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, &sendError{err: err, sent: sent.Load()}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode, newAPIError(resp)
+	}
+
+	if result != nil && resp.StatusCode != http.StatusNoContent && resp.ContentLength != 0 {
+		err = json.NewDecoder(resp.Body).Decode(result)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return resp.StatusCode, fmt.Errorf("decoding response: %w", err)
+		}
+	}
+
+	return resp.StatusCode, nil
+}
+
+// sendError is a failed request without a response.
+type sendError struct {
+	err error
+	// sent is true if the request was written, so the server could have processed it
+	sent bool
+}
+
+func (e *sendError) Error() string {
+	return fmt.Sprintf("sending request: %v", e.err)
+}
+
+func (e *sendError) Unwrap() error {
+	return e.err
+}
+
+// isTemporary returns true if a failed request can be retried.
+// GET requests are retried after network errors, 429 and 5xx responses.
+// Other requests are only retried if they were not sent or the server rejected them before processing (429 or 503).
+func isTemporary(err error, method string) bool {
+	idempotent := method == http.MethodGet || method == http.MethodHead
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if idempotent {
+			return apiErr.Temporary()
+		}
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode == http.StatusServiceUnavailable
+	}
+
+	var sendErr *sendError
+	if errors.As(err, &sendErr) {
+		// A canceled context is not temporary
+		if errors.Is(err, context.Canceled) {
+			return false
+		}
+		return idempotent || !sendErr.sent
+	}
+
+	// Errors building the request or decoding the response are not temporary
+	return false
+}
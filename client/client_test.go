@@ -0,0 +1,197 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+
+	"github.com/networkteam/grazer"
+)
+
+type staticDocuments []grazer.DocumentsItem
+
+func (d staticDocuments) ListDocuments(_ context.Context) (*grazer.DocumentsResponse, error) {
+	return &grazer.DocumentsResponse{Documents: d}, nil
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	next := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(next.Close)
+
+	h := grazer.NewHandler(grazer.HandlerOpts{
+		RevalidateToken: "revalidate-token",
+		AdminToken:      "admin-token",
+		Revalidator:     grazer.NewRevalidator(grazer.RevalidatorOpts{URL: next.URL}),
+		DocumentSource:  staticDocuments{{RoutePath: "/"}, {RoutePath: "/blog/post-1"}, {RoutePath: "/blog/post-2"}},
+	})
+	t.Cleanup(h.ShutdownAndWait)
+
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestClient_Revalidate(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+
+	c := New(Opts{BaseURL: srv.URL, Token: "revalidate-token"})
+
+	resp, finished, err := c.RevalidateAndWait(ctx, RevalidateRequest{
+		Documents: []Document{{RoutePath: "/blog/*"}},
+		Priority:  grazer.PriorityUrgent,
+	}, 10*time.Second)
+	require.NoError(t, err)
+	assert.True(t, finished)
+	assert.Equal(t, []ExpandedPattern{{Pattern: "/blog/*", Count: 2}}, resp.Expanded)
+	require.NotNil(t, resp.Job)
+	assert.Equal(t, grazer.JobStateDone, resp.Job.State)
+
+	job, err := c.Job(ctx, resp.JobID)
+	require.NoError(t, err)
+	assert.Equal(t, resp.JobID, job.ID)
+
+	_, err = c.Job(ctx, "unknown")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	// Invalid documents are returned as typed errors and not retried
+	_, err = c.Revalidate(ctx, RevalidateRequest{Documents: []Document{{RoutePath: "blog"}}})
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, grazer.ErrorCodeInvalidDocuments, apiErr.Code)
+	assert.Len(t, apiErr.Documents, 1)
+
+	// The revalidate token has no admin scopes
+	_, err = c.PauseQueue(ctx)
+	assert.True(t, errors.Is(err, ErrForbidden))
+
+	_, err = New(Opts{BaseURL: srv.URL, Token: "wrong"}).Revalidate(ctx, RevalidateRequest{Documents: []Document{{RoutePath: "/"}}})
+	assert.True(t, errors.Is(err, ErrForbidden))
+}
+
+func TestClient_admin(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+
+	c := New(Opts{BaseURL: srv.URL, Token: "admin-token"})
+
+	resp, err := c.PauseQueue(ctx)
+	require.NoError(t, err)
+	assert.True(t, resp.Paused)
+
+	require.NoError(t, c.FullRevalidate(ctx))
+
+	status, err := c.Status(ctx)
+	require.NoError(t, err)
+	assert.True(t, status.Queue.Paused)
+	assert.Equal(t, 3, status.Queue.Length)
+
+	resp, err = c.CancelQueue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, resp.Dropped)
+
+	resp, err = c.ResumeQueue(ctx)
+	require.NoError(t, err)
+	assert.False(t, resp.Paused)
+
+	records, err := c.History(ctx, grazer.HistoryQuery{Type: grazer.HistoryFullRevalidation})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, grazer.SourceAPI, records[0].Source)
+
+	_, err = New(Opts{BaseURL: srv.URL, Token: "wrong"}).Status(ctx)
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+}
+
+func TestClient_retries(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	c := New(Opts{BaseURL: srv.URL})
+	require.NoError(t, c.FullRevalidate(context.Background()))
+	assert.Equal(t, int32(3), requests.Load())
+
+	requests.Store(0)
+	err := New(Opts{BaseURL: srv.URL, DisableRetries: true}).FullRevalidate(context.Background())
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestClient_retriesAfterProcessing(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/api/status", "/api/revalidate":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			// The connection is closed after the request was received
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			_ = conn.Close()
+		}
+	}))
+	defer srv.Close()
+
+	c := New(Opts{BaseURL: srv.URL, MaxRetries: 2})
+	ctx := context.Background()
+
+	// A GET request is retried after a server error
+	_, err := c.Status(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, int32(3), requests.Load())
+
+	// An invalidation could have been processed, so it is not retried
+	requests.Store(0)
+	_, err = c.Revalidate(ctx, RevalidateRequest{Documents: []Document{{RoutePath: "/"}}})
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), requests.Load())
+
+	requests.Store(0)
+	err = c.FullRevalidate(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), requests.Load())
+
+	requests.Store(0)
+	_, err = c.Job(ctx, "a-job")
+	assert.Error(t, err)
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func Test_isTemporary(t *testing.T) {
+	notSent := &sendError{err: errors.New("connection refused")}
+	sent := &sendError{err: errors.New("connection reset"), sent: true}
+
+	assert.True(t, isTemporary(notSent, http.MethodPost))
+	assert.False(t, isTemporary(sent, http.MethodPost))
+	assert.True(t, isTemporary(sent, http.MethodGet))
+	assert.False(t, isTemporary(&sendError{err: context.Canceled}, http.MethodGet))
+
+	assert.True(t, isTemporary(&APIError{StatusCode: http.StatusTooManyRequests}, http.MethodPost))
+	assert.True(t, isTemporary(&APIError{StatusCode: http.StatusServiceUnavailable}, http.MethodPost))
+	assert.False(t, isTemporary(&APIError{StatusCode: http.StatusBadGateway}, http.MethodPost))
+	assert.True(t, isTemporary(&APIError{StatusCode: http.StatusBadGateway}, http.MethodGet))
+	assert.False(t, isTemporary(&APIError{StatusCode: http.StatusBadRequest}, http.MethodGet))
+
+	assert.False(t, isTemporary(errors.New("decoding response: unexpected EOF"), http.MethodGet))
+}
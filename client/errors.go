@@ -0,0 +1,75 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/networkteam/grazer"
+)
+
+// Errors to match an APIError by status code with errors.Is.
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+)
+
+// APIError is returned for responses with an error status code.
+type APIError struct {
+	StatusCode int
+	// Code is one of the grazer.ErrorCode constants if the response had a JSON error body
+	Code    string
+	Message string
+	// Documents are the errors of invalid documents of an invalidation
+	Documents []grazer.DocumentError
+}
+
+func newAPIError(resp *http.Response) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var errResp grazer.ErrorResponse
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") && json.Unmarshal(body, &errResp) == nil {
+		apiErr.Code = errResp.Code
+		apiErr.Message = errResp.Message
+		apiErr.Documents = errResp.Documents
+	} else {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("grazer API error %d (%s): %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("grazer API error %d: %s", e.StatusCode, e.Message)
+}
+
+// Is matches ErrUnauthorized, ErrForbidden and ErrNotFound by status code.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	}
+	return false
+}
+
+// Temporary returns true if the request can be retried (rate limited, server errors or a failed listing of documents).
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/apex/log"
+	"github.com/robfig/cron/v3"
+	"github.com/urfave/cli/v2"
+
+	"github.com/networkteam/grazer"
+)
+
+// settings can be changed by reloading the config file.
+type settings struct {
+	revalidators []*grazer.Revalidator
+	pathRules    grazer.PathRules
+	schedules    []grazer.Schedule
+	// tokens of the config file
+	tokens []grazer.Token
+}
+
+// loadSettings combines the flags with the config file (if set) and validates the result.
+func loadSettings(c *cli.Context) (settings, error) {
+	var config grazer.Config
+	if filename := c.String("config"); filename != "" {
+		var err error
+		config, err = grazer.LoadConfig(filename)
+		if err != nil {
+			return settings{}, err
+		}
+	}
+
+	if len(config.Tokens) > 0 && c.String("tokens-file") != "" {
+		return settings{}, errors.New("tokens can be configured either in the config file or with tokens-file")
+	}
+
+	revalidators, err := createRevalidators(c, config)
+	if err != nil {
+		return settings{}, err
+	}
+
+	pathRules, err := createPathRules(c)
+	if err != nil {
+		return settings{}, err
+	}
+	configPathRules, err := config.PathRules()
+	if err != nil {
+		return settings{}, err
+	}
+	pathRules = append(pathRules, configPathRules...)
+
+	schedules, err := createSchedules(c, config, revalidators)
+	if err != nil {
+		return settings{}, err
+	}
+
+	tokens, err := config.LoadTokens()
+	if err != nil {
+		return settings{}, err
+	}
+
+	return settings{
+		revalidators: revalidators,
+		pathRules:    pathRules,
+		schedules:    schedules,
+		tokens:       tokens,
+	}, nil
+}
+
+// createSchedules parses the schedule flags and adds the schedules of the config file.
+func createSchedules(c *cli.Context, config grazer.Config, revalidators []*grazer.Revalidator) ([]grazer.Schedule, error) {
+	var schedules []grazer.Schedule
+	for _, value := range repeatedValues(c, "revalidate-schedule") {
+		schedule, err := grazer.ParseSchedule(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid revalidate schedule %q: %w", value, err)
+		}
+		schedules = append(schedules, schedule)
+	}
+	schedules = append(schedules, config.Schedules...)
+
+	for _, schedule := range schedules {
+		if _, err := cron.ParseStandard(schedule.Spec); err != nil {
+			return nil, fmt.Errorf("schedule %s: invalid spec: %w", schedule.DisplayName(), err)
+		}
+		for _, target := range schedule.Targets {
+			if !hasRevalidator(revalidators, target) {
+				return nil, fmt.Errorf("schedule %s: unknown target %q", schedule.DisplayName(), target)
+			}
+		}
+	}
+
+	return schedules, nil
+}
+
+func hasRevalidator(revalidators []*grazer.Revalidator, name string) bool {
+	for _, revalidator := range revalidators {
+		if revalidator.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// reloadOnSignal reloads the settings on SIGHUP until the context is done.
+// The current settings are kept if the new settings are invalid.
+func reloadOnSignal(ctx context.Context, c *cli.Context, h *grazer.Handler, tokenSet *grazer.TokenSet, sched *scheduler) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		}
+
+		err := reload(c, h, tokenSet, sched)
+		if err != nil {
+			log.
+				WithField("component", "config").
+				WithError(err).
+				Error("Reloading config failed, keeping current config")
+			continue
+		}
+	}
+}
+
+func reload(c *cli.Context, h *grazer.Handler, tokenSet *grazer.TokenSet, sched *scheduler) error {
+	s, err := loadSettings(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.Reconfigure(grazer.ReconfigureOpts{
+		Revalidators: s.revalidators,
+		PathRules:    s.pathRules,
+	}); err != nil {
+		return err
+	}
+	if err := sched.setSchedules(s.schedules); err != nil {
+		return err
+	}
+	if c.String("tokens-file") == "" {
+		tokenSet.Replace(s.tokens)
+	}
+
+	targetNames := make([]string, len(s.revalidators))
+	for i, revalidator := range s.revalidators {
+		targetNames[i] = revalidator.Name()
+	}
+	log.
+		WithField("component", "config").
+		WithField("targets", strings.Join(targetNames, ",")).
+		WithField("schedules", len(s.schedules)).
+		WithField("pathRules", len(s.pathRules)).
+		WithField("tokens", len(s.tokens)).
+		Info("Reloaded config")
+
+	return nil
+}
+
+func configCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "config",
+		Usage: "Work with the configuration file",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "check",
+				Usage: "Validate the configuration of flags and the config file without starting grazer",
+				Action: func(c *cli.Context) error {
+					s, err := loadSettings(c)
+					if err != nil {
+						return err
+					}
+
+					fmt.Fprintf(c.App.Writer, "Configuration is valid: %d targets, %d schedules, %d path rules, %d tokens\n",
+						len(s.revalidators), len(s.schedules), len(s.pathRules), len(s.tokens))
+					return nil
+				},
+			},
+		},
+	}
+}
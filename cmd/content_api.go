@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/networkteam/grazer"
+)
+
+func createContentAPITransport(c *cli.Context) (http.RoundTripper, error) {
+	password, err := secretValue(c, "neos-basic-auth-password")
+	if err != nil {
+		return nil, err
+	}
+	bearerToken, err := secretValue(c, "neos-bearer-token")
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make(http.Header)
+	for _, header := range c.StringSlice("neos-header") {
+		if err := addHeaderLine(headers, header); err != nil {
+			return nil, fmt.Errorf("invalid neos-header: %w", err)
+		}
+	}
+	if headersFile := c.String("neos-headers-file"); headersFile != "" {
+		content, err := os.ReadFile(headersFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading neos-headers-file: %w", err)
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if err := addHeaderLine(headers, line); err != nil {
+				return nil, fmt.Errorf("invalid line in neos-headers-file: %w", err)
+			}
+		}
+	}
+
+	transport, err := grazer.NewContentAPITransport(grazer.ContentAPIAuth{
+		BasicAuthUsername: c.String("neos-basic-auth-username"),
+		BasicAuthPassword: password,
+		BearerToken:       bearerToken,
+		Headers:           headers,
+		ClientCertFile:    c.String("neos-client-cert"),
+		ClientKeyFile:     c.String("neos-client-key"),
+		CAFile:            c.String("neos-ca-cert"),
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("configuring content API authentication: %w", err)
+	}
+	return transport, nil
+}
+
+// secretValue returns the value of the flag with the given name or reads it from the file given by the flag name with a "-file" suffix.
+func secretValue(c *cli.Context, name string) (string, error) {
+	value := c.String(name)
+	filename := c.String(name + "-file")
+	if filename == "" {
+		return value, nil
+	}
+	if value != "" {
+		return "", fmt.Errorf("only one of %s and %s-file can be set", name, name)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("reading %s-file: %w", name, err)
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+func addHeaderLine(headers http.Header, line string) error {
+	name, value, ok := strings.Cut(line, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("expected \"Name: value\", got %q", line)
+	}
+	headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	return nil
+}
@@ -3,53 +3,150 @@ package main
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"sync"
 
 	"github.com/apex/log"
 	"github.com/cenkalti/backoff/v4"
 	"github.com/robfig/cron/v3"
-	"github.com/urfave/cli/v2"
 
 	"github.com/networkteam/grazer"
 )
 
-func createCron(c *cli.Context, h *grazer.Handler) (*cron.Cron, error) {
+// scheduler runs revalidation schedules with a cron, the schedules can be replaced at runtime.
+type scheduler struct {
+	h  *grazer.Handler
+	cr *cron.Cron
+
+	mx      sync.Mutex
+	entries []scheduleEntry
+}
+
+type scheduleEntry struct {
+	id       cron.EntryID
+	schedule grazer.Schedule
+}
+
+func createScheduler(h *grazer.Handler, schedules []grazer.Schedule) (*scheduler, error) {
 	logger := cronLogger{log.Log}
-	cr := cron.New(cron.WithChain(
-		cron.Recover(logger),
-		cron.SkipIfStillRunning(logger),
-	))
-
-	if revalidateSchedules := c.StringSlice("revalidate-schedule"); len(revalidateSchedules) > 0 {
-		for _, schedule := range revalidateSchedules {
-			_, err := cr.AddFunc(schedule, func() {
-				ctx := context.Background()
-				err := backoff.Retry(func() error {
-					log.
-						WithField("component", "controller").
-						Debug("Performing scheduled revalidate")
-
-					err := h.FullRevalidate(ctx)
-					if err != nil {
-						log.
-							WithError(err).
-							Warn("Scheduled revalidation failed, retrying...")
-					}
-					return err
-				}, backoff.NewExponentialBackOff())
-				if err != nil {
-					log.
-						WithError(err).
-						Error("Scheduled revalidation failed")
-				}
-			})
-			if err != nil {
-				return nil, fmt.Errorf("invalid revalidate schedule: %w", err)
-			}
+	s := &scheduler{
+		h: h,
+		cr: cron.New(cron.WithChain(
+			cron.Recover(logger),
+			cron.SkipIfStillRunning(logger),
+		)),
+	}
+
+	if err := s.setSchedules(schedules); err != nil {
+		return nil, err
+	}
+	s.cr.Start()
+
+	h.SetSchedules(s.status)
+
+	return s, nil
+}
+
+// setSchedules replaces the schedules, unchanged schedules keep their cron entry and next run.
+// All specs are parsed before the cron is changed, so the current schedules are kept if any spec is invalid.
+func (s *scheduler) setSchedules(schedules []grazer.Schedule) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	existing := make(map[int]bool)
+	entries := make([]scheduleEntry, len(schedules))
+	// specs of new schedules, nil for unchanged schedules
+	specs := make([]cron.Schedule, len(schedules))
+	for i, schedule := range schedules {
+		if j, ok := s.findEntry(schedule, existing); ok {
+			existing[j] = true
+			entries[i] = s.entries[j]
+			continue
+		}
+
+		spec, err := cron.ParseStandard(schedule.Spec)
+		if err != nil {
+			return fmt.Errorf("invalid revalidate schedule %s: %w", schedule.DisplayName(), err)
+		}
+		specs[i] = spec
+	}
+
+	// Adding parsed schedules cannot fail, old entries are removed after all new entries were added
+	for i, spec := range specs {
+		if spec == nil {
+			continue
 		}
+		schedule := schedules[i]
+		id := s.cr.Schedule(spec, cron.FuncJob(func() {
+			s.run(schedule)
+		}))
+		entries[i] = scheduleEntry{id: id, schedule: schedule}
 	}
-	cr.Start()
+	for i, entry := range s.entries {
+		if !existing[i] {
+			s.cr.Remove(entry.id)
+		}
+	}
+	s.entries = entries
+
+	return nil
+}
+
+// findEntry returns the index of an unused entry with an equal schedule.
+func (s *scheduler) findEntry(schedule grazer.Schedule, used map[int]bool) (int, bool) {
+	for i, entry := range s.entries {
+		if !used[i] && reflect.DeepEqual(entry.schedule, schedule) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (s *scheduler) run(schedule grazer.Schedule) {
+	ctx := context.Background()
+	err := backoff.Retry(func() error {
+		log.
+			WithField("component", "controller").
+			WithField("schedule", schedule.DisplayName()).
+			Debug("Performing scheduled revalidate")
+
+		err := s.h.RevalidateSchedule(ctx, schedule)
+		if err != nil {
+			log.
+				WithError(err).
+				Warn("Scheduled revalidation failed, retrying...")
+		}
+		return err
+	}, backoff.NewExponentialBackOff())
+	if err != nil {
+		log.
+			WithError(err).
+			Error("Scheduled revalidation failed")
+	}
+}
+
+func (s *scheduler) status() []grazer.ScheduleStatus {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	result := make([]grazer.ScheduleStatus, len(s.entries))
+	for i, entry := range s.entries {
+		cronEntry := s.cr.Entry(entry.id)
+		result[i] = grazer.ScheduleStatus{
+			Name:     entry.schedule.DisplayName(),
+			Spec:     entry.schedule.Spec,
+			Paths:    entry.schedule.Paths,
+			Targets:  entry.schedule.Targets,
+			Priority: entry.schedule.Priority,
+			Next:     cronEntry.Next,
+			Prev:     cronEntry.Prev,
+		}
+	}
+	return result
+}
 
-	return cr, nil
+func (s *scheduler) stop() {
+	s.cr.Stop()
 }
 
 type cronLogger struct{ log log.Interface }
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+
+	"github.com/networkteam/grazer"
+)
+
+func Test_scheduler_setSchedules(t *testing.T) {
+	h := grazer.NewHandler(grazer.HandlerOpts{
+		Revalidator: grazer.NewRevalidator(grazer.RevalidatorOpts{URL: "http://localhost:0", DryRun: true}),
+	})
+	defer h.ShutdownAndWait()
+
+	hourly := grazer.Schedule{Spec: "@hourly"}
+	daily := grazer.Schedule{Spec: "@daily", Name: "nightly"}
+	s, err := createScheduler(h, []grazer.Schedule{hourly, daily})
+	require.NoError(t, err)
+	defer s.stop()
+	hourlyID := s.entries[0].id
+
+	// An invalid spec keeps the current schedules
+	err = s.setSchedules([]grazer.Schedule{hourly, {Spec: "@weekly"}, {Spec: "invalid", Name: "broken"}})
+	require.EqualError(t, err, `invalid revalidate schedule broken: expected exactly 5 fields, found 1: [invalid]`)
+	assert.Len(t, s.cr.Entries(), 2)
+	assert.Equal(t, []grazer.Schedule{hourly, daily}, scheduleList(s))
+
+	// Unchanged schedules keep their entry
+	require.NoError(t, s.setSchedules([]grazer.Schedule{{Spec: "@weekly"}, hourly}))
+	assert.Len(t, s.cr.Entries(), 2)
+	assert.Equal(t, []grazer.Schedule{{Spec: "@weekly"}, hourly}, scheduleList(s))
+	assert.Equal(t, hourlyID, s.entries[1].id)
+}
+
+func scheduleList(s *scheduler) []grazer.Schedule {
+	schedules := make([]grazer.Schedule, len(s.entries))
+	for i, entry := range s.entries {
+		schedules[i] = entry.schedule
+	}
+	return schedules
+}
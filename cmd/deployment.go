@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/networkteam/grazer"
+)
+
+// createDeploymentWatcher creates a watcher for the Next.js build identity, it returns nil if no URL is configured.
+func createDeploymentWatcher(c *cli.Context, h *grazer.Handler) (*grazer.DeploymentWatcher, error) {
+	url := c.String("deployment-watch-url")
+	if url == "" {
+		return nil, nil
+	}
+
+	var pattern *regexp.Regexp
+	if value := c.String("deployment-watch-pattern"); value != "" {
+		var err error
+		pattern, err = regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid deployment-watch-pattern: %w", err)
+		}
+		if pattern.NumSubexp() < 1 {
+			return nil, fmt.Errorf("invalid deployment-watch-pattern: expected a submatch for the build id")
+		}
+	}
+
+	schedule, err := grazer.ParseScheduleOptions(c.String("deployment-revalidate"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid deployment-revalidate: %w", err)
+	}
+	if err := h.ValidateSchedule(schedule); err != nil {
+		return nil, fmt.Errorf("invalid deployment-revalidate: %w", err)
+	}
+
+	return grazer.NewDeploymentWatcher(grazer.DeploymentWatcherOpts{
+		Handler:  h,
+		URL:      url,
+		Header:   c.String("deployment-watch-header"),
+		Pattern:  pattern,
+		Interval: c.Duration("deployment-watch-interval"),
+		Schedule: schedule,
+		ClaimDir: c.String("deployment-claim-dir"),
+	}), nil
+}
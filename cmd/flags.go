@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// repeatedValue is the value of a repeatable flag whose values can contain commas.
+// Unlike a StringSliceFlag a value is not split on commas, multiple values of an environment variable are separated by newlines.
+type repeatedValue struct {
+	values []string
+}
+
+func (v *repeatedValue) Set(value string) error {
+	for _, line := range strings.Split(value, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			v.values = append(v.values, line)
+		}
+	}
+	return nil
+}
+
+func (v *repeatedValue) String() string {
+	return strings.Join(v.values, "\n")
+}
+
+// repeatedValues returns the values of a repeatable flag.
+func repeatedValues(c *cli.Context, name string) []string {
+	if v, ok := c.Generic(name).(*repeatedValue); ok {
+		return v.values
+	}
+	return nil
+}
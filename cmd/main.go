@@ -4,9 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -21,32 +24,107 @@ import (
 )
 
 func main() {
-	app := &cli.App{
+	if err := newApp().Run(os.Args); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+}
+
+// newApp creates the CLI app with all flags and commands.
+func newApp() *cli.App {
+	return &cli.App{
 		Name:  "grazer",
 		Usage: "Handle invalidates from Neos CMS and revalidation in Next.js",
+		Commands: []*cli.Command{
+			queueCommand(),
+			replayCommand(),
+			configCommand(),
+		},
 		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "config",
+				Usage:   "YAML config file with targets, schedules, path rules and tokens in addition to flags, reloaded on SIGHUP",
+				EnvVars: []string{"GZ_CONFIG"},
+			},
 			&cli.StringFlag{
 				Name:    "address",
 				Value:   ":3100",
 				Usage:   "Address for HTTP server to listen on",
 				EnvVars: []string{"GZ_ADDRESS"},
 			},
+			&cli.StringFlag{
+				Name:    "grpc-address",
+				Usage:   "Address for the gRPC server to listen on (disabled if empty)",
+				EnvVars: []string{"GZ_GRPC_ADDRESS"},
+			},
 			&cli.StringFlag{
 				Name:    "revalidate-token",
-				Usage:   "A secret token to use for revalidation",
+				Usage:   "A secret token for invalidations from Neos (also sent to Next.js if next-revalidate-token is not set)",
 				EnvVars: []string{"GZ_REVALIDATE_TOKEN"},
 			},
+			&cli.StringFlag{
+				Name:    "next-revalidate-token",
+				Usage:   "A secret token sent to Next.js for revalidation",
+				EnvVars: []string{"GZ_NEXT_REVALIDATE_TOKEN"},
+			},
+			&cli.StringFlag{
+				Name:    "next-revalidate-token-file",
+				Usage:   "Read the token sent to Next.js for revalidation from a file",
+				EnvVars: []string{"GZ_NEXT_REVALIDATE_TOKEN_FILE"},
+			},
+			&cli.StringFlag{
+				Name:    "tokens-file",
+				Usage:   `Read additional API tokens from a file with one "name secret scopes" per line (scopes: invalidate, full-revalidate, admin:read, admin:write), reloaded on change or SIGHUP`,
+				EnvVars: []string{"GZ_TOKENS_FILE"},
+			},
+			&cli.DurationFlag{
+				Name:    "tokens-reload-interval",
+				Usage:   "Interval for checking the tokens file for changes",
+				Value:   10 * time.Second,
+				EnvVars: []string{"GZ_TOKENS_RELOAD_INTERVAL"},
+			},
+			&cli.StringFlag{
+				Name:    "admin-token",
+				Usage:   "A secret token for the dashboard at /admin/ and the status API (disabled if empty)",
+				EnvVars: []string{"GZ_ADMIN_TOKEN"},
+			},
+			&cli.StringFlag{
+				Name:    "admin-token-file",
+				Usage:   "Read the admin token from a file",
+				EnvVars: []string{"GZ_ADMIN_TOKEN_FILE"},
+			},
 			&cli.StringFlag{
 				Name:    "next-revalidate-url",
 				Usage:   "The full URL to call to revalidate a page in Next.js",
 				EnvVars: []string{"GZ_NEXT_REVALIDATE_URL"},
 			},
+			&cli.StringSliceFlag{
+				Name:    "target",
+				Usage:   `Add a named revalidation target in addition to next-revalidate-url (e.g. "preview=http://preview:3000/api/revalidate")`,
+				EnvVars: []string{"GZ_TARGET"},
+			},
+			&cli.Int64Flag{
+				Name:    "max-request-body-bytes",
+				Usage:   "Maximum size of request bodies for the API",
+				Value:   grazer.DefaultMaxRequestBodyBytes,
+				EnvVars: []string{"GZ_MAX_REQUEST_BODY_BYTES"},
+			},
 			&cli.IntFlag{
 				Name:    "revalidate-batch-size",
 				Usage:   "The number of documents to send for revalidation in one batch to Next.js",
 				Value:   1,
 				EnvVars: []string{"GZ_REVALIDATE_BATCH_SIZE"},
 			},
+			&cli.IntFlag{
+				Name:    "revalidate-retries",
+				Usage:   "The number of retries with exponential backoff for a failed revalidation request",
+				Value:   2,
+				EnvVars: []string{"GZ_REVALIDATE_RETRIES"},
+			},
+			&cli.Float64Flag{
+				Name:    "revalidate-rate-limit",
+				Usage:   "Maximum number of revalidate requests per second for each target (unlimited if 0)",
+				EnvVars: []string{"GZ_REVALIDATE_RATE_LIMIT"},
+			},
 			&cli.DurationFlag{
 				Name:    "revalidate-timeout",
 				Usage:   "Timeout for revalidation requests",
@@ -63,22 +141,266 @@ func main() {
 				Usage:   "The publicly accessible base URL for sending correct proxy headers to Neos (for multi-site setups)",
 				EnvVars: []string{"GZ_PUBLIC_BASE_URL"},
 			},
+			&cli.StringFlag{
+				Name:    "neos-basic-auth-username",
+				Usage:   "Username for basic auth against the Neos content API",
+				EnvVars: []string{"GZ_NEOS_BASIC_AUTH_USERNAME"},
+			},
+			&cli.StringFlag{
+				Name:    "neos-basic-auth-password",
+				Usage:   "Password for basic auth against the Neos content API",
+				EnvVars: []string{"GZ_NEOS_BASIC_AUTH_PASSWORD"},
+			},
+			&cli.StringFlag{
+				Name:    "neos-basic-auth-password-file",
+				Usage:   "Read the password for basic auth against the Neos content API from a file",
+				EnvVars: []string{"GZ_NEOS_BASIC_AUTH_PASSWORD_FILE"},
+			},
+			&cli.StringFlag{
+				Name:    "neos-bearer-token",
+				Usage:   "Bearer token for requests to the Neos content API",
+				EnvVars: []string{"GZ_NEOS_BEARER_TOKEN"},
+			},
+			&cli.StringFlag{
+				Name:    "neos-bearer-token-file",
+				Usage:   "Read the bearer token for requests to the Neos content API from a file",
+				EnvVars: []string{"GZ_NEOS_BEARER_TOKEN_FILE"},
+			},
+			&cli.StringSliceFlag{
+				Name:    "neos-header",
+				Usage:   `Add a header to requests to the Neos content API (e.g. "X-Api-Key: secret")`,
+				EnvVars: []string{"GZ_NEOS_HEADER"},
+			},
+			&cli.StringFlag{
+				Name:    "neos-headers-file",
+				Usage:   "Read headers for requests to the Neos content API from a file (one \"Name: value\" per line)",
+				EnvVars: []string{"GZ_NEOS_HEADERS_FILE"},
+			},
+			&cli.StringFlag{
+				Name:    "neos-client-cert",
+				Usage:   "PEM encoded client certificate file for mutual TLS with the Neos content API",
+				EnvVars: []string{"GZ_NEOS_CLIENT_CERT"},
+			},
+			&cli.StringFlag{
+				Name:    "neos-client-key",
+				Usage:   "PEM encoded client key file for mutual TLS with the Neos content API",
+				EnvVars: []string{"GZ_NEOS_CLIENT_KEY"},
+			},
+			&cli.StringFlag{
+				Name:    "neos-ca-cert",
+				Usage:   "PEM encoded CA certificates file to verify the Neos content API server certificate",
+				EnvVars: []string{"GZ_NEOS_CA_CERT"},
+			},
 			&cli.DurationFlag{
 				Name:    "fetch-timeout",
 				Usage:   "Timeout for fetching from the Neos content API",
 				Value:   15 * time.Second,
 				EnvVars: []string{"GZ_FETCH_TIMEOUT"},
 			},
+			&cli.StringSliceFlag{
+				Name:    "sitemap-url",
+				Usage:   "Add a sitemap.xml or sitemap index URL as document source for a full revalidation",
+				EnvVars: []string{"GZ_SITEMAP_URL"},
+			},
+			&cli.StringSliceFlag{
+				Name:    "static-paths-file",
+				Usage:   "Add a file with one route path per line as document source for a full revalidation",
+				EnvVars: []string{"GZ_STATIC_PATHS_FILE"},
+			},
+			&cli.GenericFlag{
+				Name:    "include-path",
+				Usage:   `Only revalidate route paths matching a glob or regular expression with "re:" prefix, optionally scoped to schedules or targets (e.g. "/blog/**;target=next"), can be repeated or separated by newlines`,
+				EnvVars: []string{"GZ_INCLUDE_PATH"},
+				Value:   &repeatedValue{},
+			},
+			&cli.GenericFlag{
+				Name:    "exclude-path",
+				Usage:   `Never revalidate route paths matching a glob or regular expression with "re:" prefix, optionally scoped to schedules or targets (e.g. "/archive/**;schedule=@hourly"), can be repeated or separated by newlines`,
+				EnvVars: []string{"GZ_EXCLUDE_PATH"},
+				Value:   &repeatedValue{},
+			},
+			&cli.StringFlag{
+				Name:    "sweep-order",
+				Usage:   `Order of documents in a full revalidation as comma separated keys "priority", "depth", "lastModified" and "routePath" with optional ":asc" or ":desc" suffix (e.g. "priority,depth")`,
+				EnvVars: []string{"GZ_SWEEP_ORDER"},
+			},
+			&cli.StringSliceFlag{
+				Name:    "sweep-node-type-priority",
+				Usage:   `Set a priority weight for documents of a node type without an explicit priority (e.g. "Neos.Neos:Shortcut=-1")`,
+				EnvVars: []string{"GZ_SWEEP_NODE_TYPE_PRIORITY"},
+			},
+			&cli.DurationFlag{
+				Name:    "sweep-duration",
+				Usage:   "Target duration of a full revalidation, route paths are dispatched at a rate derived from the number of documents (as fast as possible if 0), invalidations are never delayed",
+				EnvVars: []string{"GZ_SWEEP_DURATION"},
+			},
+			&cli.StringSliceFlag{
+				Name:    "sweep-quiet-hours",
+				Usage:   `Add a daily window in local time in which a paced full revalidation is slowed down further (e.g. "08:00-18:00")`,
+				EnvVars: []string{"GZ_SWEEP_QUIET_HOURS"},
+			},
+			&cli.Float64Flag{
+				Name:    "sweep-quiet-factor",
+				Usage:   "Factor for the delay between batches of a paced full revalidation during quiet hours",
+				Value:   4,
+				EnvVars: []string{"GZ_SWEEP_QUIET_FACTOR"},
+			},
+			&cli.DurationFlag{
+				Name:    "refresh-max-age",
+				Usage:   "Continuously revalidate route paths with the oldest successful revalidation at low priority, so no page is older than the maximum age (disabled if 0)",
+				EnvVars: []string{"GZ_REFRESH_MAX_AGE"},
+			},
+			&cli.DurationFlag{
+				Name:    "refresh-interval",
+				Usage:   "Interval for listing documents and enqueuing the oldest route paths of the rolling refresh",
+				Value:   5 * time.Minute,
+				EnvVars: []string{"GZ_REFRESH_INTERVAL"},
+			},
+			&cli.StringFlag{
+				Name:    "refresh-state-file",
+				Usage:   "File for the last successful revalidation of each route path, kept across restarts for the rolling refresh (kept in memory only if empty)",
+				EnvVars: []string{"GZ_REFRESH_STATE_FILE"},
+			},
+			&cli.BoolFlag{
+				Name:    "initial-revalidate",
+				Usage:   "Revalidate all pages after Neos and Next.js are ready",
+				Value:   true,
+				EnvVars: []string{"GZ_INITIAL_REVALIDATE"},
+			},
 			&cli.DurationFlag{
 				Name:    "initial-revalidate-delay",
-				Usage:   "Delay before an initial revalidation of all pages, set to 0 to disable",
-				Value:   15 * time.Second,
+				Usage:   "Delay before probing the readiness for the initial revalidation, setting it to 0 explicitly disables the initial revalidation for compatibility",
 				EnvVars: []string{"GZ_INITIAL_REVALIDATE_DELAY"},
 			},
-			&cli.StringSliceFlag{
+			&cli.DurationFlag{
+				Name:    "initial-revalidate-timeout",
+				Usage:   "Deadline for Neos and Next.js to become ready and the initial revalidation to succeed",
+				Value:   10 * time.Minute,
+				EnvVars: []string{"GZ_INITIAL_REVALIDATE_TIMEOUT"},
+			},
+			&cli.StringFlag{
+				Name:    "next-health-url",
+				Usage:   "Next.js URL probed with GET until it responds with a 2xx status before the initial revalidation (the reachability of targets is checked if empty)",
+				EnvVars: []string{"GZ_NEXT_HEALTH_URL"},
+			},
+			&cli.DurationFlag{
+				Name:    "readiness-interval",
+				Usage:   "Interval for probing Neos and Next.js until they are ready",
+				Value:   2 * time.Second,
+				EnvVars: []string{"GZ_READINESS_INTERVAL"},
+			},
+			&cli.StringFlag{
+				Name:    "deployment-watch-url",
+				Usage:   "Next.js URL responding with the build identity (e.g. a version file or a page), a changed build triggers a revalidation (disabled if empty)",
+				EnvVars: []string{"GZ_DEPLOYMENT_WATCH_URL"},
+			},
+			&cli.StringFlag{
+				Name:    "deployment-watch-header",
+				Usage:   "Response header with the build identity (the body is used if empty)",
+				EnvVars: []string{"GZ_DEPLOYMENT_WATCH_HEADER"},
+			},
+			&cli.StringFlag{
+				Name:    "deployment-watch-pattern",
+				Usage:   `Regular expression extracting the build identity from the header or body with its first submatch (e.g. "buildId":"([^"]+)" for the __NEXT_DATA__ of a page)`,
+				EnvVars: []string{"GZ_DEPLOYMENT_WATCH_PATTERN"},
+			},
+			&cli.DurationFlag{
+				Name:    "deployment-watch-interval",
+				Usage:   "Interval for polling the build identity",
+				Value:   time.Minute,
+				EnvVars: []string{"GZ_DEPLOYMENT_WATCH_INTERVAL"},
+			},
+			&cli.StringFlag{
+				Name:    "deployment-revalidate",
+				Usage:   `Restrict the revalidation after a changed build with options "name=<name>;path=<pattern>;target=<name>;priority=<priority>" (all pages if empty, the name defaults to "deployment")`,
+				EnvVars: []string{"GZ_DEPLOYMENT_REVALIDATE"},
+			},
+			&cli.StringFlag{
+				Name:    "deployment-claim-dir",
+				Usage:   "Directory shared by all replicas to claim the revalidation of a changed build, so only one replica revalidates it",
+				EnvVars: []string{"GZ_DEPLOYMENT_CLAIM_DIR"},
+			},
+			&cli.GenericFlag{
 				Name:    "revalidate-schedule",
-				Usage:   `Add a cron schedule to trigger revalidation of all pages (e.g. "@hourly", "@daily", "30 * * * *")`,
+				Usage:   `Add a cron schedule to trigger revalidation of all pages (e.g. "@hourly", "@daily", "30 * * * *"), restrict it with options "<spec>;name=<name>;path=<pattern>;target=<name>;priority=<priority>" (e.g. "*/5 * * * *;name=news;path=/news/**;priority=normal"), can be repeated or separated by newlines`,
 				EnvVars: []string{"GZ_REVALIDATE_SCHEDULE"},
+				Value:   &repeatedValue{},
+			},
+			&cli.StringFlag{
+				Name:    "history-file",
+				Usage:   "File for the history of invalidations and revalidations (kept in memory only if empty)",
+				EnvVars: []string{"GZ_HISTORY_FILE"},
+			},
+			&cli.IntFlag{
+				Name:    "history-size",
+				Usage:   "The number of records to keep in the history",
+				Value:   10000,
+				EnvVars: []string{"GZ_HISTORY_SIZE"},
+			},
+			&cli.StringSliceFlag{
+				Name:    "webhook",
+				Usage:   `Send events as signed JSON to a webhook with "name=url", can be repeated`,
+				EnvVars: []string{"GZ_WEBHOOK"},
+			},
+			&cli.GenericFlag{
+				Name:    "webhook-events",
+				Usage:   `Event types sent to a webhook with "name=type,type" (jobFinished, pathFailed, sweepFinished, upstreamUnreachable and upstreamRecovered if not set), can be repeated or separated by newlines`,
+				EnvVars: []string{"GZ_WEBHOOK_EVENTS"},
+				Value:   &repeatedValue{},
+			},
+			&cli.StringFlag{
+				Name:    "webhook-secret",
+				Usage:   "A secret for signing webhook deliveries with HMAC-SHA256",
+				EnvVars: []string{"GZ_WEBHOOK_SECRET"},
+			},
+			&cli.StringFlag{
+				Name:    "webhook-secret-file",
+				Usage:   "Read the secret for signing webhook deliveries from a file",
+				EnvVars: []string{"GZ_WEBHOOK_SECRET_FILE"},
+			},
+			&cli.DurationFlag{
+				Name:    "webhook-batch-interval",
+				Usage:   "Maximum delay of an event until it is delivered to webhooks",
+				Value:   5 * time.Second,
+				EnvVars: []string{"GZ_WEBHOOK_BATCH_INTERVAL"},
+			},
+			&cli.IntFlag{
+				Name:    "webhook-retries",
+				Usage:   "Number of retries with exponential backoff for a failed webhook delivery (no retries if 0)",
+				Value:   5,
+				EnvVars: []string{"GZ_WEBHOOK_RETRIES"},
+			},
+			&cli.DurationFlag{
+				Name:    "upstream-check-interval",
+				Usage:   "Interval for checking if Neos and Next.js are reachable to publish upstreamUnreachable and upstreamRecovered events (disabled if 0)",
+				Value:   time.Minute,
+				EnvVars: []string{"GZ_UPSTREAM_CHECK_INTERVAL"},
+			},
+			&cli.StringFlag{
+				Name:    "capture-file",
+				Usage:   "Append authenticated invalidation requests to a file for grazer replay (auth headers are redacted)",
+				EnvVars: []string{"GZ_CAPTURE_FILE"},
+			},
+			&cli.Int64Flag{
+				Name:    "capture-max-file-bytes",
+				Usage:   "Maximum size of the capture file, it is rotated to a file with a .1 suffix when full",
+				Value:   grazer.DefaultMaxCaptureFileBytes,
+				EnvVars: []string{"GZ_CAPTURE_MAX_FILE_BYTES"},
+			},
+			&cli.BoolFlag{
+				Name:    "capture-unknown",
+				Usage:   "Also capture requests to unknown endpoints",
+				EnvVars: []string{"GZ_CAPTURE_UNKNOWN"},
+			},
+			&cli.BoolFlag{
+				Name:    "capture-unauthenticated",
+				Usage:   "Also capture requests without a valid token",
+				EnvVars: []string{"GZ_CAPTURE_UNAUTHENTICATED"},
+			},
+			&cli.BoolFlag{
+				Name:    "dry-run",
+				Usage:   "Only log and record revalidate requests in the history instead of sending them to Next.js",
+				EnvVars: []string{"GZ_DRY_RUN"},
 			},
 			&cli.BoolFlag{
 				Name:    "verbose",
@@ -99,35 +421,154 @@ func main() {
 			ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
 			defer cancel()
 
-			revalidator := grazer.NewRevalidator(grazer.RevalidatorOpts{
-				URL:             c.String("next-revalidate-url"),
-				RevalidateToken: c.String("revalidate-token"),
-				Timeout:         c.Duration("revalidate-timeout"),
-			})
+			s, err := loadSettings(c)
+			if err != nil {
+				return err
+			}
+
+			contentAPITransport, err := createContentAPITransport(c)
+			if err != nil {
+				return err
+			}
 
 			fetcher := grazer.NewFetcher(grazer.FetcherOpts{
 				Timeout:       c.Duration("fetch-timeout"),
 				NeosBaseURL:   c.String("neos-base-url"),
 				PublicBaseURL: c.String("public-base-url"),
+				Transport:     contentAPITransport,
 			})
 
+			documentSource, err := createDocumentSource(c, fetcher)
+			if err != nil {
+				return err
+			}
+
+			sweepOrder, err := createSweepOrder(c)
+			if err != nil {
+				return err
+			}
+
+			sweepPacing, err := createSweepPacing(c)
+			if err != nil {
+				return err
+			}
+
+			if c.Duration("refresh-interval") <= 0 {
+				return errors.New("invalid refresh-interval: must be positive")
+			}
+			refresh := grazer.RefreshOpts{
+				MaxAge:    c.Duration("refresh-max-age"),
+				Interval:  c.Duration("refresh-interval"),
+				StateFile: c.String("refresh-state-file"),
+			}
+
+			adminToken, err := secretValue(c, "admin-token")
+			if err != nil {
+				return err
+			}
+
+			history, err := grazer.NewHistory(grazer.HistoryOpts{
+				Filename:   c.String("history-file"),
+				MaxRecords: c.Int("history-size"),
+			})
+			if err != nil {
+				return err
+			}
+			defer history.Close()
+
+			tokens, err := createTokens(ctx, c)
+			if err != nil {
+				return err
+			}
+			// Tokens of the config file are replaced on reload
+			if tokens == nil && c.String("config") != "" {
+				tokens = grazer.NewTokenSet(s.tokens)
+			}
+
+			webhooks, err := createWebhooks(c)
+			if err != nil {
+				return err
+			}
+
+			var capture *grazer.Capture
+			if c.String("capture-file") != "" {
+				capture, err = grazer.NewCapture(grazer.CaptureOpts{
+					Filename:        c.String("capture-file"),
+					MaxBodyBytes:    c.Int64("max-request-body-bytes"),
+					MaxFileBytes:    c.Int64("capture-max-file-bytes"),
+					Unknown:         c.Bool("capture-unknown"),
+					Unauthenticated: c.Bool("capture-unauthenticated"),
+				})
+				if err != nil {
+					return err
+				}
+				defer capture.Close()
+			}
+
 			h := grazer.NewHandler(grazer.HandlerOpts{
-				Revalidator:         revalidator,
+				AdminToken:          adminToken,
+				Tokens:              tokens,
+				History:             history,
+				Revalidators:        s.revalidators,
+				PathRules:           s.pathRules,
 				Fetcher:             fetcher,
+				DocumentSource:      documentSource,
 				RevalidateToken:     c.String("revalidate-token"),
 				RevalidateBatchSize: c.Int("revalidate-batch-size"),
+				RevalidateRetries:   c.Int("revalidate-retries"),
+				SweepOrder:          sweepOrder,
+				SweepPacing:         sweepPacing,
+				Refresh:             refresh,
+				MaxRequestBodyBytes: c.Int64("max-request-body-bytes"),
+				Webhooks:            webhooks,
+				Capture:             capture,
 			})
 
+			if interval := c.Duration("upstream-check-interval"); interval > 0 {
+				go h.MonitorUpstreams(ctx, interval)
+			}
+
+			deploymentWatcher, err := createDeploymentWatcher(c, h)
+			if err != nil {
+				return err
+			}
+			if deploymentWatcher != nil {
+				go deploymentWatcher.Run(ctx)
+			}
+
 			srv := &http.Server{
 				Addr:    c.String("address"),
 				Handler: h,
 			}
+			// Open event streams would block a graceful shutdown
+			srv.RegisterOnShutdown(h.CloseStreams)
 
-			cr, err := createCron(c, h)
+			sched, err := createScheduler(h, s.schedules)
 			if err != nil {
 				return err
 			}
 
+			if c.String("config") != "" {
+				go reloadOnSignal(ctx, c, h, tokens, sched)
+			}
+
+			var grpcServer *grazer.GRPCServer
+			if c.String("grpc-address") != "" {
+				lis, err := net.Listen("tcp", c.String("grpc-address"))
+				if err != nil {
+					return fmt.Errorf("listening for gRPC: %w", err)
+				}
+				grpcServer = grazer.NewGRPCServer(grazer.GRPCServerOpts{Handler: h})
+				go func() {
+					log.Infof("Listening for gRPC on %s", c.String("grpc-address"))
+					if err := grpcServer.Serve(lis); err != nil {
+						log.
+							WithError(err).
+							Error("Error serving gRPC")
+					}
+				}()
+			}
+
 			// Shutdown srv gracefully on signal and use wait group to wait for shutdown
 			shutdownCtx, shutdownDone := context.WithCancel(context.Background())
 			go func() {
@@ -140,8 +581,14 @@ func main() {
 				}
 				log.Debug("HTTP server shut down")
 
+				if grpcServer != nil {
+					log.Debug("Shutting down gRPC server...")
+					grpcServer.GracefulStop()
+					log.Debug("gRPC server shut down")
+				}
+
 				log.Debug("Stopping cron...")
-				cr.Stop()
+				sched.stop()
 
 				log.Debug("Waiting for controller to finish...")
 				h.ShutdownAndWait()
@@ -150,29 +597,12 @@ func main() {
 				shutdownDone()
 			}()
 
-			if c.Duration("initial-revalidate-delay") > 0 {
-				log.Debugf("Scheduling initial revalidation in %s", c.Duration("initial-revalidate-delay"))
-				time.AfterFunc(c.Duration("initial-revalidate-delay"), func() {
-					ctx := context.Background()
-					err := backoff.Retry(func() error {
-						log.
-							WithField("component", "controller").
-							Debug("Performing initial revalidate")
-
-						err := h.FullRevalidate(ctx)
-						if err != nil {
-							log.
-								WithError(err).
-								Warn("Initial revalidation failed, retrying...")
-						}
-						return err
-					}, backoff.NewExponentialBackOff())
-					if err != nil {
-						log.
-							WithError(err).
-							Error("Initial revalidation failed")
-					}
-				})
+			if initialRevalidateEnabled(c) {
+				go initialRevalidate(ctx, c, h)
+			}
+
+			if c.Bool("dry-run") {
+				log.Warn("Dry run enabled, revalidate requests are not sent to Next.js")
 			}
 
 			log.Infof("Listening on %s", c.String("address"))
@@ -189,10 +619,262 @@ func main() {
 			return nil
 		},
 	}
+}
 
-	if err := app.Run(os.Args); err != nil {
-		log.Fatalf("Error: %v", err)
+// initialRevalidateEnabled checks if the initial revalidation is enabled, an explicit delay of 0 disables it for compatibility.
+func initialRevalidateEnabled(c *cli.Context) bool {
+	if c.IsSet("initial-revalidate-delay") && c.Duration("initial-revalidate-delay") == 0 {
+		return false
 	}
+	return c.Bool("initial-revalidate")
+}
+
+// initialRevalidate waits until Neos and Next.js are ready and revalidates all pages.
+// Probing and retries stop at the deadline of initial-revalidate-timeout or on shutdown.
+func initialRevalidate(ctx context.Context, c *cli.Context, h *grazer.Handler) {
+	ctx, cancel := context.WithTimeout(ctx, c.Duration("initial-revalidate-timeout"))
+	defer cancel()
+
+	if delay := c.Duration("initial-revalidate-delay"); delay > 0 {
+		log.Debugf("Delaying initial revalidation by %s", delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	log.
+		WithField("component", "controller").
+		Info("Waiting for Neos and Next.js to be ready for the initial revalidation")
+	err := h.WaitReady(ctx, grazer.ReadinessOpts{
+		NextHealthURL: c.String("next-health-url"),
+		Interval:      c.Duration("readiness-interval"),
+	})
+	if err != nil {
+		log.
+			WithError(err).
+			Error("Skipping initial revalidation")
+		return
+	}
+
+	err = backoff.Retry(func() error {
+		log.
+			WithField("component", "controller").
+			Debug("Performing initial revalidate")
+
+		err := h.FullRevalidate(ctx)
+		if err != nil {
+			log.
+				WithError(err).
+				Warn("Initial revalidation failed, retrying...")
+		}
+		return err
+	}, backoff.WithContext(backoff.NewExponentialBackOff(), ctx))
+	if err != nil {
+		log.
+			WithError(err).
+			Error("Initial revalidation failed")
+	}
+}
+
+// createRevalidators creates the default target for next-revalidate-url and additional named targets of flags and the config file.
+func createRevalidators(c *cli.Context, config grazer.Config) ([]*grazer.Revalidator, error) {
+	revalidateToken, err := secretValue(c, "next-revalidate-token")
+	if err != nil {
+		return nil, err
+	}
+	// Fall back to the inbound token for compatibility
+	if revalidateToken == "" {
+		revalidateToken = c.String("revalidate-token")
+	}
+
+	rateLimit := c.Float64("revalidate-rate-limit")
+	if config.RateLimit > 0 {
+		rateLimit = config.RateLimit
+	}
+
+	var (
+		revalidators []*grazer.Revalidator
+		names        = make(map[string]struct{})
+	)
+	add := func(name, url string, targetRateLimit float64) error {
+		if _, exists := names[name]; exists {
+			return fmt.Errorf("duplicate target name %q", name)
+		}
+		names[name] = struct{}{}
+
+		if targetRateLimit == 0 {
+			targetRateLimit = rateLimit
+		}
+		revalidators = append(revalidators, grazer.NewRevalidator(grazer.RevalidatorOpts{
+			Name:            name,
+			URL:             url,
+			RevalidateToken: revalidateToken,
+			Timeout:         c.Duration("revalidate-timeout"),
+			DryRun:          c.Bool("dry-run"),
+			RateLimit:       targetRateLimit,
+		}))
+		return nil
+	}
+
+	if url := c.String("next-revalidate-url"); url != "" {
+		_ = add(grazer.DefaultRevalidatorName, url, 0)
+	}
+	for _, target := range c.StringSlice("target") {
+		name, url, ok := strings.Cut(target, "=")
+		if !ok || name == "" || url == "" {
+			return nil, fmt.Errorf("invalid target %q: expected name=url", target)
+		}
+		if err := add(name, url, 0); err != nil {
+			return nil, err
+		}
+	}
+	for _, target := range config.Targets {
+		if err := add(target.Name, target.URL, target.RateLimit); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(revalidators) == 0 {
+		return nil, errors.New("no revalidation target configured, set next-revalidate-url, target or targets in the config file")
+	}
+
+	return revalidators, nil
+}
+
+// createTokens loads the tokens file and reloads it on changes and SIGHUP until the context is done.
+func createTokens(ctx context.Context, c *cli.Context) (*grazer.TokenSet, error) {
+	filename := c.String("tokens-file")
+	if filename == "" {
+		return nil, nil
+	}
+
+	tokens, err := grazer.LoadTokensFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	tokenSet := grazer.NewTokenSet(tokens)
+
+	go tokenSet.WatchFile(ctx, filename, c.Duration("tokens-reload-interval"))
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+			}
+
+			err := tokenSet.LoadFile(filename)
+			if err != nil {
+				log.
+					WithError(err).
+					Error("Reloading tokens failed, keeping current tokens")
+				continue
+			}
+			log.
+				WithField("count", len(tokenSet.Tokens())).
+				Info("Reloaded tokens")
+		}
+	}()
+
+	return tokenSet, nil
+}
+
+func createPathRules(c *cli.Context) (grazer.PathRules, error) {
+	var rules grazer.PathRules
+	for _, value := range repeatedValues(c, "include-path") {
+		rule, err := grazer.ParsePathRule(value, false)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include-path %q: %w", value, err)
+		}
+		rules = append(rules, rule)
+	}
+	for _, value := range repeatedValues(c, "exclude-path") {
+		rule, err := grazer.ParsePathRule(value, true)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude-path %q: %w", value, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// createDocumentSource combines the Neos content API (if a base URL is set), sitemaps and static path files as a document source.
+func createDocumentSource(c *cli.Context, fetcher *grazer.Fetcher) (grazer.DocumentSource, error) {
+	var sources []grazer.DocumentSource
+	if c.String("neos-base-url") != "" {
+		sources = append(sources, fetcher)
+	}
+	for _, sitemapURL := range c.StringSlice("sitemap-url") {
+		sources = append(sources, grazer.NewSitemapSource(grazer.SitemapSourceOpts{
+			URL:     sitemapURL,
+			Timeout: c.Duration("fetch-timeout"),
+		}))
+	}
+	for _, filename := range c.StringSlice("static-paths-file") {
+		sources = append(sources, grazer.NewStaticSource(filename))
+	}
+
+	switch len(sources) {
+	case 0:
+		return nil, errors.New("no document source configured, set at least one of neos-base-url, sitemap-url or static-paths-file")
+	case 1:
+		return sources[0], nil
+	}
+	return grazer.NewMultiSource(sources...), nil
+}
+
+func createSweepOrder(c *cli.Context) (grazer.SweepOrder, error) {
+	rules, err := grazer.ParseSweepOrder(c.String("sweep-order"))
+	if err != nil {
+		return grazer.SweepOrder{}, fmt.Errorf("invalid sweep-order: %w", err)
+	}
+
+	nodeTypePriorities := make(map[string]float64)
+	for _, value := range c.StringSlice("sweep-node-type-priority") {
+		nodeType, priority, ok := strings.Cut(value, "=")
+		if !ok {
+			return grazer.SweepOrder{}, fmt.Errorf("invalid sweep-node-type-priority %q: expected NodeType=priority", value)
+		}
+		p, err := strconv.ParseFloat(priority, 64)
+		if err != nil {
+			return grazer.SweepOrder{}, fmt.Errorf("invalid sweep-node-type-priority %q: %w", value, err)
+		}
+		nodeTypePriorities[nodeType] = p
+	}
+
+	return grazer.SweepOrder{
+		Rules:              rules,
+		NodeTypePriorities: nodeTypePriorities,
+	}, nil
+}
+
+func createSweepPacing(c *cli.Context) (grazer.SweepPacing, error) {
+	if c.Float64("sweep-quiet-factor") < 1 {
+		return grazer.SweepPacing{}, errors.New("invalid sweep-quiet-factor: must be at least 1")
+	}
+
+	pacing := grazer.SweepPacing{
+		Duration:    c.Duration("sweep-duration"),
+		QuietFactor: c.Float64("sweep-quiet-factor"),
+	}
+	for _, value := range c.StringSlice("sweep-quiet-hours") {
+		window, err := grazer.ParseTimeWindow(value)
+		if err != nil {
+			return grazer.SweepPacing{}, fmt.Errorf("invalid sweep-quiet-hours: %w", err)
+		}
+		pacing.QuietHours = append(pacing.QuietHours, window)
+	}
+	if len(pacing.QuietHours) > 0 && pacing.Duration == 0 {
+		return grazer.SweepPacing{}, errors.New("sweep-quiet-hours needs a sweep-duration")
+	}
+
+	return pacing, nil
 }
 
 func setServerLogHandler(c *cli.Context) {
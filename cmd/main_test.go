@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+	"github.com/urfave/cli/v2"
+
+	"github.com/networkteam/grazer"
+)
+
+func Test_createPathRules(t *testing.T) {
+	parse := func(t *testing.T, args ...string) (grazer.PathRules, error) {
+		t.Helper()
+
+		var (
+			rules    grazer.PathRules
+			rulesErr error
+		)
+		app := newApp()
+		app.Action = func(c *cli.Context) error {
+			rules, rulesErr = createPathRules(c)
+			return nil
+		}
+		require.NoError(t, app.Run(append([]string{"grazer"}, args...)))
+		return rules, rulesErr
+	}
+
+	// A regular expression with a comma is kept as one rule
+	rules, err := parse(t, "--exclude-path", "re:^/(a|b){1,3}$;schedule=nightly", "--include-path", "/blog/**")
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, "/blog/**", rules[0].Pattern)
+	assert.Equal(t, "re:^/(a|b){1,3}$", rules[1].Pattern)
+	assert.Equal(t, []string{"nightly"}, rules[1].Schedules)
+
+	t.Setenv("GZ_EXCLUDE_PATH", "/archive/**\nre:^/events/\\d{2,}$")
+	rules, err = parse(t)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, "re:^/events/\\d{2,}$", rules[1].Pattern)
+
+	// The rest of a value that was split on a comma is rejected
+	_, err = parse(t, "--exclude-path", "3}")
+	assert.EqualError(t, err, `invalid exclude-path "3}": glob "3}" must start with / or *`)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/networkteam/grazer"
+	"github.com/networkteam/grazer/client"
+)
+
+// queueCommand controls the queue of a running grazer instance via the admin API.
+func queueCommand() *cli.Command {
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:    "url",
+			Usage:   "Base URL of the running grazer instance",
+			Value:   "http://localhost:3100",
+			EnvVars: []string{"GZ_URL"},
+		},
+		&cli.StringFlag{
+			Name:    "admin-token",
+			Usage:   "A token with the admin:write scope",
+			EnvVars: []string{"GZ_ADMIN_TOKEN"},
+		},
+		&cli.StringFlag{
+			Name:    "admin-token-file",
+			Usage:   "Read the admin token from a file",
+			EnvVars: []string{"GZ_ADMIN_TOKEN_FILE"},
+		},
+	}
+
+	subcommand := func(name, usage string) *cli.Command {
+		return &cli.Command{
+			Name:  name,
+			Usage: usage,
+			Flags: flags,
+			Action: func(c *cli.Context) error {
+				return queueControl(c, name)
+			},
+		}
+	}
+
+	return &cli.Command{
+		Name:  "queue",
+		Usage: "Control the queue of a running instance",
+		Subcommands: []*cli.Command{
+			subcommand("pause", "Pause dispatching revalidations, invalidations are still enqueued"),
+			subcommand("resume", "Resume dispatching revalidations"),
+			subcommand("drain", "Drop route paths of full revalidations from the queue"),
+			subcommand("cancel", "Drop all route paths from the queue"),
+		},
+	}
+}
+
+func queueControl(c *cli.Context, action string) error {
+	token, err := secretValue(c, "admin-token")
+	if err != nil {
+		return err
+	}
+
+	apiClient := client.New(client.Opts{
+		BaseURL: c.String("url"),
+		Token:   token,
+		Timeout: 10 * time.Second,
+	})
+
+	var result *grazer.QueueControlResponse
+	switch action {
+	case "pause":
+		result, err = apiClient.PauseQueue(c.Context)
+	case "resume":
+		result, err = apiClient.ResumeQueue(c.Context)
+	case "drain":
+		result, err = apiClient.DrainSweep(c.Context)
+	case "cancel":
+		result, err = apiClient.CancelQueue(c.Context)
+	}
+	if err != nil {
+		return fmt.Errorf("controlling queue: %w", err)
+	}
+
+	fmt.Fprintf(c.App.Writer, "paused: %t, dropped: %d, queue length: %d\n", result.Paused, result.Dropped, result.Length)
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/networkteam/grazer"
+)
+
+// replayCommand replays a capture file against a running grazer instance.
+func replayCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "replay",
+		Usage:     "Replay captured requests against a running instance (e.g. a staging or dry-run instance)",
+		ArgsUsage: "CAPTURE_FILE",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "url",
+				Usage:   "Base URL of the running grazer instance",
+				Value:   "http://localhost:3100",
+				EnvVars: []string{"GZ_URL"},
+			},
+			&cli.StringFlag{
+				Name:    "token",
+				Usage:   "A token with the invalidate scope, sent instead of the redacted authorization",
+				EnvVars: []string{"GZ_TOKEN"},
+			},
+			&cli.StringFlag{
+				Name:    "token-file",
+				Usage:   "Read the token from a file",
+				EnvVars: []string{"GZ_TOKEN_FILE"},
+			},
+			&cli.Float64Flag{
+				Name:  "speed",
+				Usage: "Scale the delays between captured requests (e.g. 2 for twice as fast), 0 sends requests without delays",
+				Value: 1,
+			},
+			&cli.BoolFlag{
+				Name:  "unknown",
+				Usage: "Also replay requests to unknown endpoints",
+			},
+		},
+		Action: replay,
+	}
+}
+
+func replay(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("expected a capture file as argument")
+	}
+
+	token, err := secretValue(c, "token")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(c.Args().First())
+	if err != nil {
+		return fmt.Errorf("opening capture file: %w", err)
+	}
+	defer f.Close()
+
+	records, err := grazer.ReadCapture(f)
+	if err != nil {
+		return err
+	}
+
+	var replayed, changed, failed int
+	err = grazer.Replay(c.Context, records, grazer.ReplayOpts{
+		BaseURL: c.String("url"),
+		Token:   token,
+		Speed:   c.Float64("speed"),
+		Unknown: c.Bool("unknown"),
+	}, func(result grazer.ReplayResult) {
+		replayed++
+		record := result.Record
+		if result.Err != nil {
+			failed++
+			fmt.Fprintf(c.App.Writer, "%s %s %s: %v\n", record.Time.Format(time.RFC3339), record.Method, record.URI, result.Err)
+			return
+		}
+		if result.Status != record.Status {
+			changed++
+		}
+		fmt.Fprintf(c.App.Writer, "%s %s %s: %d (captured %d)\n", record.Time.Format(time.RFC3339), record.Method, record.URI, result.Status, record.Status)
+	})
+	if err != nil {
+		return fmt.Errorf("replaying: %w", err)
+	}
+
+	fmt.Fprintf(c.App.Writer, "replayed: %d, different status: %d, failed: %d\n", replayed, changed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d requests failed", failed)
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/networkteam/grazer"
+)
+
+// createWebhooks creates the webhooks with their event types, all webhooks share the secret.
+func createWebhooks(c *cli.Context) ([]*grazer.Webhook, error) {
+	webhookOpts, err := parseWebhooks(c)
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks := make([]*grazer.Webhook, len(webhookOpts))
+	for i, opts := range webhookOpts {
+		webhooks[i] = grazer.NewWebhook(opts)
+	}
+	return webhooks, nil
+}
+
+// parseWebhooks parses the options of all webhooks from the webhook flags.
+func parseWebhooks(c *cli.Context) ([]grazer.WebhookOpts, error) {
+	secret, err := secretValue(c, "webhook-secret")
+	if err != nil {
+		return nil, err
+	}
+
+	type webhookConfig struct {
+		url    string
+		events []grazer.EventType
+	}
+	var (
+		names   []string
+		configs = make(map[string]*webhookConfig)
+	)
+	for _, webhook := range c.StringSlice("webhook") {
+		name, url, ok := strings.Cut(webhook, "=")
+		if !ok || name == "" || url == "" {
+			return nil, fmt.Errorf("invalid webhook %q: expected name=url", webhook)
+		}
+		if _, exists := configs[name]; exists {
+			return nil, fmt.Errorf("duplicate webhook name %q", name)
+		}
+		names = append(names, name)
+		configs[name] = &webhookConfig{url: url}
+	}
+
+	for _, webhookEvents := range repeatedValues(c, "webhook-events") {
+		name, types, ok := strings.Cut(webhookEvents, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid webhook events %q: expected name=type,type", webhookEvents)
+		}
+		config, exists := configs[name]
+		if !exists {
+			return nil, fmt.Errorf("webhook events for unknown webhook %q", name)
+		}
+		events, err := grazer.ParseWebhookEvents(types)
+		if err != nil {
+			return nil, fmt.Errorf("parsing events of webhook %q: %w", name, err)
+		}
+		config.events = events
+	}
+
+	webhookOpts := make([]grazer.WebhookOpts, len(names))
+	for i, name := range names {
+		webhookOpts[i] = grazer.WebhookOpts{
+			Name:          name,
+			URL:           configs[name].url,
+			Secret:        secret,
+			Events:        configs[name].events,
+			BatchInterval: c.Duration("webhook-batch-interval"),
+			Retries:       c.Int("webhook-retries"),
+		}
+	}
+
+	return webhookOpts, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+	"github.com/urfave/cli/v2"
+
+	"github.com/networkteam/grazer"
+)
+
+func Test_parseWebhooks(t *testing.T) {
+	parse := func(t *testing.T, args ...string) []grazer.WebhookOpts {
+		t.Helper()
+
+		var webhookOpts []grazer.WebhookOpts
+		app := newApp()
+		app.Action = func(c *cli.Context) error {
+			var err error
+			webhookOpts, err = parseWebhooks(c)
+			return err
+		}
+		require.NoError(t, app.Run(append([]string{"grazer"}, args...)))
+		return webhookOpts
+	}
+
+	t.Run("documented example", func(t *testing.T) {
+		webhookOpts := parse(t,
+			"--webhook", "chat=https://chat.example.com/hooks/grazer",
+			"--webhook", "search=https://search.example.com/reindex", "--webhook-events", "search=jobFinished,sweepFinished",
+		)
+
+		require.Len(t, webhookOpts, 2)
+		assert.Equal(t, "chat", webhookOpts[0].Name)
+		assert.Empty(t, webhookOpts[0].Events)
+		assert.Equal(t, "search", webhookOpts[1].Name)
+		assert.Equal(t, "https://search.example.com/reindex", webhookOpts[1].URL)
+		assert.Equal(t, []grazer.EventType{grazer.EventJobFinished, grazer.EventSweepFinished}, webhookOpts[1].Events)
+	})
+
+	t.Run("environment variable", func(t *testing.T) {
+		t.Setenv("GZ_WEBHOOK", "a=http://a.example.com")
+		t.Setenv("GZ_WEBHOOK_EVENTS", "a=jobFinished,pathFailed")
+
+		webhookOpts := parse(t)
+		require.Len(t, webhookOpts, 1)
+		assert.Equal(t, []grazer.EventType{grazer.EventJobFinished, grazer.EventPathFailed}, webhookOpts[0].Events)
+	})
+}
@@ -0,0 +1,215 @@
+package grazer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the schema of the YAML configuration file.
+// Targets, schedules, path rules and tokens of the file are added to the ones configured with flags.
+type Config struct {
+	// Targets are named revalidation targets
+	Targets []ConfigTarget `yaml:"targets"`
+	// RateLimit is the maximum number of revalidate requests per second for each target without an own rate limit
+	RateLimit float64 `yaml:"rateLimit"`
+	// Schedules trigger scoped or full revalidations
+	Schedules []Schedule `yaml:"schedules"`
+	// Paths are include and exclude rules in the format of ParsePathRule
+	Paths ConfigPaths `yaml:"paths"`
+	// Tokens are inbound API tokens
+	Tokens []ConfigToken `yaml:"tokens"`
+}
+
+type ConfigTarget struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+	// RateLimit is the maximum number of revalidate requests per second (Config.RateLimit if 0)
+	RateLimit float64 `yaml:"rateLimit"`
+}
+
+type ConfigPaths struct {
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+}
+
+type ConfigToken struct {
+	Name string `yaml:"name"`
+	// Secret of the token, alternatively read from SecretFile
+	Secret     string   `yaml:"secret"`
+	SecretFile string   `yaml:"secretFile"`
+	Scopes     []string `yaml:"scopes"`
+}
+
+// LoadConfig reads and validates a configuration file.
+func LoadConfig(filename string) (Config, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return Config{}, fmt.Errorf("opening config file: %w", err)
+	}
+	defer f.Close()
+
+	config, err := ParseConfig(f)
+	if err != nil {
+		return Config{}, fmt.Errorf("parsing config file %s: %w", filename, err)
+	}
+	return config, nil
+}
+
+// ParseConfig decodes and validates a YAML configuration, unknown keys are rejected.
+func ParseConfig(r io.Reader) (Config, error) {
+	var config Config
+
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return Config{}, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// Validate checks the configuration without reading secret files.
+func (c Config) Validate() error {
+	if c.RateLimit < 0 {
+		return errors.New("rateLimit: must not be negative")
+	}
+
+	names := make(map[string]struct{})
+	for i, target := range c.Targets {
+		if target.Name == "" || target.URL == "" {
+			return fmt.Errorf("targets[%d]: name and url are required", i)
+		}
+		if _, exists := names[target.Name]; exists {
+			return fmt.Errorf("targets[%d]: duplicate target name %q", i, target.Name)
+		}
+		names[target.Name] = struct{}{}
+		if target.RateLimit < 0 {
+			return fmt.Errorf("targets[%d]: rateLimit must not be negative", i)
+		}
+	}
+
+	for i, schedule := range c.Schedules {
+		if schedule.Spec == "" {
+			return fmt.Errorf("schedules[%d]: spec is required", i)
+		}
+		if _, err := schedule.scope(); err != nil {
+			return fmt.Errorf("schedules[%d]: %w", i, err)
+		}
+	}
+
+	if _, err := c.PathRules(); err != nil {
+		return err
+	}
+
+	tokenNames := make(map[string]struct{})
+	for i, token := range c.Tokens {
+		if token.Name == "" {
+			return fmt.Errorf("tokens[%d]: name is required", i)
+		}
+		if _, exists := tokenNames[token.Name]; exists {
+			return fmt.Errorf("tokens[%d]: duplicate token name %q", i, token.Name)
+		}
+		tokenNames[token.Name] = struct{}{}
+		if (token.Secret == "") == (token.SecretFile == "") {
+			return fmt.Errorf("tokens[%d]: exactly one of secret or secretFile is required", i)
+		}
+		if len(token.Scopes) == 0 {
+			return fmt.Errorf("tokens[%d]: scopes are required", i)
+		}
+		for _, s := range token.Scopes {
+			if _, err := parseScope(s); err != nil {
+				return fmt.Errorf("tokens[%d]: %w", i, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// PathRules parses the include and exclude rules.
+func (c Config) PathRules() (PathRules, error) {
+	var rules PathRules
+	for _, value := range c.Paths.Include {
+		rule, err := ParsePathRule(value, false)
+		if err != nil {
+			return nil, fmt.Errorf("paths.include %q: %w", value, err)
+		}
+		rules = append(rules, rule)
+	}
+	for _, value := range c.Paths.Exclude {
+		rule, err := ParsePathRule(value, true)
+		if err != nil {
+			return nil, fmt.Errorf("paths.exclude %q: %w", value, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// LoadTokens returns the tokens with secrets read from secret files.
+func (c Config) LoadTokens() ([]Token, error) {
+	tokens := make([]Token, 0, len(c.Tokens))
+	secrets := make(map[string]struct{})
+	for _, configToken := range c.Tokens {
+		token := Token{
+			Name:   configToken.Name,
+			Secret: configToken.Secret,
+		}
+		if configToken.SecretFile != "" {
+			data, err := os.ReadFile(configToken.SecretFile)
+			if err != nil {
+				return nil, fmt.Errorf("reading secret of token %s: %w", configToken.Name, err)
+			}
+			token.Secret = strings.TrimSpace(string(data))
+		}
+		if token.Secret == "" {
+			return nil, fmt.Errorf("empty secret of token %s", configToken.Name)
+		}
+		if _, exists := secrets[token.Secret]; exists {
+			return nil, fmt.Errorf("duplicate secret of token %s", configToken.Name)
+		}
+		secrets[token.Secret] = struct{}{}
+
+		for _, s := range configToken.Scopes {
+			scope, err := parseScope(s)
+			if err != nil {
+				return nil, err
+			}
+			token.Scopes = append(token.Scopes, scope)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, nil
+}
+
+type ReconfigureOpts struct {
+	// Revalidators are all revalidation targets
+	Revalidators []*Revalidator
+	PathRules    PathRules
+}
+
+// Reconfigure replaces the targets and path rules at runtime, the queue and running jobs are kept.
+// Queued route paths restricted to removed targets fail without a revalidation.
+func (h *Handler) Reconfigure(opts ReconfigureOpts) error {
+	if len(opts.Revalidators) == 0 {
+		return errors.New("no revalidation target")
+	}
+	names := make(map[string]struct{})
+	for _, target := range opts.Revalidators {
+		if _, exists := names[target.Name()]; exists {
+			return fmt.Errorf("duplicate target name %q", target.Name())
+		}
+		names[target.Name()] = struct{}{}
+	}
+
+	h.ctrl.reconfigure(opts.Revalidators, opts.PathRules)
+	return nil
+}
@@ -0,0 +1,222 @@
+package grazer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func TestParseConfig(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(secretFile, []byte("file-secret\n"), 0o600))
+
+	config, err := ParseConfig(strings.NewReader(`
+targets:
+  - name: preview
+    url: http://preview:3000/api/revalidate
+    rateLimit: 2
+rateLimit: 10
+schedules:
+  - name: news
+    spec: "*/5 * * * *"
+    paths: ["/news/*"]
+    priority: normal
+  - spec: "@midnight"
+paths:
+  include: ["/**"]
+  exclude: ["/archive/**;schedule=news"]
+tokens:
+  - name: neos
+    secret: a-secret
+    scopes: [invalidate]
+  - name: ci
+    secretFile: ` + secretFile + `
+    scopes: [full-revalidate, admin:read]
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, []ConfigTarget{{Name: "preview", URL: "http://preview:3000/api/revalidate", RateLimit: 2}}, config.Targets)
+	assert.Equal(t, 10.0, config.RateLimit)
+	require.Len(t, config.Schedules, 2)
+	assert.Equal(t, Schedule{Name: "news", Spec: "*/5 * * * *", Paths: []string{"/news/*"}, Priority: PriorityNormal}, config.Schedules[0])
+
+	rules, err := config.PathRules()
+	require.NoError(t, err)
+	assert.Len(t, rules, 2)
+
+	tokens, err := config.LoadTokens()
+	require.NoError(t, err)
+	assert.Equal(t, []Token{
+		{Name: "neos", Secret: "a-secret", Scopes: []Scope{ScopeInvalidate}},
+		{Name: "ci", Secret: "file-secret", Scopes: []Scope{ScopeFullRevalidate, ScopeAdminRead}},
+	}, tokens)
+
+	// An empty config is valid
+	_, err = ParseConfig(strings.NewReader(""))
+	assert.NoError(t, err)
+
+	invalid := map[string]string{
+		"unknown key":      "target: []",
+		"missing url":      "targets: [{name: preview}]",
+		"invalid priority": "schedules: [{spec: '@hourly', priority: high}]",
+		"invalid path":     "paths: {exclude: ['re:[']}",
+		"unknown scope":    "tokens: [{name: a, secret: b, scopes: [write]}]",
+		"missing secret":   "tokens: [{name: a, scopes: [invalidate]}]",
+	}
+	for name, s := range invalid {
+		_, err := ParseConfig(strings.NewReader(s))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestHandler_Reconfigure(t *testing.T) {
+	var (
+		mx       sync.Mutex
+		received = make(map[string]int)
+	)
+	newTarget := func(name string) *Revalidator {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Ignore health checks of the status
+			if r.Method != http.MethodPost {
+				return
+			}
+			mx.Lock()
+			defer mx.Unlock()
+			received[name]++
+		}))
+		t.Cleanup(srv.Close)
+		return NewRevalidator(RevalidatorOpts{Name: name, URL: srv.URL})
+	}
+
+	h := NewHandler(HandlerOpts{
+		Revalidators:   []*Revalidator{newTarget("next")},
+		DocumentSource: staticDocuments{{RoutePath: "/"}, {RoutePath: "/about"}},
+	})
+	defer h.ShutdownAndWait()
+	h.PauseQueue()
+
+	ctx := context.Background()
+	require.NoError(t, h.FullRevalidate(ctx))
+
+	rule, err := NewPathRule("/about", true)
+	require.NoError(t, err)
+	err = h.Reconfigure(ReconfigureOpts{
+		Revalidators: []*Revalidator{newTarget("next"), newTarget("preview")},
+		PathRules:    PathRules{rule},
+	})
+	require.NoError(t, err)
+
+	// The queue is kept
+	status := h.Status(ctx)
+	assert.Equal(t, 2, status.Queue.Length)
+
+	events, unsubscribe := h.Subscribe()
+	defer unsubscribe()
+	h.ResumeQueue()
+	for event := range events {
+		if event.Type == EventQueueDrained {
+			break
+		}
+	}
+
+	mx.Lock()
+	assert.Equal(t, map[string]int{"next": 2, "preview": 2}, received)
+	mx.Unlock()
+
+	// New path rules apply to the next revalidation
+	h.PauseQueue()
+	require.NoError(t, h.FullRevalidate(ctx))
+	status = h.Status(ctx)
+	assert.Equal(t, 1, status.Queue.Length)
+
+	assert.NoError(t, h.ValidateSchedule(Schedule{Targets: []string{"preview"}}))
+
+	err = h.Reconfigure(ReconfigureOpts{})
+	assert.EqualError(t, err, "no revalidation target")
+}
+
+func TestHandler_ReconfigureRemovedTarget(t *testing.T) {
+	var (
+		mx       sync.Mutex
+		received = make(map[string]int)
+	)
+	newTarget := func(name string) *Revalidator {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				return
+			}
+			mx.Lock()
+			defer mx.Unlock()
+			received[name]++
+		}))
+		t.Cleanup(srv.Close)
+		return NewRevalidator(RevalidatorOpts{Name: name, URL: srv.URL})
+	}
+
+	next := newTarget("next")
+	rule, err := ParsePathRule("/only-b;target=next", true)
+	require.NoError(t, err)
+	h := NewHandler(HandlerOpts{
+		RevalidateToken: "a-token",
+		Revalidators:    []*Revalidator{next, newTarget("b")},
+		DocumentSource:  staticDocuments{{RoutePath: "/only-b"}},
+		PathRules:       PathRules{rule},
+	})
+	defer h.ShutdownAndWait()
+	h.PauseQueue()
+
+	events, unsubscribe := h.Subscribe()
+	defer unsubscribe()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/revalidate", strings.NewReader(`{"documents":[{"routePath":"/only-b"}],"skipFullRevalidate":true}`))
+	req.Header.Set("Authorization", "Bearer a-token")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp revalidateResponseBody
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+
+	for event := range events {
+		if event.Type == EventEnqueued {
+			break
+		}
+	}
+
+	// The only target of the queued route path is removed
+	require.NoError(t, h.Reconfigure(ReconfigureOpts{Revalidators: []*Revalidator{next}}))
+
+	h.ResumeQueue()
+	var failed []Event
+	for event := range events {
+		if event.Type == EventPathFailed {
+			failed = append(failed, event)
+		}
+		if event.Type == EventQueueDrained {
+			break
+		}
+	}
+
+	mx.Lock()
+	assert.Empty(t, received)
+	mx.Unlock()
+
+	require.Len(t, failed, 1)
+	assert.Equal(t, "/only-b", failed[0].RoutePath)
+
+	status := h.ctrl.jobs.status(resp.JobID)
+	require.NotNil(t, status)
+	assert.Equal(t, JobStateFailed, status.State)
+	require.Len(t, status.Paths, 1)
+	assert.Equal(t, PathStateFailed, status.Paths[0].State)
+	assert.Equal(t, "targets of the route path were removed", status.Paths[0].Error)
+	assert.Empty(t, h.ctrl.history.LastRevalidations("/only-b"))
+}
@@ -0,0 +1,117 @@
+package grazer
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// ContentAPIAuth configures authentication for requests to the Neos content API.
+type ContentAPIAuth struct {
+	BasicAuthUsername string
+	BasicAuthPassword string
+
+	BearerToken string
+
+	// Headers are added to every request (e.g. an API key header)
+	Headers http.Header
+
+	// ClientCertFile and ClientKeyFile are PEM encoded files for mutual TLS
+	ClientCertFile string
+	ClientKeyFile  string
+	// CAFile is a PEM encoded file with CA certificates to verify the server certificate (system roots are used if empty)
+	CAFile string
+}
+
+// NewContentAPITransport creates a round tripper that authenticates requests to the Neos content API according to auth.
+// The base transport is used for sending requests (http.DefaultTransport if nil), it must be a *http.Transport if TLS options are set.
+// It should be used as transport for all clients of the content API.
+func NewContentAPITransport(auth ContentAPIAuth, base http.RoundTripper) (http.RoundTripper, error) {
+	if auth.BasicAuthUsername != "" && auth.BearerToken != "" {
+		return nil, errors.New("basic auth and bearer token are mutually exclusive")
+	}
+	if (auth.ClientCertFile == "") != (auth.ClientKeyFile == "") {
+		return nil, errors.New("client certificate and key must be set together")
+	}
+
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if auth.ClientCertFile != "" || auth.CAFile != "" {
+		baseTransport, ok := base.(*http.Transport)
+		if !ok {
+			return nil, fmt.Errorf("cannot configure TLS for transport of type %T", base)
+		}
+
+		tlsConfig, err := auth.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		baseTransport = baseTransport.Clone()
+		baseTransport.TLSClientConfig = tlsConfig
+		base = baseTransport
+	}
+
+	return &contentAPITransport{
+		auth: auth,
+		base: base,
+	}, nil
+}
+
+func (a ContentAPIAuth) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if a.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(a.ClientCertFile, a.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if a.CAFile != "" {
+		caPEM, err := os.ReadFile(a.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", a.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+type contentAPITransport struct {
+	auth ContentAPIAuth
+	base http.RoundTripper
+}
+
+func (t *contentAPITransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A round tripper must not modify the given request
+	req = req.Clone(req.Context())
+
+	for name, values := range t.auth.Headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+
+	if t.auth.BasicAuthUsername != "" {
+		req.SetBasicAuth(t.auth.BasicAuthUsername, t.auth.BasicAuthPassword)
+	}
+	if t.auth.BearerToken != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", t.auth.BearerToken))
+	}
+
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,48 @@
+package grazer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func TestNewContentAPITransport(t *testing.T) {
+	t.Run("basic auth and headers", func(t *testing.T) {
+		var receivedHeader http.Header
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			receivedHeader = r.Header.Clone()
+			_, _ = w.Write([]byte(`{"documents":[{"routePath":"/"}]}`))
+		}))
+		defer srv.Close()
+
+		transport, err := NewContentAPITransport(ContentAPIAuth{
+			BasicAuthUsername: "neos",
+			BasicAuthPassword: "secret",
+			Headers:           http.Header{"X-Api-Key": []string{"key"}},
+		}, nil)
+		require.NoError(t, err)
+
+		fetcher := NewFetcher(FetcherOpts{
+			NeosBaseURL: srv.URL,
+			Transport:   transport,
+		})
+		resp, err := fetcher.ListDocuments(context.Background())
+		require.NoError(t, err)
+
+		assert.Len(t, resp.Documents, 1)
+		assert.Equal(t, "key", receivedHeader.Get("X-Api-Key"))
+		assert.Equal(t, "Basic bmVvczpzZWNyZXQ=", receivedHeader.Get("Authorization"))
+	})
+
+	t.Run("basic auth and bearer token are exclusive", func(t *testing.T) {
+		_, err := NewContentAPITransport(ContentAPIAuth{
+			BasicAuthUsername: "neos",
+			BearerToken:       "token",
+		}, nil)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,860 @@
+package grazer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/cenkalti/backoff/v4"
+)
+
+type controller struct {
+	mx sync.Mutex
+
+	revalidateBatchSize int
+	// revalidateRetries is the number of retries of a failed revalidate request
+	revalidateRetries int
+
+	source DocumentSource
+
+	// configMx guards targets and rules, they can be replaced at runtime
+	configMx sync.RWMutex
+	targets  []*Revalidator
+	rules    PathRules
+
+	// documents of the last listing to detect removed and renamed documents
+	documents []DocumentsItem
+
+	diffMx   sync.RWMutex
+	lastDiff *DocumentsDiff
+
+	jobs    *jobRegistry
+	events  *eventBroker
+	tracker *revalidationTracker
+	history *History
+	// freshness records the last successful revalidation of each route path for the rolling refresh (nil if disabled)
+	freshness *freshness
+
+	// sweepMx guards the progress of a full revalidation
+	sweepMx     sync.Mutex
+	sweepTotal  int
+	sweepDone   int
+	sweepFailed int
+	// nextSweepAt is the earliest time for dispatching the next sweep batch if the sweep is paced
+	nextSweepAt time.Time
+
+	pacing SweepPacing
+
+	// paused stops dispatching of the queue
+	pausedMx sync.RWMutex
+	paused   bool
+
+	// delayed are invalidations waiting for their notBefore time, they are canceled together with the queue
+	delayedMx sync.Mutex
+	delayed   map[*job]*delayedInvalidation
+
+	// stopped refuses new work after the shutdown started, the run loop must not be signaled after sig was closed
+	stoppedMx sync.RWMutex
+	stopped   bool
+
+	queue *queue
+	sig   chan struct{}
+	wg    sync.WaitGroup
+}
+
+var errStopped = errors.New("shutting down")
+
+func newController(targets []*Revalidator, source DocumentSource, rules PathRules, history *History) *controller {
+	ctrl := &controller{
+		revalidateBatchSize: 1,
+
+		targets: targets,
+		source:  source,
+		rules:   rules,
+
+		delayed: make(map[*job]*delayedInvalidation),
+
+		jobs:    newJobRegistry(1000, maxJobAge),
+		events:  newEventBroker(),
+		tracker: newRevalidationTracker(),
+		history: history,
+
+		queue: newQueue(),
+		// Buffered, so a signal is not lost if the run loop is not waiting for it
+		sig: make(chan struct{}, 1),
+	}
+
+	ctrl.jobs.onFinished = func(status *JobStatus) {
+		ctrl.events.publish(Event{
+			Type:  EventJobFinished,
+			JobID: status.ID,
+			Error: status.Error,
+			Job:   status,
+		})
+	}
+
+	ctrl.wg.Add(1)
+	go ctrl.run()
+
+	return ctrl
+}
+
+type revalidateRequest struct {
+	invalidatedDocuments []revalidateRequestDocument
+	// schedule is the name of the schedule that triggered the revalidation (empty for invalidations and other full revalidations) for scoping path rules
+	schedule string
+	// scope restricts the documents of a scheduled revalidation (nil for all documents)
+	scope *scheduleScope
+	// job tracks the state of the invalidated documents (optional)
+	job *job
+	// tier of the invalidated documents
+	tier queueTier
+	// skipFullRevalidate only enqueues the invalidated documents, documents are only listed to expand patterns
+	skipFullRevalidate bool
+	// source, remoteAddr and token describe the origin of the request for the history
+	source     string
+	remoteAddr string
+	token      string
+}
+
+// Sources of invalidations and full revalidations in the history.
+const (
+	SourceAPI        = "api"
+	SourceSchedule   = "schedule"
+	SourceManual     = "manual"
+	SourceGRPC       = "grpc"
+	SourceDeployment = "deployment"
+)
+
+type revalidateResult struct {
+	expanded []expandedPattern
+}
+
+// revalidate lists all documents and enqueues them together with the invalidated documents.
+// Patterns of invalidated documents are expanded against the listed documents.
+func (c *controller) revalidate(ctx context.Context, req revalidateRequest) (revalidateResult, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if c.isStopped() {
+		if req.job != nil {
+			c.jobs.fail(req.job, errStopped)
+		}
+		return revalidateResult{}, errStopped
+	}
+
+	// Documents are only listed if needed for a full revalidation or to expand patterns
+	var documents []DocumentsItem
+	listDocuments := !req.skipFullRevalidate || hasPatterns(req.invalidatedDocuments)
+	if listDocuments {
+		documentsResponse, err := c.source.ListDocuments(ctx)
+		if err != nil {
+			err = fmt.Errorf("listing documents: %w", err)
+			if req.job != nil {
+				c.jobs.fail(req.job, err)
+			}
+			c.recordRequest(req, requestedRoutePaths(req.invalidatedDocuments), err)
+			return revalidateResult{}, err
+		}
+		documents = documentsResponse.Documents
+	}
+
+	schedule := req.schedule
+
+	invalidatedRoutePaths, expanded := expandInvalidatedDocuments(req.invalidatedDocuments, documents)
+	for _, e := range expanded {
+		log.
+			WithField("component", "controller").
+			WithField("pattern", e.Pattern).
+			WithField("count", e.Count).
+			Debug("Expanded invalidated pattern")
+	}
+
+	c.recordRequest(req, invalidatedRoutePaths, nil)
+
+	// Apply path rules before enqueuing
+	invalidatedEntries := c.filterRoutePaths(invalidatedRoutePaths, schedule)
+	for i := range invalidatedEntries {
+		invalidatedEntries[i].tier = req.tier
+	}
+
+	// Only the invalidated route paths of the request are tracked by the job
+	if req.job != nil {
+		c.jobs.register(req.job, entriesRoutePaths(invalidatedEntries))
+	}
+
+	if listDocuments {
+		// Removed and renamed documents are not part of the listing anymore, so they are revalidated with the same priority as invalidated documents
+		invalidatedEntries = append(invalidatedEntries, c.filterRoutePaths(c.diffDocuments(documents), schedule)...)
+	}
+
+	var allEntries []queueEntry
+	if !req.skipFullRevalidate {
+		allEntries = make([]queueEntry, 0, len(documents))
+		for _, document := range documents {
+			if !req.scope.matches(document.RoutePath) {
+				continue
+			}
+			entry, ok := c.filterEntry(document, schedule)
+			if !ok {
+				continue
+			}
+			if entry, ok = req.scope.restrictTargets(entry, c.targetNames()); ok {
+				allEntries = append(allEntries, entry)
+			}
+		}
+	}
+
+	// Route paths of a prioritized schedule are enqueued like invalidated route paths
+	if req.scope != nil && req.scope.prioritized {
+		for i := range allEntries {
+			allEntries[i].tier = req.scope.tier
+		}
+		invalidatedEntries = append(invalidatedEntries, allEntries...)
+		allEntries = nil
+	}
+
+	log.
+		WithField("component", "controller").
+		WithField("invalidatedRoutePaths", strings.Join(entriesRoutePaths(invalidatedEntries), ",")).
+		WithField("allRoutePaths", strings.Join(entriesRoutePaths(allEntries), ",")).
+		Debug("Enqueuing route paths")
+
+	c.queue.enqueueEntries(invalidatedEntries, allEntries)
+	c.updateSweepTotal()
+
+	enqueuedEvent := Event{
+		Type:        EventEnqueued,
+		Schedule:    schedule,
+		Invalidated: len(invalidatedEntries),
+		All:         len(allEntries),
+	}
+	if req.job != nil {
+		enqueuedEvent.JobID = req.job.id
+	}
+	c.events.publish(enqueuedEvent)
+
+	c.ensureProcessQueue()
+
+	return revalidateResult{
+		expanded: expanded,
+	}, nil
+}
+
+// recordRequest adds an invalidation or full revalidation to the history.
+func (c *controller) recordRequest(req revalidateRequest, routePaths []string, err error) {
+	record := HistoryRecord{
+		Type:       HistoryInvalidation,
+		RoutePaths: routePaths,
+		Source:     req.source,
+		RemoteAddr: req.remoteAddr,
+		Token:      req.token,
+		Schedule:   req.schedule,
+		Outcome:    OutcomeSuccess,
+	}
+	if len(req.invalidatedDocuments) == 0 {
+		record.Type = HistoryFullRevalidation
+	}
+	if req.job != nil {
+		record.JobID = req.job.id
+	}
+	if err != nil {
+		record.Outcome = OutcomeFailure
+		record.Error = err.Error()
+	}
+	c.history.Add(record)
+}
+
+// requestedRoutePaths returns the route paths and patterns of the documents as requested.
+func requestedRoutePaths(documents []revalidateRequestDocument) []string {
+	routePaths := make([]string, 0, len(documents))
+	for _, document := range documents {
+		if document.RoutePath != "" {
+			routePaths = append(routePaths, document.RoutePath)
+		} else {
+			routePaths = append(routePaths, document.RoutePathPrefix)
+		}
+	}
+	return routePaths
+}
+
+func (c *controller) filterRoutePaths(routePaths []string, schedule string) []queueEntry {
+	entries := make([]queueEntry, 0, len(routePaths))
+	for _, routePath := range routePaths {
+		if entry, ok := c.filterEntry(DocumentsItem{RoutePath: routePath}, schedule); ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// config returns the current targets and path rules.
+func (c *controller) config() ([]*Revalidator, PathRules) {
+	c.configMx.RLock()
+	defer c.configMx.RUnlock()
+
+	return c.targets, c.rules
+}
+
+// reconfigure replaces the targets and path rules, the queue is kept.
+// Batches in flight are finished with the previous targets.
+func (c *controller) reconfigure(targets []*Revalidator, rules PathRules) {
+	c.configMx.Lock()
+	defer c.configMx.Unlock()
+
+	c.targets = targets
+	c.rules = rules
+}
+
+func (c *controller) targetNames() []string {
+	targets, _ := c.config()
+	names := make([]string, len(targets))
+	for i, target := range targets {
+		names[i] = target.Name()
+	}
+	return names
+}
+
+// filterEntry applies the path rules for the schedule to a document and returns a queue entry restricted to the allowed targets.
+// It returns false if the document is not allowed for any target.
+func (c *controller) filterEntry(document DocumentsItem, schedule string) (queueEntry, bool) {
+	entry := queueEntry{document: document}
+	targets, rules := c.config()
+	if len(rules) == 0 {
+		return entry, true
+	}
+
+	var (
+		allowedTargets []string
+		reasons        []string
+	)
+	for _, target := range targets {
+		ok, reason := rules.allowed(document.RoutePath, schedule, target.Name())
+		if ok {
+			allowedTargets = append(allowedTargets, target.Name())
+		} else {
+			reasons = append(reasons, fmt.Sprintf("%s: %s", target.Name(), reason))
+		}
+	}
+
+	if len(allowedTargets) == 0 {
+		log.
+			WithField("component", "controller").
+			WithField("routePath", document.RoutePath).
+			WithField("schedule", schedule).
+			WithField("reason", strings.Join(reasons, ", ")).
+			Debug("Filtered route path")
+		metrics.Add(metricFilteredRoutePaths, 1)
+
+		return entry, false
+	}
+
+	// Only restrict targets if the route path was filtered for some targets
+	if len(allowedTargets) < len(targets) {
+		log.
+			WithField("component", "controller").
+			WithField("routePath", document.RoutePath).
+			WithField("schedule", schedule).
+			WithField("targets", strings.Join(allowedTargets, ",")).
+			WithField("reason", strings.Join(reasons, ", ")).
+			Debug("Filtered route path for some targets")
+		metrics.Add(metricFilteredRoutePaths, 1)
+
+		entry.targets = allowedTargets
+	}
+
+	return entry, true
+}
+
+// diffDocuments compares the given documents with the previous listing, stores a non-empty diff and returns stale route paths of the diff.
+func (c *controller) diffDocuments(documents []DocumentsItem) []string {
+	previous := c.documents
+	c.documents = documents
+
+	// Nothing to compare on the first listing
+	if previous == nil {
+		return nil
+	}
+
+	diff := diffDocuments(previous, documents)
+	if diff.Empty() {
+		return nil
+	}
+	diff.ComputedAt = time.Now()
+
+	log.
+		WithField("component", "controller").
+		WithField("added", strings.Join(diff.Added, ",")).
+		WithField("removed", strings.Join(diff.Removed, ",")).
+		WithField("renamed", len(diff.Renamed)).
+		Info("Detected changed documents")
+
+	c.diffMx.Lock()
+	c.lastDiff = &diff
+	c.diffMx.Unlock()
+
+	return diff.staleRoutePaths()
+}
+
+func (c *controller) lastDocumentsDiff() *DocumentsDiff {
+	c.diffMx.RLock()
+	defer c.diffMx.RUnlock()
+
+	return c.lastDiff
+}
+
+func (c *controller) shutdownAndWait() {
+	c.stoppedMx.Lock()
+	c.stopped = true
+	close(c.sig)
+	c.stoppedMx.Unlock()
+
+	c.wg.Wait()
+}
+
+func (c *controller) isStopped() bool {
+	c.stoppedMx.RLock()
+	defer c.stoppedMx.RUnlock()
+
+	return c.stopped
+}
+
+func (c *controller) run() {
+	defer c.wg.Done()
+
+	for {
+		// Wait for signal to process the queue or a close of the channel
+		_, ok := <-c.sig
+		// The channel was closed
+		if !ok {
+			log.
+				WithField("component", "controller").
+				Debug("Returning from run loop")
+			return
+		}
+
+		processed := false
+		for {
+			// Check if channel was closed while processing the queue
+			select {
+			case _, ok := <-c.sig:
+				if !ok {
+					log.
+						WithField("component", "controller").
+						Debug("Returning from run loop, stop processing the queue")
+					return
+				}
+			default:
+			}
+
+			// Keep route paths in the queue until processing is resumed
+			if c.isPaused() {
+				log.
+					WithField("component", "controller").
+					Debug("Queue is paused, stop processing")
+				break
+			}
+
+			// Wait for the pace of a full revalidation, invalidations arriving meanwhile are dispatched immediately
+			if wait := c.sweepWait(); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case _, ok := <-c.sig:
+					timer.Stop()
+					if !ok {
+						log.
+							WithField("component", "controller").
+							Debug("Returning from run loop, stop processing the queue")
+						return
+					}
+				case <-timer.C:
+				}
+				continue
+			}
+
+			entries := c.queuePopBatch()
+			if len(entries) == 0 {
+				log.
+					WithField("component", "controller").
+					Debug("Queue is empty, stop processing")
+				if processed {
+					c.events.publish(Event{Type: EventQueueDrained})
+				}
+				break
+			}
+
+			c.paceSweep(entries)
+			c.revalidateBatch(entries)
+			processed = true
+		}
+	}
+}
+
+// errTargetsRemoved is the error of route paths restricted to targets that were removed.
+var errTargetsRemoved = errors.New("targets of the route path were removed")
+
+// revalidateBatch sends the entries to all targets and reports the result to jobs.
+// A route path failed if the revalidation failed for any of the targets or if none of its targets exists anymore.
+func (c *controller) revalidateBatch(entries []queueEntry) {
+	batchRoutePaths := entriesRoutePaths(entries)
+	c.jobs.dispatched(batchRoutePaths)
+	c.freshness.dispatched(batchRoutePaths)
+
+	results := make(map[string]error, len(entries))
+	for _, entry := range entries {
+		results[entry.document.RoutePath] = nil
+	}
+	sweepRoutePaths := make(map[string]struct{})
+	for _, entry := range entries {
+		if entry.priority == 0 {
+			sweepRoutePaths[entry.document.RoutePath] = struct{}{}
+		}
+	}
+	targets, _ := c.config()
+	sent := make(map[string]bool, len(entries))
+	for _, target := range targets {
+		routePaths := targetRoutePaths(target, entries)
+		if len(routePaths) == 0 {
+			continue
+		}
+
+		err := c.revalidateTarget(target, routePaths, sweepRoutePaths)
+		for _, routePath := range routePaths {
+			sent[routePath] = true
+			if results[routePath] == nil {
+				results[routePath] = err
+			}
+		}
+	}
+
+	// Entries restricted to targets that were removed by a reconfiguration were not sent to any target
+	for _, entry := range entries {
+		routePath := entry.document.RoutePath
+		if sent[routePath] {
+			continue
+		}
+		results[routePath] = errTargetsRemoved
+		log.
+			WithField("component", "controller").
+			WithField("routePath", routePath).
+			WithField("targets", entry.targets).
+			Warn("Skipping route path, its targets were removed")
+		c.events.publish(Event{
+			Type:      EventPathFailed,
+			RoutePath: routePath,
+			Error:     errTargetsRemoved.Error(),
+		})
+	}
+
+	c.jobs.completed(results)
+
+	sweepEntries, sweepFailed := 0, 0
+	now := time.Now()
+	for _, entry := range entries {
+		routePath := entry.document.RoutePath
+		if results[routePath] == nil {
+			c.freshness.revalidated(routePath, now)
+			c.events.publish(Event{
+				Type:      EventPathRevalidated,
+				RoutePath: routePath,
+			})
+		}
+		if entry.priority == 0 {
+			sweepEntries++
+			if results[routePath] != nil {
+				sweepFailed++
+			}
+		}
+	}
+
+	c.freshness.finished(batchRoutePaths)
+
+	if sweepEntries > 0 {
+		c.publishSweepProgress(sweepEntries, sweepFailed)
+	}
+}
+
+// updateSweepTotal sets the total of the running full revalidation after zero-priority entries were enqueued.
+func (c *controller) updateSweepTotal() {
+	_, sweepLen := c.queue.len()
+
+	c.sweepMx.Lock()
+	defer c.sweepMx.Unlock()
+	c.sweepTotal = c.sweepDone + sweepLen
+}
+
+// publishSweepProgress adds done entries of a full revalidation and publishes the progress.
+// The progress is reset and EventSweepFinished is published after the full revalidation is done.
+func (c *controller) publishSweepProgress(done, failed int) {
+	_, remaining := c.queue.len()
+
+	c.sweepMx.Lock()
+	c.sweepDone += done
+	c.sweepFailed += failed
+	progress := SweepProgress{
+		Total:     c.sweepTotal,
+		Done:      c.sweepDone,
+		Remaining: remaining,
+		Failed:    c.sweepFailed,
+	}
+	if remaining == 0 {
+		c.sweepTotal = 0
+		c.sweepDone = 0
+		c.sweepFailed = 0
+	}
+	c.sweepMx.Unlock()
+
+	c.events.publish(Event{
+		Type:  EventSweepProgress,
+		Sweep: &progress,
+	})
+	if remaining == 0 {
+		c.events.publish(Event{
+			Type:  EventSweepFinished,
+			Sweep: &progress,
+		})
+	}
+}
+
+// sweepWait returns the remaining time until the next sweep batch can be dispatched.
+// It is zero if invalidated route paths are queued, they are never paced.
+func (c *controller) sweepWait() time.Duration {
+	if !c.pacing.enabled() {
+		return 0
+	}
+	total, sweep := c.queue.len()
+	if sweep == 0 || total > sweep {
+		return 0
+	}
+
+	c.sweepMx.Lock()
+	defer c.sweepMx.Unlock()
+	return time.Until(c.nextSweepAt)
+}
+
+// nextSweepDispatch returns the time of the next sweep batch if a paced sweep is waiting.
+func (c *controller) nextSweepDispatch() *time.Time {
+	if !c.pacing.enabled() {
+		return nil
+	}
+
+	c.sweepMx.Lock()
+	defer c.sweepMx.Unlock()
+	if c.sweepTotal == 0 || !c.nextSweepAt.After(time.Now()) {
+		return nil
+	}
+	next := c.nextSweepAt
+	return &next
+}
+
+// paceSweep sets the time for the next sweep batch after dispatching a batch with sweep entries.
+func (c *controller) paceSweep(entries []queueEntry) {
+	if !c.pacing.enabled() {
+		return
+	}
+	sweepEntries := 0
+	for _, entry := range entries {
+		if entry.priority == 0 {
+			sweepEntries++
+		}
+	}
+	if sweepEntries == 0 {
+		return
+	}
+
+	now := time.Now()
+	c.sweepMx.Lock()
+	defer c.sweepMx.Unlock()
+	c.nextSweepAt = now.Add(c.pacing.delay(sweepEntries, c.sweepTotal, now))
+}
+
+// targetRoutePaths returns the route paths of all entries that are not restricted to other targets.
+func targetRoutePaths(target *Revalidator, entries []queueEntry) []string {
+	var routePaths []string
+	for _, entry := range entries {
+		if entry.targets == nil || containsString(entry.targets, target.Name()) {
+			routePaths = append(routePaths, entry.document.RoutePath)
+		}
+	}
+	return routePaths
+}
+
+// revalidateTarget sends a revalidate request with retries to the target and records it in the history.
+// Route paths of a full revalidation (in sweepRoutePaths) are recorded apart from other route paths.
+func (c *controller) revalidateTarget(target *Revalidator, routePaths []string, sweepRoutePaths map[string]struct{}) error {
+	log.
+		WithField("component", "controller").
+		WithField("target", target.Name()).
+		WithField("routePaths", routePaths).
+		Info("Sending revalidate request")
+
+	c.events.publish(Event{
+		Type:       EventBatchStarted,
+		Target:     target.Name(),
+		RoutePaths: routePaths,
+	})
+
+	start := time.Now()
+	c.tracker.started(target.Name(), routePaths, start)
+
+	ctx := context.Background()
+	attempt := 1
+	err := backoff.RetryNotify(func() error {
+		return target.Revalidate(ctx, routePaths)
+	}, backoff.WithMaxRetries(backoff.NewExponentialBackOff(), uint64(c.revalidateRetries)), func(err error, next time.Duration) {
+		attempt++
+		log.
+			WithField("component", "controller").
+			WithField("target", target.Name()).
+			WithField("routePaths", routePaths).
+			WithField("attempt", attempt).
+			WithError(err).
+			Warnf("Revalidate failed, retrying in %s", next)
+		c.events.publish(Event{
+			Type:       EventRetried,
+			Target:     target.Name(),
+			RoutePaths: routePaths,
+			Attempt:    attempt,
+			Error:      err.Error(),
+		})
+	})
+	revalidationAttempt := RevalidationAttempt{
+		Time:       start,
+		Target:     target.Name(),
+		RoutePaths: routePaths,
+		DurationMs: time.Since(start).Milliseconds(),
+		Attempts:   attempt,
+	}
+	if err != nil {
+		revalidationAttempt.Error = err.Error()
+	}
+	c.tracker.finished(revalidationAttempt)
+
+	addRecord := func(recordRoutePaths []string, sweep bool) {
+		if len(recordRoutePaths) == 0 {
+			return
+		}
+		record := HistoryRecord{
+			Type:       HistoryRevalidation,
+			Time:       start,
+			RoutePaths: recordRoutePaths,
+			Target:     target.Name(),
+			DryRun:     target.DryRun(),
+			Outcome:    OutcomeSuccess,
+			DurationMs: revalidationAttempt.DurationMs,
+			Attempts:   attempt,
+			Sweep:      sweep,
+		}
+		if err != nil {
+			record.Outcome = OutcomeFailure
+			record.Error = err.Error()
+		}
+		c.history.Add(record)
+	}
+	var invalidatedRoutePaths, sweepPaths []string
+	for _, routePath := range routePaths {
+		if _, ok := sweepRoutePaths[routePath]; ok {
+			sweepPaths = append(sweepPaths, routePath)
+		} else {
+			invalidatedRoutePaths = append(invalidatedRoutePaths, routePath)
+		}
+	}
+	addRecord(invalidatedRoutePaths, false)
+	addRecord(sweepPaths, true)
+
+	if err != nil {
+		log.
+			WithField("component", "controller").
+			WithField("target", target.Name()).
+			WithField("routePaths", routePaths).
+			WithError(err).
+			Error("Revalidate failed")
+		for _, routePath := range routePaths {
+			c.events.publish(Event{
+				Type:       EventPathFailed,
+				Target:     target.Name(),
+				RoutePath:  routePath,
+				Error:      err.Error(),
+				DurationMs: time.Since(start).Milliseconds(),
+			})
+		}
+	}
+
+	log.
+		WithField("component", "controller").
+		WithField("target", target.Name()).
+		WithField("routePaths", strings.Join(routePaths, ",")).
+		WithDuration(time.Since(start)).
+		Debug("Revalidate finished")
+
+	return err
+}
+
+// healthCheckers returns health checkers for the document source and all targets.
+func (c *controller) healthCheckers() []namedHealthChecker {
+	var checkers []namedHealthChecker
+	if checker, ok := c.source.(HealthChecker); ok {
+		checkers = append(checkers, namedHealthChecker{
+			name:    "source",
+			checker: checker,
+		})
+	}
+	targets, _ := c.config()
+	for _, target := range targets {
+		checkers = append(checkers, namedHealthChecker{
+			name:    fmt.Sprintf("target %s", target.Name()),
+			checker: target,
+		})
+	}
+	return checkers
+}
+
+func (c *controller) ensureProcessQueue() {
+	c.stoppedMx.RLock()
+	defer c.stoppedMx.RUnlock()
+
+	// The queue is not processed anymore after the shutdown started
+	if c.stopped {
+		return
+	}
+
+	select {
+	case c.sig <- struct{}{}:
+		// Signal was sent
+	default:
+		// Signal was already sent
+	}
+}
+
+func (c *controller) queuePopBatch() []queueEntry {
+	var result []queueEntry
+	for {
+		var entry *queueEntry
+		// Sweep entries would bypass the pacing in a batch of invalidated entries
+		if c.pacing.enabled() && len(result) > 0 && result[0].priority != 0 {
+			entry = c.queue.popInvalidatedEntry()
+		} else {
+			entry = c.queue.popEntry()
+		}
+		if entry == nil {
+			break
+		}
+		result = append(result, *entry)
+		if len(result) == c.revalidateBatchSize {
+			break
+		}
+	}
+	return result
+}
+
+func entriesRoutePaths(entries []queueEntry) []string {
+	routePaths := make([]string, len(entries))
+	for i, entry := range entries {
+		routePaths[i] = entry.document.RoutePath
+	}
+	return routePaths
+}
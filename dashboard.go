@@ -0,0 +1,129 @@
+package grazer
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+)
+
+//go:embed dashboard.html
+var dashboardHTML string
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"join": strings.Join,
+	"ago": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return time.Since(t).Truncate(time.Second).String()
+	},
+	"until": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return time.Until(t).Truncate(time.Second).String()
+	},
+}).Parse(dashboardHTML))
+
+// upstreamCheckTimeout limits the time for health checks of upstreams.
+const upstreamCheckTimeout = 3 * time.Second
+
+func (h *Handler) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r, ScopeAdminRead) {
+		return
+	}
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	if r.URL.Path != "/admin/" {
+		writeError(w, http.StatusNotFound, ErrorResponse{
+			Code:    ErrorCodeNotFound,
+			Message: "page not found",
+		})
+		return
+	}
+
+	status := h.Status(r.Context())
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := dashboardTemplate.Execute(w, struct {
+		Status
+		GeneratedAt time.Time
+	}{
+		Status:      status,
+		GeneratedAt: time.Now(),
+	})
+	if err != nil {
+		log.
+			WithField("component", "http").
+			WithError(err).
+			Error("Rendering dashboard")
+	}
+}
+
+func (h *Handler) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r, ScopeAdminRead) {
+		return
+	}
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.Status(r.Context()))
+}
+
+// authorizeAdmin verifies a token with the scope is given as bearer token or basic auth password (for browsers).
+// Admin endpoints are disabled if no token has an admin scope.
+func (h *Handler) authorizeAdmin(w http.ResponseWriter, r *http.Request, scope Scope) bool {
+	if !h.adminEnabled() {
+		writeError(w, http.StatusNotFound, ErrorResponse{
+			Code:    ErrorCodeNotFound,
+			Message: "admin endpoints are disabled",
+		})
+		return false
+	}
+
+	token, ok := h.lookupToken(r)
+	if !ok {
+		log.
+			WithField("component", "http").
+			WithField("path", r.URL.Path).
+			Warn("Invalid admin token")
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", "grazer"))
+		writeError(w, http.StatusUnauthorized, ErrorResponse{
+			Code:    ErrorCodeUnauthorized,
+			Message: "invalid token",
+		})
+		return false
+	}
+	if !token.hasScope(scope) {
+		log.
+			WithField("component", "http").
+			WithField("path", r.URL.Path).
+			WithField("token", token.Name).
+			WithField("scope", scope).
+			Warn("Missing scope")
+		writeError(w, http.StatusForbidden, ErrorResponse{
+			Code:    ErrorCodeForbidden,
+			Message: fmt.Sprintf("missing scope %s", scope),
+		})
+		return false
+	}
+	return true
+}
+
+func (h *Handler) adminEnabled() bool {
+	for _, token := range h.allTokens() {
+		if token.hasScope(ScopeAdminRead) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,329 @@
+package grazer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// maxBuildIDSize limits the size of a response body that is read for the build identity.
+const maxBuildIDSize = 1 << 20
+
+type DeploymentWatcherOpts struct {
+	// Handler enqueues the revalidation after a changed build was detected
+	Handler *Handler
+	// URL responds with the identity of the current Next.js build (e.g. a version file or a page)
+	URL string
+	// Header contains the build identity in the response (the body is used if empty)
+	Header string
+	// Pattern extracts the build identity from the header or body with its first submatch (the whole trimmed value is used if nil)
+	Pattern *regexp.Regexp
+	// Interval between polls of the URL (1 minute if 0)
+	Interval time.Duration
+	// Schedule restricts the revalidation after a changed build to paths, targets and a priority (all documents if empty).
+	// Its name is used for path rules scoped to a schedule ("deployment" if empty).
+	Schedule Schedule
+	// ClaimDir is a directory shared by all replicas, only the replica that claims a build revalidates it (no de-duplication if empty)
+	ClaimDir string
+
+	Transport http.RoundTripper
+}
+
+// DeploymentWatcher polls the identity of the Next.js build and revalidates documents when it changes.
+type DeploymentWatcher struct {
+	h        *Handler
+	client   *http.Client
+	url      string
+	header   string
+	pattern  *regexp.Regexp
+	interval time.Duration
+	schedule Schedule
+	claimDir string
+
+	// buildID is the last confirmed build identity
+	buildID string
+	// candidate is a changed build identity that was observed once
+	candidate string
+}
+
+func NewDeploymentWatcher(opts DeploymentWatcherOpts) *DeploymentWatcher {
+	if opts.Interval == 0 {
+		opts.Interval = time.Minute
+	}
+	if opts.Schedule.Name == "" {
+		opts.Schedule.Name = "deployment"
+	}
+
+	return &DeploymentWatcher{
+		h:        opts.Handler,
+		client:   &http.Client{Transport: opts.Transport},
+		url:      opts.URL,
+		header:   opts.Header,
+		pattern:  opts.Pattern,
+		interval: opts.Interval,
+		schedule: opts.Schedule,
+		claimDir: opts.ClaimDir,
+	}
+}
+
+// Run polls the build identity every interval until ctx is done.
+// The first observed build is only recorded, the initial revalidation covers it.
+// A changed build must be observed by two consecutive polls, so a rolling deployment behind a load balancer does not trigger revalidations for both builds.
+func (w *DeploymentWatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.poll(ctx); err != nil && ctx.Err() == nil {
+			log.
+				WithField("component", "deployment").
+				WithError(err).
+				Warn("Checking Next.js deployment failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll fetches the build identity and revalidates documents if it changed since the last poll.
+func (w *DeploymentWatcher) poll(ctx context.Context) error {
+	fetchCtx, cancel := context.WithTimeout(ctx, upstreamCheckTimeout)
+	buildID, err := w.fetchBuildID(fetchCtx)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("fetching build id: %w", err)
+	}
+
+	previous := w.buildID
+	if buildID == previous {
+		w.candidate = ""
+		return nil
+	}
+	if previous == "" {
+		w.buildID = buildID
+		log.
+			WithField("component", "deployment").
+			WithField("buildId", buildID).
+			Info("Observed Next.js build")
+		return nil
+	}
+	if buildID != w.candidate {
+		w.candidate = buildID
+		log.
+			WithField("component", "deployment").
+			WithField("buildId", buildID).
+			Debug("Next.js build changed, waiting for confirmation")
+		return nil
+	}
+
+	// The confirmed build is kept as candidate until it was revalidated, so a failed revalidation is retried with the next poll
+	state, err := w.claim(previous, buildID)
+	if err != nil {
+		return fmt.Errorf("claiming build %s: %w", buildID, err)
+	}
+	switch state {
+	case claimPending:
+		log.
+			WithField("component", "deployment").
+			WithField("buildId", buildID).
+			Debug("Next.js build changed, waiting for the revalidation of another replica")
+		return nil
+	case claimDone:
+		w.buildID = buildID
+		w.candidate = ""
+		log.
+			WithField("component", "deployment").
+			WithField("buildId", buildID).
+			Info("Next.js build changed, revalidated by another replica")
+		return nil
+	}
+
+	log.
+		WithField("component", "deployment").
+		WithField("buildId", buildID).
+		WithField("previousBuildId", previous).
+		Info("Next.js build changed, revalidating documents")
+
+	if err := w.h.revalidateSchedule(ctx, w.schedule, SourceDeployment); err != nil {
+		// Retry with the next poll on this or another replica
+		w.release(previous, buildID)
+		return fmt.Errorf("revalidating build %s: %w", buildID, err)
+	}
+	w.buildID = buildID
+	w.candidate = ""
+	w.markDone(previous, buildID)
+
+	w.h.ctrl.events.publish(Event{
+		Type:     EventDeploymentChanged,
+		Schedule: w.schedule.DisplayName(),
+		BuildID:  buildID,
+	})
+	return nil
+}
+
+// fetchBuildID requests the URL and extracts the build identity from the header or body.
+func (w *DeploymentWatcher) fetchBuildID(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.url, nil)
+	if err != nil {
+		return "", fmt.Errorf("building request: %w", err)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var value string
+	if w.header != "" {
+		value = resp.Header.Get(w.header)
+	} else {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxBuildIDSize))
+		if err != nil {
+			return "", fmt.Errorf("reading body: %w", err)
+		}
+		value = string(body)
+	}
+
+	if w.pattern != nil {
+		match := w.pattern.FindStringSubmatch(value)
+		if len(match) < 2 {
+			return "", fmt.Errorf("pattern %s did not match", w.pattern)
+		}
+		value = match[1]
+	}
+
+	buildID := strings.TrimSpace(value)
+	if buildID == "" {
+		return "", errors.New("empty build id")
+	}
+	return buildID, nil
+}
+
+// claimState is the result of claiming the revalidation of a changed build.
+type claimState int
+
+const (
+	// claimAcquired means this replica revalidates the change
+	claimAcquired claimState = iota
+	// claimPending means another replica claimed the change and did not finish its revalidation yet
+	claimPending
+	// claimDone means another replica revalidated the change
+	claimDone
+)
+
+// claim creates an exclusive claim file for the change from the previous build in the claim directory.
+// If another replica already claimed the change, the state tells if its revalidation is done.
+func (w *DeploymentWatcher) claim(previous, buildID string) (claimState, error) {
+	if w.claimDir == "" {
+		return claimAcquired, nil
+	}
+
+	w.removeOldClaims()
+
+	claimFile := w.claimFile(previous, buildID)
+	f, err := os.OpenFile(claimFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		if _, err := os.Stat(doneFile(claimFile)); err == nil {
+			return claimDone, nil
+		}
+		return claimPending, nil
+	}
+	if err != nil {
+		return claimAcquired, fmt.Errorf("creating claim file: %w", err)
+	}
+	defer f.Close()
+
+	// A done file of an earlier claim of the same change (e.g. after a rollback) must not confirm this claim
+	if err := os.Remove(doneFile(claimFile)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return claimAcquired, fmt.Errorf("removing done file: %w", err)
+	}
+
+	hostname, _ := os.Hostname()
+	if _, err := fmt.Fprintf(f, "%s\n%s\n%s\n%s\n", previous, buildID, hostname, time.Now().Format(time.RFC3339)); err != nil {
+		return claimAcquired, fmt.Errorf("writing claim file: %w", err)
+	}
+	return claimAcquired, nil
+}
+
+// markDone creates a done file next to the claim file, so other replicas accept the change without revalidating it.
+// Errors are only logged, other replicas revalidate the change after the claim expired.
+func (w *DeploymentWatcher) markDone(previous, buildID string) {
+	if w.claimDir == "" {
+		return
+	}
+	if err := os.WriteFile(doneFile(w.claimFile(previous, buildID)), []byte(time.Now().Format(time.RFC3339)+"\n"), 0o644); err != nil {
+		log.
+			WithField("component", "deployment").
+			WithField("buildId", buildID).
+			WithError(err).
+			Warn("Marking claim as done failed")
+	}
+}
+
+// release removes the claim file of the change so it can be claimed again.
+func (w *DeploymentWatcher) release(previous, buildID string) {
+	if w.claimDir == "" {
+		return
+	}
+	if err := os.Remove(w.claimFile(previous, buildID)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.
+			WithField("component", "deployment").
+			WithField("buildId", buildID).
+			WithError(err).
+			Warn("Releasing claim failed")
+	}
+}
+
+// claimFile returns the name of the claim file for a change, the build identities are hashed since they can contain any characters.
+func (w *DeploymentWatcher) claimFile(previous, buildID string) string {
+	sum := sha256.Sum256([]byte(previous + "\n" + buildID))
+	return filepath.Join(w.claimDir, "build-"+hex.EncodeToString(sum[:8])+".claim")
+}
+
+// doneFile returns the name of the file that marks the revalidation of a claim as done.
+func doneFile(claimFile string) string {
+	return strings.TrimSuffix(claimFile, ".claim") + ".done"
+}
+
+// removeOldClaims removes claim and done files older than ten intervals, all replicas detect a change long before.
+// This allows claiming the same change again (e.g. after a rollback and another deployment) or after a replica stopped during its revalidation.
+// Errors are only logged since they do not affect claiming.
+func (w *DeploymentWatcher) removeOldClaims() {
+	claims, _ := filepath.Glob(filepath.Join(w.claimDir, "build-*.claim"))
+	done, _ := filepath.Glob(filepath.Join(w.claimDir, "build-*.done"))
+	names := append(claims, done...)
+	for _, name := range names {
+		info, err := os.Stat(name)
+		if err != nil || time.Since(info.ModTime()) < 10*w.interval {
+			continue
+		}
+		if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.
+				WithField("component", "deployment").
+				WithField("file", name).
+				WithError(err).
+				Warn("Removing old claim file failed")
+		}
+	}
+}
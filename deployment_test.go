@@ -0,0 +1,130 @@
+package grazer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func TestDeploymentWatcher_poll(t *testing.T) {
+	var buildID atomic.Value
+	buildID.Store("build-1")
+	next := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/BUILD_ID" {
+			_, _ = w.Write([]byte(buildID.Load().(string) + "\n"))
+		}
+	}))
+	defer next.Close()
+
+	h := NewHandler(HandlerOpts{
+		Revalidator:    NewRevalidator(RevalidatorOpts{URL: next.URL}),
+		DocumentSource: staticDocuments{{RoutePath: "/a"}, {RoutePath: "/b"}},
+	})
+	defer h.ShutdownAndWait()
+
+	events, unsubscribe := h.Subscribe()
+	defer unsubscribe()
+
+	// Two replicas share the claim directory
+	claimDir := t.TempDir()
+	replicas := []*DeploymentWatcher{
+		NewDeploymentWatcher(DeploymentWatcherOpts{Handler: h, URL: next.URL + "/BUILD_ID", ClaimDir: claimDir}),
+		NewDeploymentWatcher(DeploymentWatcherOpts{Handler: h, URL: next.URL + "/BUILD_ID", ClaimDir: claimDir}),
+	}
+	pollAll := func() {
+		for _, w := range replicas {
+			require.NoError(t, w.poll(context.Background()))
+		}
+	}
+	changedEvents := func() (changed []Event) {
+		for {
+			select {
+			case event := <-events:
+				if event.Type == EventDeploymentChanged {
+					changed = append(changed, event)
+				}
+			default:
+				return changed
+			}
+		}
+	}
+
+	// The first build is only recorded
+	pollAll()
+	assert.Equal(t, "build-1", replicas[0].buildID)
+	assert.Empty(t, changedEvents())
+
+	// A changed build must be confirmed by a second poll
+	buildID.Store("build-2")
+	pollAll()
+	assert.Empty(t, changedEvents())
+	pollAll()
+
+	// Only one replica revalidates the change
+	changed := changedEvents()
+	require.Len(t, changed, 1)
+	assert.Equal(t, "build-2", changed[0].BuildID)
+	assert.Equal(t, "deployment", changed[0].Schedule)
+	assert.Equal(t, "build-2", replicas[1].buildID)
+
+	claims, err := os.ReadDir(claimDir)
+	require.NoError(t, err)
+	// The claim file and the done file of the change
+	assert.Len(t, claims, 2)
+
+	// A build that was only observed once during a rolling deployment is ignored
+	buildID.Store("build-3")
+	pollAll()
+	buildID.Store("build-2")
+	pollAll()
+	pollAll()
+	assert.Empty(t, changedEvents())
+
+	// A replica that lost the claim accepts the change only after the revalidation is done
+	buildID.Store("build-4")
+	pollAll()
+	state, err := replicas[0].claim("build-2", "build-4")
+	require.NoError(t, err)
+	require.Equal(t, claimAcquired, state)
+	require.NoError(t, replicas[1].poll(context.Background()))
+	assert.Equal(t, "build-2", replicas[1].buildID)
+
+	// The claim is released after a failed revalidation, so the other replica revalidates the change
+	replicas[0].release("build-2", "build-4")
+	require.NoError(t, replicas[1].poll(context.Background()))
+	assert.Equal(t, "build-4", replicas[1].buildID)
+	require.NoError(t, replicas[0].poll(context.Background()))
+	assert.Equal(t, "build-4", replicas[0].buildID)
+	changed = changedEvents()
+	require.Len(t, changed, 1)
+	assert.Equal(t, "build-4", changed[0].BuildID)
+}
+
+func TestDeploymentWatcher_fetchBuildID(t *testing.T) {
+	next := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Build-Id", "header-build")
+		_, _ = w.Write([]byte(`<script id="__NEXT_DATA__" type="application/json">{"page":"/","buildId":"page-build"}</script>`))
+	}))
+	defer next.Close()
+
+	w := NewDeploymentWatcher(DeploymentWatcherOpts{URL: next.URL, Header: "X-Build-Id"})
+	buildID, err := w.fetchBuildID(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "header-build", buildID)
+
+	w = NewDeploymentWatcher(DeploymentWatcherOpts{URL: next.URL, Pattern: regexp.MustCompile(`"buildId":"([^"]+)"`)})
+	buildID, err = w.fetchBuildID(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "page-build", buildID)
+
+	w = NewDeploymentWatcher(DeploymentWatcherOpts{URL: next.URL, Pattern: regexp.MustCompile(`"version":"([^"]+)"`)})
+	_, err = w.fetchBuildID(context.Background())
+	assert.Error(t, err)
+}
@@ -0,0 +1,255 @@
+package grazer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DocumentSource lists all documents that are revalidated in a full revalidation.
+type DocumentSource interface {
+	ListDocuments(ctx context.Context) (*DocumentsResponse, error)
+}
+
+var (
+	_ DocumentSource = &Fetcher{}
+	_ DocumentSource = &SitemapSource{}
+	_ DocumentSource = &StaticSource{}
+	_ DocumentSource = MultiSource{}
+)
+
+type SitemapSourceOpts struct {
+	// URL of a sitemap.xml or a sitemap index
+	URL     string
+	Timeout time.Duration
+	// MaxSitemaps limits the number of sitemaps fetched for a sitemap index (including the index itself)
+	MaxSitemaps int
+
+	Transport http.RoundTripper
+}
+
+// SitemapSource lists documents from a sitemap.xml or a sitemap index.
+// The route path of a document is the path of the location, lastmod and priority are mapped to the document metadata.
+type SitemapSource struct {
+	url         string
+	maxSitemaps int
+	client      *http.Client
+}
+
+func NewSitemapSource(opts SitemapSourceOpts) *SitemapSource {
+	if opts.Timeout == 0 {
+		opts.Timeout = 15 * time.Second
+	}
+	if opts.MaxSitemaps == 0 {
+		opts.MaxSitemaps = 100
+	}
+
+	return &SitemapSource{
+		url:         opts.URL,
+		maxSitemaps: opts.MaxSitemaps,
+		client: &http.Client{
+			Timeout:   opts.Timeout,
+			Transport: opts.Transport,
+		},
+	}
+}
+
+type sitemapDocument struct {
+	URLs     []sitemapURL `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+type sitemapURL struct {
+	Loc      string `xml:"loc"`
+	LastMod  string `xml:"lastmod"`
+	Priority string `xml:"priority"`
+}
+
+func (s *SitemapSource) ListDocuments(ctx context.Context) (*DocumentsResponse, error) {
+	result := &DocumentsResponse{}
+
+	pending := []string{s.url}
+	fetched := 0
+	for len(pending) > 0 {
+		sitemapURL := pending[0]
+		pending = pending[1:]
+
+		if fetched == s.maxSitemaps {
+			return nil, fmt.Errorf("exceeded maximum number of %d sitemaps", s.maxSitemaps)
+		}
+		fetched++
+
+		sitemap, err := s.fetch(ctx, sitemapURL)
+		if err != nil {
+			return nil, fmt.Errorf("fetching sitemap %s: %w", sitemapURL, err)
+		}
+
+		for _, nested := range sitemap.Sitemaps {
+			pending = append(pending, strings.TrimSpace(nested.Loc))
+		}
+
+		for _, u := range sitemap.URLs {
+			document, err := sitemapURLToDocument(u)
+			if err != nil {
+				return nil, fmt.Errorf("in sitemap %s: %w", sitemapURL, err)
+			}
+			result.Documents = append(result.Documents, document)
+		}
+	}
+
+	return result, nil
+}
+
+func (s *SitemapSource) fetch(ctx context.Context, sitemapURL string) (*sitemapDocument, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sitemapURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building request: %w", err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	// Sitemaps can be served as gzip files (e.g. sitemap.xml.gz)
+	body := bufio.NewReader(resp.Body)
+	var r io.Reader = body
+	if magic, _ := body.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("reading gzip: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	var sitemap sitemapDocument
+	err = xml.NewDecoder(r).Decode(&sitemap)
+	if err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+
+	return &sitemap, nil
+}
+
+func sitemapURLToDocument(u sitemapURL) (DocumentsItem, error) {
+	loc, err := url.Parse(strings.TrimSpace(u.Loc))
+	if err != nil {
+		return DocumentsItem{}, fmt.Errorf("parsing location %q: %w", u.Loc, err)
+	}
+
+	document := DocumentsItem{
+		RoutePath: loc.Path,
+	}
+	if document.RoutePath == "" {
+		document.RoutePath = "/"
+	}
+
+	if lastMod := strings.TrimSpace(u.LastMod); lastMod != "" {
+		// W3C datetime with full date and time or only the date
+		for _, layout := range []string{time.RFC3339, "2006-01-02T15:04Z07:00", "2006-01-02"} {
+			if t, err := time.Parse(layout, lastMod); err == nil {
+				document.LastModified = &t
+				break
+			}
+		}
+	}
+
+	if priority := strings.TrimSpace(u.Priority); priority != "" {
+		p, err := strconv.ParseFloat(priority, 64)
+		if err != nil {
+			return DocumentsItem{}, fmt.Errorf("parsing priority %q of %s: %w", u.Priority, u.Loc, err)
+		}
+		document.Priority = &p
+	}
+
+	return document, nil
+}
+
+// StaticSource lists documents from a file with one route path per line.
+// Empty lines and lines starting with # are ignored. The file is read on every listing, so changes are picked up without a restart.
+type StaticSource struct {
+	filename string
+}
+
+func NewStaticSource(filename string) *StaticSource {
+	return &StaticSource{
+		filename: filename,
+	}
+}
+
+func (s *StaticSource) ListDocuments(_ context.Context) (*DocumentsResponse, error) {
+	content, err := os.ReadFile(s.filename)
+	if err != nil {
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
+
+	result := &DocumentsResponse{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, "/") {
+			return nil, fmt.Errorf("line %d: route path %q must start with /", lineNo, line)
+		}
+		result.Documents = append(result.Documents, DocumentsItem{RoutePath: line})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
+
+	return result, nil
+}
+
+// MultiSource combines the documents of multiple sources.
+// Documents are de-duplicated by route path, the first source listing a route path wins.
+// The listing fails if any source fails, since a partial listing would be detected as removed documents.
+type MultiSource []DocumentSource
+
+func NewMultiSource(sources ...DocumentSource) MultiSource {
+	return sources
+}
+
+func (m MultiSource) ListDocuments(ctx context.Context) (*DocumentsResponse, error) {
+	result := &DocumentsResponse{}
+	seen := make(map[string]struct{})
+
+	for i, source := range m {
+		resp, err := source.ListDocuments(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing documents of source %d (%T): %w", i, source, err)
+		}
+
+		for _, document := range resp.Documents {
+			if _, exists := seen[document.RoutePath]; exists {
+				continue
+			}
+			seen[document.RoutePath] = struct{}{}
+			result.Documents = append(result.Documents, document)
+		}
+	}
+
+	return result, nil
+}
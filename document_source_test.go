@@ -0,0 +1,68 @@
+package grazer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func TestSitemapSource_ListDocuments(t *testing.T) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	mux.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>` + srv.URL + `/sitemap-pages.xml</loc></sitemap>
+</sitemapindex>`))
+	})
+	mux.HandleFunc("/sitemap-pages.xml", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://www.example.com/</loc><priority>1.0</priority></url>
+  <url><loc>https://www.example.com/about</loc><lastmod>2023-02-01</lastmod></url>
+</urlset>`))
+	})
+
+	source := NewSitemapSource(SitemapSourceOpts{
+		URL: srv.URL + "/sitemap.xml",
+	})
+	resp, err := source.ListDocuments(context.Background())
+	require.NoError(t, err)
+
+	require.Len(t, resp.Documents, 2)
+	assert.Equal(t, "/", resp.Documents[0].RoutePath)
+	assert.Equal(t, floatPtr(1), resp.Documents[0].Priority)
+	assert.Equal(t, "/about", resp.Documents[1].RoutePath)
+	require.NotNil(t, resp.Documents[1].LastModified)
+	assert.Equal(t, time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC), *resp.Documents[1].LastModified)
+}
+
+func TestMultiSource_ListDocuments(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "paths.txt")
+	err := os.WriteFile(filename, []byte("# Additional pages\n/search\n\n/about\n"), 0o644)
+	require.NoError(t, err)
+
+	source := NewMultiSource(
+		NewStaticSource(filename),
+		staticDocuments{{RoutePath: "/"}, {RoutePath: "/about", Identifier: "a"}},
+	)
+	resp, err := source.ListDocuments(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, []DocumentsItem{{RoutePath: "/search"}, {RoutePath: "/about"}, {RoutePath: "/"}}, resp.Documents)
+}
+
+type staticDocuments []DocumentsItem
+
+func (s staticDocuments) ListDocuments(context.Context) (*DocumentsResponse, error) {
+	return &DocumentsResponse{Documents: s}, nil
+}
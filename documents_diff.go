@@ -0,0 +1,112 @@
+package grazer
+
+import (
+	"sort"
+	"time"
+)
+
+// DocumentsDiff describes the changes between two consecutive document listings.
+type DocumentsDiff struct {
+	ComputedAt time.Time `json:"computedAt"`
+
+	// Added route paths were not part of the previous listing.
+	Added []string `json:"added"`
+	// Removed route paths are not part of the current listing anymore.
+	Removed []string `json:"removed"`
+	// Renamed documents kept their identifier, but changed their route path.
+	Renamed []RenamedDocument `json:"renamed"`
+}
+
+// RenamedDocument is a document whose route path changed between two listings.
+type RenamedDocument struct {
+	Identifier string `json:"identifier"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+}
+
+// Empty returns true if no changes were detected.
+func (d DocumentsDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Renamed) == 0
+}
+
+// staleRoutePaths returns the route paths that need a revalidation because of the diff.
+// This is every removed path (so Next.js can serve a 404) and both sides of a rename (old path for a redirect or 404, new path to be rendered).
+func (d DocumentsDiff) staleRoutePaths() []string {
+	result := make([]string, 0, len(d.Removed)+2*len(d.Renamed))
+	result = append(result, d.Removed...)
+	for _, renamed := range d.Renamed {
+		result = append(result, renamed.From, renamed.To)
+	}
+	return result
+}
+
+// diffDocuments compares two document listings by route path.
+// Documents with an identifier are checked for renames: if exactly one route path of an identifier disappeared and
+// exactly one appeared, it is reported as a rename instead of a removal and addition.
+// Multiple route paths per identifier (e.g. for content dimensions) are only compared within the same identifier.
+func diffDocuments(previous, current []DocumentsItem) DocumentsDiff {
+	previousPaths := make(map[string]struct{}, len(previous))
+	for _, document := range previous {
+		previousPaths[document.RoutePath] = struct{}{}
+	}
+	currentPaths := make(map[string]struct{}, len(current))
+	for _, document := range current {
+		currentPaths[document.RoutePath] = struct{}{}
+	}
+
+	removedByIdentifier := make(map[string][]string)
+	addedByIdentifier := make(map[string][]string)
+
+	diff := DocumentsDiff{
+		Added:   []string{},
+		Removed: []string{},
+		Renamed: []RenamedDocument{},
+	}
+
+	for _, document := range previous {
+		if _, exists := currentPaths[document.RoutePath]; exists {
+			continue
+		}
+		if document.Identifier != "" {
+			removedByIdentifier[document.Identifier] = append(removedByIdentifier[document.Identifier], document.RoutePath)
+			continue
+		}
+		diff.Removed = append(diff.Removed, document.RoutePath)
+	}
+
+	for _, document := range current {
+		if _, existed := previousPaths[document.RoutePath]; existed {
+			continue
+		}
+		if document.Identifier != "" {
+			addedByIdentifier[document.Identifier] = append(addedByIdentifier[document.Identifier], document.RoutePath)
+			continue
+		}
+		diff.Added = append(diff.Added, document.RoutePath)
+	}
+
+	for identifier, removed := range removedByIdentifier {
+		added := addedByIdentifier[identifier]
+		if len(removed) == 1 && len(added) == 1 {
+			diff.Renamed = append(diff.Renamed, RenamedDocument{
+				Identifier: identifier,
+				From:       removed[0],
+				To:         added[0],
+			})
+			delete(addedByIdentifier, identifier)
+			continue
+		}
+		diff.Removed = append(diff.Removed, removed...)
+	}
+	for _, added := range addedByIdentifier {
+		diff.Added = append(diff.Added, added...)
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Slice(diff.Renamed, func(i, j int) bool {
+		return diff.Renamed[i].From < diff.Renamed[j].From
+	})
+
+	return diff
+}
@@ -0,0 +1,53 @@
+package grazer
+
+import (
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func Test_diffDocuments(t *testing.T) {
+	t.Run("added and removed", func(t *testing.T) {
+		diff := diffDocuments(
+			[]DocumentsItem{{RoutePath: "/home"}, {RoutePath: "/about"}},
+			[]DocumentsItem{{RoutePath: "/home"}, {RoutePath: "/contact"}},
+		)
+
+		assert.Equal(t, []string{"/contact"}, diff.Added)
+		assert.Equal(t, []string{"/about"}, diff.Removed)
+		assert.Empty(t, diff.Renamed)
+		assert.Equal(t, []string{"/about"}, diff.staleRoutePaths())
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		diff := diffDocuments(
+			[]DocumentsItem{{RoutePath: "/home", Identifier: "a"}},
+			[]DocumentsItem{{RoutePath: "/home", Identifier: "a"}},
+		)
+
+		assert.True(t, diff.Empty())
+	})
+
+	t.Run("renamed by identifier", func(t *testing.T) {
+		diff := diffDocuments(
+			[]DocumentsItem{{RoutePath: "/home", Identifier: "a"}, {RoutePath: "/about", Identifier: "b"}},
+			[]DocumentsItem{{RoutePath: "/home", Identifier: "a"}, {RoutePath: "/about-us", Identifier: "b"}},
+		)
+
+		assert.Empty(t, diff.Added)
+		assert.Empty(t, diff.Removed)
+		assert.Equal(t, []RenamedDocument{{Identifier: "b", From: "/about", To: "/about-us"}}, diff.Renamed)
+		assert.Equal(t, []string{"/about", "/about-us"}, diff.staleRoutePaths())
+	})
+
+	t.Run("ambiguous identifier changes are not renames", func(t *testing.T) {
+		diff := diffDocuments(
+			[]DocumentsItem{{RoutePath: "/en/about", Identifier: "b"}, {RoutePath: "/de/ueber", Identifier: "b"}},
+			[]DocumentsItem{{RoutePath: "/en/about-us", Identifier: "b"}},
+		)
+
+		assert.Equal(t, []string{"/en/about-us"}, diff.Added)
+		assert.Equal(t, []string{"/de/ueber", "/en/about"}, diff.Removed)
+		assert.Empty(t, diff.Renamed)
+	})
+}
@@ -0,0 +1,130 @@
+package grazer
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType is the type of a queue event.
+type EventType string
+
+const (
+	// EventEnqueued is published after documents were enqueued for an invalidation or full revalidation.
+	EventEnqueued EventType = "enqueued"
+	// EventBatchStarted is published before a batch of route paths is sent to a target.
+	EventBatchStarted EventType = "batchStarted"
+	// EventPathRevalidated is published for each route path that was revalidated by all targets.
+	EventPathRevalidated EventType = "pathRevalidated"
+	// EventPathFailed is published for each route path that failed to revalidate for a target after all retries.
+	EventPathFailed EventType = "pathFailed"
+	// EventRetried is published before a failed batch is retried.
+	EventRetried EventType = "retried"
+	// EventQueueDrained is published when the queue is empty after processing.
+	EventQueueDrained EventType = "queueDrained"
+	// EventSweepProgress is published after a batch with route paths of a full revalidation was processed.
+	EventSweepProgress EventType = "sweepProgress"
+	// EventQueuePaused is published when dispatching of the queue was paused.
+	EventQueuePaused EventType = "queuePaused"
+	// EventQueueResumed is published when dispatching of the queue was resumed.
+	EventQueueResumed EventType = "queueResumed"
+	// EventSweepDrained is published when the route paths of full revalidations were dropped from the queue.
+	EventSweepDrained EventType = "sweepDrained"
+	// EventQueueCanceled is published when all route paths were dropped from the queue.
+	EventQueueCanceled EventType = "queueCanceled"
+	// EventJobFinished is published when all route paths of an invalidation were revalidated or failed.
+	EventJobFinished EventType = "jobFinished"
+	// EventSweepFinished is published when all route paths of a full revalidation were processed.
+	EventSweepFinished EventType = "sweepFinished"
+	// EventUpstreamUnreachable is published when the health check of the document source or a target starts failing.
+	EventUpstreamUnreachable EventType = "upstreamUnreachable"
+	// EventUpstreamRecovered is published when the health check of an unreachable upstream succeeds again.
+	EventUpstreamRecovered EventType = "upstreamRecovered"
+	// EventDeploymentChanged is published when a changed Next.js build was detected and its revalidation was enqueued.
+	EventDeploymentChanged EventType = "deploymentChanged"
+)
+
+// Event is published by the controller while processing the queue.
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+
+	JobID      string   `json:"jobId,omitempty"`
+	Schedule   string   `json:"schedule,omitempty"`
+	Target     string   `json:"target,omitempty"`
+	RoutePath  string   `json:"routePath,omitempty"`
+	RoutePaths []string `json:"routePaths,omitempty"`
+	// Invalidated and All are the number of enqueued invalidated route paths and route paths of all documents
+	Invalidated int `json:"invalidated,omitempty"`
+	All         int `json:"all,omitempty"`
+	// Dropped is the number of route paths dropped from the queue
+	Dropped int `json:"dropped,omitempty"`
+	// Attempt is the number of the next attempt for a retry
+	Attempt    int            `json:"attempt,omitempty"`
+	DurationMs int64          `json:"durationMs,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	Sweep      *SweepProgress `json:"sweep,omitempty"`
+	// Job is the status of a finished job
+	Job *JobStatus `json:"job,omitempty"`
+	// Upstream is the name of the document source or target of a health check
+	Upstream string `json:"upstream,omitempty"`
+	// BuildID is the identity of a detected Next.js build
+	BuildID string `json:"buildId,omitempty"`
+}
+
+// SweepProgress is the progress of a full revalidation.
+type SweepProgress struct {
+	Total     int `json:"total"`
+	Done      int `json:"done"`
+	Remaining int `json:"remaining"`
+	// Failed is the number of done route paths that failed to revalidate
+	Failed int `json:"failed"`
+}
+
+// eventBroker publishes events to subscribers.
+// Publishing never blocks, events are dropped for subscribers that do not keep up.
+type eventBroker struct {
+	mx          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// subscribe returns a channel receiving all published events and a function to unsubscribe.
+func (b *eventBroker) subscribe(bufferSize int) (<-chan Event, func()) {
+	ch := make(chan Event, bufferSize)
+
+	b.mx.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mx.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mx.Lock()
+			delete(b.subscribers, ch)
+			b.mx.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (b *eventBroker) publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			metrics.Add(metricDroppedEvents, 1)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package grazer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/apex/log"
+)
+
+const eventStreamHeartbeatInterval = 15 * time.Second
+
+// handleEvents streams queue events as Server-Sent Events.
+func (h *Handler) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.authorize(w, r, ScopeInvalidate, ScopeAdminRead); !ok {
+		return
+	}
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, ErrorResponse{
+			Code:    ErrorCodeInternal,
+			Message: "streaming is not supported",
+		})
+		return
+	}
+
+	events, unsubscribe := h.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Disable response buffering of nginx
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	log.
+		WithField("component", "http").
+		WithField("remoteAddr", r.RemoteAddr).
+		Debug("Event stream opened")
+
+	heartbeat := time.NewTicker(eventStreamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	var id uint64
+	for {
+		select {
+		case <-r.Context().Done():
+			log.
+				WithField("component", "http").
+				WithField("remoteAddr", r.RemoteAddr).
+				Debug("Event stream closed")
+			return
+		case <-h.streamsDone:
+			return
+		case <-heartbeat.C:
+			_, err := fmt.Fprint(w, ": heartbeat\n\n")
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			id++
+			_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, event.Type, data)
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// Subscribe returns a channel receiving queue events and a function to unsubscribe.
+// Events are dropped if the receiver does not keep up.
+func (h *Handler) Subscribe() (<-chan Event, func()) {
+	return h.ctrl.events.subscribe(256)
+}
+
+// CloseStreams ends all open event streams, it should be registered with http.Server.RegisterOnShutdown, since
+// a graceful shutdown of the server waits for streams to finish.
+func (h *Handler) CloseStreams() {
+	h.closeStreamsOnce.Do(func() {
+		close(h.streamsDone)
+	})
+}
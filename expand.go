@@ -0,0 +1,79 @@
+package grazer
+
+import (
+	"regexp"
+	"strings"
+)
+
+// expandedPattern reports how many route paths a pattern of an invalidation was expanded to.
+type expandedPattern struct {
+	Pattern string `json:"pattern"`
+	Count   int    `json:"count"`
+}
+
+// isPattern returns true if the document invalidates all documents matching a glob or prefix.
+// Only "*" in the path is a wildcard, "?" starts the query string like in a URL.
+func (d revalidateRequestDocument) isPattern() bool {
+	path, _, _ := strings.Cut(d.RoutePath, "?")
+	return d.RoutePathPrefix != "" || strings.Contains(path, "*")
+}
+
+// routePathGlobToRegexp converts the glob of an invalidated route path to a regular expression, the query string is matched literally.
+func routePathGlobToRegexp(routePath string) string {
+	path, query, hasQuery := strings.Cut(routePath, "?")
+	re := globToRegexp(path)
+	if !hasQuery {
+		return re
+	}
+	return strings.TrimSuffix(re, "$") + regexp.QuoteMeta("?"+query) + "$"
+}
+
+// hasPathPrefix returns true if the route path is the prefix or continues it with a new segment, so "/blog" does not match "/blogger".
+func hasPathPrefix(routePath, prefix string) bool {
+	if !strings.HasPrefix(routePath, prefix) {
+		return false
+	}
+	return len(routePath) == len(prefix) || strings.HasSuffix(prefix, "/") || routePath[len(prefix)] == '/'
+}
+
+// expandInvalidatedDocuments expands glob and prefix patterns of invalidated documents against the listed documents.
+// Documents without a pattern are returned as they are.
+func expandInvalidatedDocuments(invalidatedDocuments []revalidateRequestDocument, documents []DocumentsItem) ([]string, []expandedPattern) {
+	var (
+		routePaths []string
+		expanded   []expandedPattern
+	)
+	for _, invalidated := range invalidatedDocuments {
+		if !invalidated.isPattern() {
+			routePaths = append(routePaths, invalidated.RoutePath)
+			continue
+		}
+
+		var (
+			match   func(routePath string) bool
+			pattern string
+		)
+		if invalidated.RoutePathPrefix != "" {
+			pattern = invalidated.RoutePathPrefix
+			match = func(routePath string) bool {
+				return hasPathPrefix(routePath, invalidated.RoutePathPrefix)
+			}
+		} else {
+			pattern = invalidated.RoutePath
+			match = regexp.MustCompile(routePathGlobToRegexp(invalidated.RoutePath)).MatchString
+		}
+
+		count := 0
+		for _, document := range documents {
+			if match(document.RoutePath) {
+				routePaths = append(routePaths, document.RoutePath)
+				count++
+			}
+		}
+		expanded = append(expanded, expandedPattern{
+			Pattern: pattern,
+			Count:   count,
+		})
+	}
+	return routePaths, expanded
+}
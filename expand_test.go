@@ -0,0 +1,59 @@
+package grazer
+
+import (
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func Test_expandInvalidatedDocuments(t *testing.T) {
+	documents := []DocumentsItem{
+		{RoutePath: "/"},
+		{RoutePath: "/blog"},
+		{RoutePath: "/blog/post-1"},
+		{RoutePath: "/blog/category/news"},
+		{RoutePath: "/blogger"},
+		{RoutePath: "/contact"},
+		{RoutePath: "/search?q=x"},
+		{RoutePath: "/search/x?page=1"},
+	}
+
+	routePaths, expanded := expandInvalidatedDocuments([]revalidateRequestDocument{
+		{RoutePath: "/contact"},
+		{RoutePath: "/blog/*"},
+		{RoutePathPrefix: "/blog/category/"},
+		{RoutePath: "/events/**"},
+		{RoutePathPrefix: "/blog"},
+		{RoutePath: "/blog/post-*"},
+		{RoutePath: "/search?q=x"},
+		{RoutePath: "/search/*?page=1"},
+	}, documents)
+
+	assert.Equal(t, []string{"/contact", "/blog/post-1", "/blog/category/news", "/blog", "/blog/post-1", "/blog/category/news", "/blog/post-1", "/search?q=x", "/search/x?page=1"}, routePaths)
+	assert.Equal(t, []expandedPattern{
+		{Pattern: "/blog/*", Count: 1},
+		{Pattern: "/blog/category/", Count: 1},
+		{Pattern: "/events/**", Count: 0},
+		{Pattern: "/blog", Count: 3},
+		{Pattern: "/blog/post-*", Count: 1},
+		{Pattern: "/search/*?page=1", Count: 1},
+	}, expanded)
+}
+
+func TestRevalidateRequestDocument_isPattern(t *testing.T) {
+	assert.True(t, revalidateRequestDocument{RoutePath: "/blog/*"}.isPattern())
+	assert.True(t, revalidateRequestDocument{RoutePath: "/blog/*?page=1"}.isPattern())
+	assert.True(t, revalidateRequestDocument{RoutePathPrefix: "/blog"}.isPattern())
+	// A query string is not a wildcard
+	assert.False(t, revalidateRequestDocument{RoutePath: "/search?q=x"}.isPattern())
+	assert.False(t, revalidateRequestDocument{RoutePath: "/search?q=*"}.isPattern())
+}
+
+func Test_hasPathPrefix(t *testing.T) {
+	assert.True(t, hasPathPrefix("/blog", "/blog"))
+	assert.True(t, hasPathPrefix("/blog/post-1", "/blog"))
+	assert.True(t, hasPathPrefix("/blog/post-1", "/blog/"))
+	assert.True(t, hasPathPrefix("/blog", "/"))
+	assert.False(t, hasPathPrefix("/blogger", "/blog"))
+	assert.False(t, hasPathPrefix("/blog", "/blog/"))
+}
@@ -3,12 +3,13 @@ package grazer
 import (
 	"bytes"
 	"context"
-	"crypto/subtle"
 	"encoding/json"
+	"errors"
+	"expvar"
 	"fmt"
 	"net/http"
-	"net/http/httputil"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -17,110 +18,573 @@ import (
 )
 
 type HandlerOpts struct {
+	// RevalidateToken is an inbound token with the invalidate scope
 	RevalidateToken string
-
-	Revalidator         *Revalidator
-	Fetcher             *Fetcher
+	// AdminToken is an inbound token with the admin:read and admin:write scopes
+	AdminToken string
+	// Tokens are additional inbound tokens with scopes, admin endpoints are disabled if no token has an admin scope
+	Tokens *TokenSet
+
+	// Revalidator is a single revalidation target, it is prepended to Revalidators if set
+	Revalidator *Revalidator
+	// Revalidators are all revalidation targets, every batch is sent to each target (filtered by path rules)
+	Revalidators []*Revalidator
+	Fetcher      *Fetcher
+	// DocumentSource lists all documents for a full revalidation, the Fetcher is used if nil
+	DocumentSource      DocumentSource
 	RevalidateBatchSize int
+	// RevalidateRetries is the number of retries with exponential backoff for a failed revalidate request
+	RevalidateRetries int
+	// SweepOrder orders documents of a full revalidation (by route path if empty)
+	SweepOrder SweepOrder
+	// SweepPacing spreads a full revalidation over a target duration (not paced if the duration is 0)
+	SweepPacing SweepPacing
+	// Refresh continuously revalidates route paths before they exceed a maximum age (disabled if the maximum age is 0)
+	Refresh RefreshOpts
+	// PathRules include or exclude route paths before they are enqueued
+	PathRules PathRules
+	// MaxRequestBodyBytes limits the size of request bodies (DefaultMaxRequestBodyBytes if 0)
+	MaxRequestBodyBytes int64
+	// History records invalidations and revalidations, an in-memory history is used if nil
+	History *History
+	// Webhooks receive events, they are started with the handler and flushed by ShutdownAndWait
+	Webhooks []*Webhook
+	// Capture records invalidation requests and requests to unknown endpoints (optional)
+	Capture *Capture
 }
 
 type revalidateRequestDocument struct {
-	RoutePath string `json:"routePath"`
+	// RoutePath of the document, a glob (e.g. "/blog/*" or "/blog/**") invalidates all matching documents
+	RoutePath string `json:"routePath,omitempty"`
+	// RoutePathPrefix invalidates all documents with the prefix as route path or as its leading segments
+	RoutePathPrefix string `json:"routePathPrefix,omitempty"`
 }
 
 type revalidateRequestBody struct {
 	Documents []revalidateRequestDocument `json:"documents"`
+	// Priority of the invalidation (PriorityNormal if empty)
+	Priority Priority `json:"priority,omitempty"`
+	// SkipFullRevalidate only enqueues the documents of the invalidation without all other documents
+	SkipFullRevalidate bool `json:"skipFullRevalidate,omitempty"`
+	// NotBefore delays the invalidation until the time
+	NotBefore *time.Time `json:"notBefore,omitempty"`
+}
+
+// Priority of an invalidation, route paths of urgent invalidations are revalidated before normal and low invalidations.
+// Route paths of all invalidations are revalidated before route paths of a full revalidation.
+type Priority string
+
+const (
+	PriorityUrgent Priority = "urgent"
+	PriorityNormal Priority = "normal"
+	PriorityLow    Priority = "low"
+)
+
+func (p Priority) tier() (queueTier, error) {
+	switch p {
+	case PriorityUrgent:
+		return tierUrgent, nil
+	case PriorityNormal, "":
+		return tierNormal, nil
+	case PriorityLow:
+		return tierLow, nil
+	default:
+		return tierNormal, fmt.Errorf("unknown priority %q", p)
+	}
 }
 
+type revalidateResponseBody struct {
+	// JobID identifies the job for querying its status
+	JobID string `json:"jobId"`
+	// Expanded reports the number of route paths for each pattern
+	Expanded []expandedPattern `json:"expanded,omitempty"`
+	// Job is the status of the job if the request waited for the job
+	Job *JobStatus `json:"job,omitempty"`
+}
+
+const (
+	defaultWaitTimeout = 30 * time.Second
+	maxWaitTimeout     = 5 * time.Minute
+	// maxNotBeforeDelay limits the delay of invalidations, since delayed invalidations are only kept in memory
+	maxNotBeforeDelay = 24 * time.Hour
+)
+
 type Handler struct {
-	revalidateToken string
+	// staticTokens are the tokens of the handler options that cannot be reloaded
+	staticTokens []Token
+	tokens       *TokenSet
+
+	maxRequestBodyBytes int64
 
 	ctrl *controller
 
 	mux *http.ServeMux
 
+	streamsDone      chan struct{}
+	closeStreamsOnce sync.Once
+
+	// shutdown is closed to cancel delayed invalidations
+	shutdown chan struct{}
+
+	schedulesMx sync.RWMutex
+	schedules   func() []ScheduleStatus
+
+	webhooks            []*Webhook
+	unsubscribeWebhooks []func()
+
+	capture *Capture
+
+	// refresher is nil if the rolling refresh is disabled
+	refresher *refresher
+
+	upstreamsMx sync.RWMutex
+	// upstreams are the results of the last check of MonitorUpstreams
+	upstreams []UpstreamStatus
+
 	wg sync.WaitGroup
 }
 
 func NewHandler(opts HandlerOpts) *Handler {
-	ctrl := newController(opts.Revalidator, opts.Fetcher)
+	source := opts.DocumentSource
+	if source == nil {
+		source = opts.Fetcher
+	}
+
+	targets := opts.Revalidators
+	if opts.Revalidator != nil {
+		targets = append([]*Revalidator{opts.Revalidator}, targets...)
+	}
+
+	history := opts.History
+	if history == nil {
+		// Cannot fail without a file
+		history, _ = NewHistory(HistoryOpts{})
+	}
+
+	ctrl := newController(targets, source, opts.PathRules, history)
 	if opts.RevalidateBatchSize == 0 {
 		opts.RevalidateBatchSize = 1
 	}
 	ctrl.revalidateBatchSize = opts.RevalidateBatchSize
+	ctrl.revalidateRetries = opts.RevalidateRetries
+	ctrl.queue.setOrder(opts.SweepOrder)
+	if opts.SweepPacing.QuietFactor == 0 {
+		opts.SweepPacing.QuietFactor = 4
+	}
+	ctrl.pacing = opts.SweepPacing
+
+	tokens := opts.Tokens
+	if tokens == nil {
+		tokens = NewTokenSet(nil)
+	}
+	var staticTokens []Token
+	if opts.RevalidateToken != "" {
+		staticTokens = append(staticTokens, Token{
+			Name:   "revalidate-token",
+			Secret: opts.RevalidateToken,
+			Scopes: []Scope{ScopeInvalidate},
+		})
+	}
+	if opts.AdminToken != "" {
+		staticTokens = append(staticTokens, Token{
+			Name:   "admin-token",
+			Secret: opts.AdminToken,
+			Scopes: []Scope{ScopeAdminRead, ScopeAdminWrite},
+		})
+	}
+
+	if opts.MaxRequestBodyBytes == 0 {
+		opts.MaxRequestBodyBytes = DefaultMaxRequestBodyBytes
+	}
 
 	mux := http.NewServeMux()
 	h := &Handler{
-		ctrl:            ctrl,
-		revalidateToken: opts.RevalidateToken,
-		mux:             mux,
+		ctrl:                ctrl,
+		staticTokens:        staticTokens,
+		tokens:              tokens,
+		maxRequestBodyBytes: opts.MaxRequestBodyBytes,
+		mux:                 mux,
+		streamsDone:         make(chan struct{}),
+		shutdown:            make(chan struct{}),
+		capture:             opts.Capture,
 	}
 
-	mux.HandleFunc("/api/revalidate", h.handleRevalidate)
-	mux.HandleFunc("/", h.catchAll)
+	if opts.Refresh.enabled() {
+		ctrl.freshness = newFreshness()
+		h.refresher = newRefresher(ctrl, opts.Refresh)
+		h.wg.Add(1)
+		go func() {
+			defer h.wg.Done()
+			h.refresher.run(h.shutdown)
+		}()
+	}
+
+	for _, webhook := range opts.Webhooks {
+		events, unsubscribe := ctrl.events.subscribe(webhookSubscriberBufferSize)
+		webhook.start(events)
+		h.webhooks = append(h.webhooks, webhook)
+		h.unsubscribeWebhooks = append(h.unsubscribeWebhooks, unsubscribe)
+	}
+
+	mux.HandleFunc("/api/revalidate", h.captured(false, h.handleRevalidate))
+	mux.HandleFunc("/api/full-revalidate", h.handleFullRevalidate)
+	mux.HandleFunc("/api/jobs/", h.handleJob)
+	mux.HandleFunc("/api/events", h.handleEvents)
+	mux.HandleFunc("/api/documents/diff", h.handleDocumentsDiff)
+	mux.HandleFunc("/api/history", h.handleHistory)
+	mux.HandleFunc("/api/history/last", h.handleLastRevalidations)
+	mux.HandleFunc("/api/queue/", h.handleQueueControl)
+	mux.HandleFunc("/api/openapi.json", h.handleOpenAPI)
+	mux.HandleFunc("/debug/vars", h.handleMetrics)
+	mux.HandleFunc("/api/status", h.handleStatus)
+	mux.HandleFunc("/admin/", h.handleDashboard)
+	mux.HandleFunc("/", h.captured(true, h.catchAll))
 
 	return h
 }
 
 func (h *Handler) handleRevalidate(w http.ResponseWriter, r *http.Request) {
-	// Verify Authorization header matches the revalidate token
-	authHeader := r.Header.Get("Authorization")
-	if subtle.ConstantTimeCompare([]byte(authHeader), []byte(fmt.Sprintf("Bearer %s", h.revalidateToken))) != 1 {
+	token, ok := h.authorize(w, r, ScopeInvalidate)
+	if !ok {
+		return
+	}
+
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Parse and validate request body
+	var body revalidateRequestBody
+	if !decodeJSONBody(w, r, h.maxRequestBodyBytes, &body) {
+		return
+	}
+
+	wait, waitTimeout, err := parseWait(r)
+	if err != nil {
 		log.
 			WithField("component", "http").
-			Warn("Invalid revalidate token")
-		w.WriteHeader(http.StatusForbidden)
+			WithError(err).
+			Warn("Invalid wait parameters")
+		writeError(w, http.StatusBadRequest, ErrorResponse{
+			Code:    ErrorCodeInvalidParameter,
+			Message: err.Error(),
+		})
 		return
 	}
 
-	// Parse request body
-	var body revalidateRequestBody
-	err := json.NewDecoder(r.Body).Decode(&body)
+	resp, job, errResp := h.invalidate(r.Context(), body, revalidateRequest{
+		source:     SourceAPI,
+		remoteAddr: r.RemoteAddr,
+		token:      token.Name,
+	})
+	if errResp != nil {
+		status := http.StatusBadRequest
+		if errResp.Code == ErrorCodeUpstreamFailed {
+			status = http.StatusBadGateway
+		}
+		writeError(w, status, *errResp)
+		return
+	}
+
+	status := http.StatusOK
+	if wait {
+		select {
+		case <-job.done:
+		case <-time.After(waitTimeout):
+			// The job is still running, the client can query the status with the job ID
+			status = http.StatusAccepted
+		case <-r.Context().Done():
+			return
+		}
+		resp.Job = h.ctrl.jobs.status(job.id)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// invalidate validates an invalidation and creates a job for it.
+// The source, remote address and token of req are kept, all other fields are set from the body.
+// Patterns are expanded synchronously unless the invalidation is delayed, all other invalidations are revalidated in the background.
+func (h *Handler) invalidate(ctx context.Context, body revalidateRequestBody, req revalidateRequest) (revalidateResponseBody, *job, *ErrorResponse) {
+	// Without documents all documents are revalidated, which is pointless if the full revalidation is skipped
+	if len(body.Documents) == 0 && body.SkipFullRevalidate {
+		return revalidateResponseBody{}, nil, &ErrorResponse{
+			Code:    ErrorCodeInvalidDocuments,
+			Message: "documents must not be empty if skipFullRevalidate is set",
+		}
+	}
+	if documentErrors := body.validate(); len(documentErrors) > 0 {
+		log.
+			WithField("component", "http").
+			WithField("source", req.source).
+			WithField("token", req.token).
+			WithField("invalidDocuments", len(documentErrors)).
+			Warn("Invalid documents in revalidate request")
+		return revalidateResponseBody{}, nil, &ErrorResponse{
+			Code:      ErrorCodeInvalidDocuments,
+			Message:   fmt.Sprintf("%d of %d documents are invalid", len(documentErrors), len(body.Documents)),
+			Documents: documentErrors,
+		}
+	}
+
+	tier, err := body.Priority.tier()
+	if err != nil {
+		return revalidateResponseBody{}, nil, &ErrorResponse{
+			Code:    ErrorCodeInvalidBody,
+			Message: fmt.Sprintf("invalid priority: %v", err),
+		}
+	}
+	var notBefore time.Time
+	if body.NotBefore != nil {
+		notBefore = *body.NotBefore
+		if time.Until(notBefore) > maxNotBeforeDelay {
+			return revalidateResponseBody{}, nil, &ErrorResponse{
+				Code:    ErrorCodeInvalidBody,
+				Message: fmt.Sprintf("notBefore must not be more than %s in the future", maxNotBeforeDelay),
+			}
+		}
+	}
+
+	job := h.ctrl.jobs.create()
+	req.invalidatedDocuments = body.Documents
+	req.job = job
+	req.tier = tier
+	req.skipFullRevalidate = body.SkipFullRevalidate
+	resp := revalidateResponseBody{
+		JobID: job.id,
+	}
+
+	// Patterns are expanded against the current documents, so the number of matching route paths can be reported.
+	// Patterns of delayed invalidations are expanded when the invalidation is due.
+	if hasPatterns(body.Documents) && !time.Now().Before(notBefore) {
+		log.
+			WithField("component", "http").
+			WithField("jobId", job.id).
+			Info("Revalidating invalidated documents with patterns")
+
+		result, err := h.ctrl.revalidate(ctx, req)
+		if err != nil {
+			log.
+				WithField("component", "http").
+				WithField("jobId", job.id).
+				WithError(err).
+				Warn("Revalidate failed")
+			return revalidateResponseBody{}, nil, &ErrorResponse{
+				Code:    ErrorCodeUpstreamFailed,
+				Message: err.Error(),
+			}
+		}
+		resp.Expanded = result.expanded
+	} else {
+		h.revalidateInBackground(req, notBefore)
+	}
+
+	return resp, job, nil
+}
+
+// handleFullRevalidate lists and enqueues all documents, it responds after the documents were enqueued.
+func (h *Handler) handleFullRevalidate(w http.ResponseWriter, r *http.Request) {
+	token, ok := h.authorize(w, r, ScopeFullRevalidate, ScopeAdminWrite)
+	if !ok {
+		return
+	}
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	log.
+		WithField("component", "http").
+		WithField("token", token.Name).
+		Info("Revalidating all documents")
+
+	_, err := h.ctrl.revalidate(r.Context(), revalidateRequest{
+		source:     SourceAPI,
+		remoteAddr: r.RemoteAddr,
+		token:      token.Name,
+	})
 	if err != nil {
 		log.
 			WithField("component", "http").
 			WithError(err).
-			Warn("Decoding revalidate request body")
-		w.WriteHeader(http.StatusBadRequest)
+			Warn("Full revalidate failed")
+		writeError(w, http.StatusBadGateway, ErrorResponse{
+			Code:    ErrorCodeUpstreamFailed,
+			Message: err.Error(),
+		})
 		return
 	}
 
-	// Start revalidation in background
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// parseWait parses the optional wait and timeout query parameters.
+func parseWait(r *http.Request) (wait bool, timeout time.Duration, err error) {
+	query := r.URL.Query()
+	if v := query.Get("wait"); v != "" {
+		wait, err = strconv.ParseBool(v)
+		if err != nil {
+			return false, 0, fmt.Errorf("parsing wait: %w", err)
+		}
+	}
+
+	timeout = defaultWaitTimeout
+	if v := query.Get("timeout"); v != "" {
+		timeout, err = time.ParseDuration(v)
+		if err != nil {
+			return false, 0, fmt.Errorf("parsing timeout: %w", err)
+		}
+		if timeout <= 0 || timeout > maxWaitTimeout {
+			return false, 0, fmt.Errorf("timeout must be between 0 and %s", maxWaitTimeout)
+		}
+	}
+
+	return wait, timeout, nil
+}
+
+// revalidateInBackground revalidates the request after notBefore (immediately if zero).
+// Delayed invalidations are canceled on shutdown or if the queue is canceled.
+func (h *Handler) revalidateInBackground(req revalidateRequest, notBefore time.Time) {
+	var canceled <-chan struct{}
+	delay := time.Until(notBefore)
+	if delay > 0 {
+		// Registered before returning, so a cancel right after the response drops it
+		canceled = h.ctrl.delay(req)
+	}
+
 	h.wg.Add(1)
 	go func() {
 		defer h.wg.Done()
 
+		if delay > 0 {
+			log.
+				WithField("component", "http").
+				WithField("jobId", req.job.id).
+				WithField("notBefore", notBefore).
+				Info("Delaying invalidation")
+
+			timer := time.NewTimer(delay)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-canceled:
+			case <-h.shutdown:
+			}
+			// The invalidation is dropped if the queue was canceled, even if the timer fired at the same time
+			if !h.ctrl.undelay(req.job) {
+				log.
+					WithField("component", "http").
+					WithField("jobId", req.job.id).
+					Info("Canceled delayed invalidation")
+				return
+			}
+			select {
+			case <-h.shutdown:
+				log.
+					WithField("component", "http").
+					WithField("jobId", req.job.id).
+					Warn("Canceled delayed invalidation on shutdown")
+				h.ctrl.jobs.fail(req.job, errors.New("canceled on shutdown before notBefore"))
+				return
+			default:
+			}
+		}
+
 		log.
 			WithField("component", "http").
+			WithField("jobId", req.job.id).
 			Info("Revalidating invalidated documents")
 
 		start := time.Now()
 
 		ctx := context.Background()
 		// TODO Add retry with backoff for some duration (e.g. 1 minute)
-		err := h.ctrl.revalidate(ctx, body.Documents)
+		_, err := h.ctrl.revalidate(ctx, req)
 		if err != nil {
 			log.
 				WithField("component", "http").
+				WithField("jobId", req.job.id).
 				WithError(err).
 				Warn("Revalidate failed")
 		}
 
 		log.
 			WithField("component", "http").
+			WithField("jobId", req.job.id).
 			WithDuration(time.Since(start)).
 			Info("Revalidate finished")
 	}()
+}
 
-	w.WriteHeader(http.StatusOK)
+func (h *Handler) handleJob(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.authorize(w, r, ScopeInvalidate, ScopeAdminRead); !ok {
+		return
+	}
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/jobs/")
+	status := h.ctrl.jobs.status(id)
+	if status == nil {
+		writeError(w, http.StatusNotFound, ErrorResponse{
+			Code:    ErrorCodeNotFound,
+			Message: fmt.Sprintf("job %q not found", id),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(status)
+}
+
+func hasPatterns(documents []revalidateRequestDocument) bool {
+	for _, document := range documents {
+		if document.isPattern() {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Handler) handleDocumentsDiff(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.authorize(w, r, ScopeInvalidate, ScopeAdminRead); !ok {
+		return
+	}
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	diff := h.ctrl.lastDocumentsDiff()
+	if diff == nil {
+		// No diff was computed yet (at least two listings are needed)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(diff)
+}
+
+func (h *Handler) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.authorize(w, r, ScopeInvalidate, ScopeAdminRead); !ok {
+		return
+	}
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	expvar.Handler().ServeHTTP(w, r)
 }
 
+// catchAll answers requests to unknown endpoints, they are recorded if enabled for the capture.
 func (h *Handler) catchAll(w http.ResponseWriter, r *http.Request) {
-	dump, _ := httputil.DumpRequest(r, true)
 	log.
 		WithField("component", "http").
+		WithField("method", r.Method).
 		WithField("url", r.URL).
-		Debug(string(dump))
+		Debug("Request to unknown endpoint")
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -130,37 +594,82 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) ShutdownAndWait() {
-	h.ctrl.shutdownAndWait()
+	h.CloseStreams()
+	close(h.shutdown)
+	// Wait for background invalidations before the controller stops, so nothing is enqueued afterwards
 	h.wg.Wait()
+	h.ctrl.shutdownAndWait()
+	// Keep the revalidations of the last batches
+	if h.refresher != nil {
+		h.refresher.saveState()
+	}
+	// Deliver the events of the last revalidations
+	for _, unsubscribe := range h.unsubscribeWebhooks {
+		unsubscribe()
+	}
+	for _, webhook := range h.webhooks {
+		webhook.wait()
+	}
 }
 
 func (h *Handler) FullRevalidate(ctx context.Context) error {
-	return h.ctrl.revalidate(ctx, nil)
+	_, err := h.ctrl.revalidate(ctx, revalidateRequest{source: SourceManual})
+	return err
+}
+
+// FullRevalidateSchedule performs a full revalidation triggered by the named schedule, path rules scoped to the schedule are applied.
+func (h *Handler) FullRevalidateSchedule(ctx context.Context, schedule string) error {
+	return h.RevalidateSchedule(ctx, Schedule{Name: schedule})
+}
+
+// LastDocumentsDiff returns the last non-empty diff between two document listings or nil if none was detected yet.
+func (h *Handler) LastDocumentsDiff() *DocumentsDiff {
+	return h.ctrl.lastDocumentsDiff()
 }
 
+// DefaultRevalidatorName is the name of a revalidation target if no name was set.
+const DefaultRevalidatorName = "next"
+
 type RevalidatorOpts struct {
+	// Name of the revalidation target for scoping path rules
+	Name            string
 	URL             string
 	RevalidateToken string
 	Timeout         time.Duration
+	// DryRun only logs revalidate requests instead of sending them
+	DryRun bool
+	// RateLimit is the maximum number of revalidate requests per second (unlimited if 0)
+	RateLimit float64
 
 	Transport http.RoundTripper
 }
 
 type Revalidator struct {
+	name            string
 	url             string
 	revalidateToken string
+	dryRun          bool
+	rateLimit       float64
 
-	client *http.Client
+	limiter *rateLimiter
+	client  *http.Client
 }
 
 func NewRevalidator(opts RevalidatorOpts) *Revalidator {
 	if opts.Timeout == 0 {
 		opts.Timeout = 10 * time.Second
 	}
+	if opts.Name == "" {
+		opts.Name = DefaultRevalidatorName
+	}
 
 	return &Revalidator{
+		name:            opts.Name,
 		url:             opts.URL,
 		revalidateToken: opts.RevalidateToken,
+		dryRun:          opts.DryRun,
+		rateLimit:       opts.RateLimit,
+		limiter:         newRateLimiter(opts.RateLimit),
 		client: &http.Client{
 			Timeout:   opts.Timeout,
 			Transport: opts.Transport,
@@ -168,6 +677,21 @@ func NewRevalidator(opts RevalidatorOpts) *Revalidator {
 	}
 }
 
+// Name returns the name of the revalidation target.
+func (r *Revalidator) Name() string {
+	return r.name
+}
+
+// DryRun returns true if revalidate requests are only logged.
+func (r *Revalidator) DryRun() bool {
+	return r.dryRun
+}
+
+// RateLimit returns the maximum number of revalidate requests per second (unlimited if 0).
+func (r *Revalidator) RateLimit() float64 {
+	return r.rateLimit
+}
+
 func (r *Revalidator) Revalidate(ctx context.Context, routePaths []string) error {
 	documents := make([]revalidateRequestDocument, len(routePaths))
 	for i, routePath := range routePaths {
@@ -184,6 +708,20 @@ func (r *Revalidator) Revalidate(ctx context.Context, routePaths []string) error
 		return fmt.Errorf("encoding request body: %w", err)
 	}
 
+	if err := r.limiter.wait(ctx); err != nil {
+		return err
+	}
+
+	if r.dryRun {
+		log.
+			WithField("component", "revalidator").
+			WithField("target", r.name).
+			WithField("url", r.url).
+			WithField("body", strings.TrimSpace(body.String())).
+			Info("Dry run, not sending revalidate request")
+		return nil
+	}
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, &body)
 	if err != nil {
 		return fmt.Errorf("building request: %w", err)
@@ -237,6 +775,20 @@ func NewFetcher(opts FetcherOpts) *Fetcher {
 
 type DocumentsItem struct {
 	RoutePath string `json:"routePath"`
+	// Identifier of the document (node aggregate identifier), optional and used to detect renamed documents
+	Identifier string `json:"identifier,omitempty"`
+
+	// Optional metadata for ordering documents in a full revalidation (see SweepOrder)
+
+	// Priority is a weight of the document, a higher value is more important
+	Priority     *float64   `json:"priority,omitempty"`
+	NodeType     string     `json:"nodeType,omitempty"`
+	Depth        *int       `json:"depth,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+}
+
+func (d DocumentsItem) hasMetadata() bool {
+	return d.Priority != nil || d.NodeType != "" || d.Depth != nil || d.LastModified != nil
 }
 
 type DocumentsResponse struct {
@@ -244,32 +796,9 @@ type DocumentsResponse struct {
 }
 
 func (f *Fetcher) ListDocuments(ctx context.Context) (*DocumentsResponse, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/neos/content-api/documents", f.neosBaseURL), nil)
+	req, err := f.newDocumentsRequest(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("building request: %w", err)
-	}
-
-	// Set proxy headers according to the public base URL (if set)
-	if f.publicBaseURL != "" {
-		u, err := url.Parse(f.publicBaseURL)
-		if err != nil {
-			return nil, fmt.Errorf("parsing public base URL: %w", err)
-		}
-		req.Header.Set("X-Forwarded-Host", u.Host)
-
-		port := u.Port()
-		if port == "" {
-			// Use default port according to scheme
-			switch u.Scheme {
-			case "http":
-				port = "80"
-			case "https":
-				port = "443"
-			}
-		}
-		req.Header.Set("X-Forwarded-Port", port)
-
-		req.Header.Set("X-Forwarded-Proto", u.Scheme)
+		return nil, err
 	}
 
 	resp, err := f.client.Do(req)
@@ -278,6 +807,10 @@ func (f *Fetcher) ListDocuments(ctx context.Context) (*DocumentsResponse, error)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
 	var result DocumentsResponse
 	err = json.NewDecoder(resp.Body).Decode(&result)
 	if err != nil {
@@ -287,163 +820,35 @@ func (f *Fetcher) ListDocuments(ctx context.Context) (*DocumentsResponse, error)
 	return &result, nil
 }
 
-type controller struct {
-	mx sync.Mutex
-
-	revalidateBatchSize int
-
-	revalidator *Revalidator
-	fetcher     *Fetcher
-
-	queue *queue
-	sig   chan struct{}
-	wg    sync.WaitGroup
-}
-
-func newController(revalidator *Revalidator, fetcher *Fetcher) *controller {
-	ctrl := &controller{
-		revalidateBatchSize: 1,
-
-		revalidator: revalidator,
-		fetcher:     fetcher,
-
-		queue: newQueue(),
-		sig:   make(chan struct{}),
-	}
-
-	ctrl.wg.Add(1)
-	go ctrl.run()
-
-	return ctrl
-}
-
-func (c *controller) revalidate(ctx context.Context, invalidatedDocuments []revalidateRequestDocument) error {
-	c.mx.Lock()
-	defer c.mx.Unlock()
-
-	documentsResponse, err := c.fetcher.ListDocuments(ctx)
+// newDocumentsRequest builds a request for the documents of the Neos content API.
+func (f *Fetcher) newDocumentsRequest(ctx context.Context) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/neos/content-api/documents", f.neosBaseURL), nil)
 	if err != nil {
-		return fmt.Errorf("listing documents: %w", err)
-	}
-
-	currentRoutePaths := make(map[string]struct{}, len(invalidatedDocuments)+len(documentsResponse.Documents))
-
-	// Store route paths that were given for revalidation as known route paths
-	invalidatedRoutePaths := make([]string, len(invalidatedDocuments))
-	for i, document := range invalidatedDocuments {
-		invalidatedRoutePaths[i] = document.RoutePath
-
-		currentRoutePaths[document.RoutePath] = struct{}{}
-	}
-
-	// Store all route paths that were fetched as known route paths
-	allRoutePaths := make([]string, len(documentsResponse.Documents))
-	for i, document := range documentsResponse.Documents {
-		allRoutePaths[i] = document.RoutePath
-
-		currentRoutePaths[document.RoutePath] = struct{}{}
+		return nil, fmt.Errorf("building request: %w", err)
 	}
 
-	log.
-		WithField("component", "controller").
-		WithField("invalidatedRoutePaths", strings.Join(invalidatedRoutePaths, ",")).
-		WithField("allRoutePaths", strings.Join(allRoutePaths, ",")).
-		Debug("Enqueuing route paths")
-
-	c.queue.enqueue(invalidatedRoutePaths, allRoutePaths)
-
-	c.ensureProcessQueue()
-
-	return nil
-}
-
-func (c *controller) shutdownAndWait() {
-	close(c.sig)
-	c.wg.Wait()
-}
-
-func (c *controller) run() {
-	defer c.wg.Done()
-
-	for {
-		// Wait for signal to process the queue or a close of the channel
-		_, ok := <-c.sig
-		// The channel was closed
-		if !ok {
-			log.
-				WithField("component", "controller").
-				Debug("Returning from run loop")
-			return
+	// Set proxy headers according to the public base URL (if set)
+	if f.publicBaseURL != "" {
+		u, err := url.Parse(f.publicBaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("parsing public base URL: %w", err)
 		}
+		req.Header.Set("X-Forwarded-Host", u.Host)
 
-		for {
-			// Check if channel was closed while processing the queue
-			select {
-			case _, ok := <-c.sig:
-				if !ok {
-					log.
-						WithField("component", "controller").
-						Debug("Returning from run loop, stop processing the queue")
-					return
-				}
-			default:
-			}
-
-			routePaths := c.queuePopBatch()
-			if len(routePaths) == 0 {
-				log.
-					WithField("component", "controller").
-					Debug("Queue is empty, stop processing")
-				break
-			}
-
-			log.
-				WithField("component", "controller").
-				WithField("routePaths", routePaths).
-				Info("Sending revalidate request")
-
-			start := time.Now()
-
-			ctx := context.Background()
-			// TODO Add retry handling around this call
-			err := c.revalidator.Revalidate(ctx, routePaths)
-			if err != nil {
-				log.
-					WithField("component", "controller").
-					WithField("routePaths", routePaths).
-					WithError(err).
-					Error("Revalidate failed")
+		port := u.Port()
+		if port == "" {
+			// Use default port according to scheme
+			switch u.Scheme {
+			case "http":
+				port = "80"
+			case "https":
+				port = "443"
 			}
-
-			log.
-				WithField("component", "controller").
-				WithField("routePaths", strings.Join(routePaths, ",")).
-				WithDuration(time.Since(start)).
-				Debug("Revalidate finished")
 		}
-	}
-}
+		req.Header.Set("X-Forwarded-Port", port)
 
-func (c *controller) ensureProcessQueue() {
-	select {
-	case c.sig <- struct{}{}:
-		// Signal was sent
-	default:
-		// Signal was already sent
+		req.Header.Set("X-Forwarded-Proto", u.Scheme)
 	}
-}
 
-func (c *controller) queuePopBatch() []string {
-	var result []string
-	for {
-		s := c.queue.pop()
-		if s == nil {
-			break
-		}
-		result = append(result, *s)
-		if len(result) == c.revalidateBatchSize {
-			break
-		}
-	}
-	return result
+	return req, nil
 }
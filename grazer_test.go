@@ -0,0 +1,327 @@
+package grazer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func TestHandler_revalidate(t *testing.T) {
+	var (
+		mx                   sync.Mutex
+		revalidatedPathSlice []string
+	)
+	next := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body revalidateRequestBody
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mx.Lock()
+		for _, document := range body.Documents {
+			revalidatedPathSlice = append(revalidatedPathSlice, document.RoutePath)
+		}
+		mx.Unlock()
+	}))
+	defer next.Close()
+
+	h := NewHandler(HandlerOpts{
+		RevalidateToken: "a-token",
+		Revalidator: NewRevalidator(RevalidatorOpts{
+			URL:             next.URL,
+			RevalidateToken: "a-token",
+		}),
+		DocumentSource: staticDocuments{{RoutePath: "/"}, {RoutePath: "/blog/post-1"}, {RoutePath: "/blog/post-2"}},
+	})
+	defer h.ShutdownAndWait()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/revalidate?wait=true", strings.NewReader(`{"documents":[{"routePath":"/blog/*"}]}`))
+	req.Header.Set("Authorization", "Bearer a-token")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp revalidateResponseBody
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+
+	assert.NotEmpty(t, resp.JobID)
+	assert.Equal(t, []expandedPattern{{Pattern: "/blog/*", Count: 2}}, resp.Expanded)
+	require.NotNil(t, resp.Job)
+	assert.Equal(t, JobStateDone, resp.Job.State)
+	assert.Len(t, resp.Job.Paths, 2)
+
+	mx.Lock()
+	defer mx.Unlock()
+	// Invalidated route paths are revalidated before all other documents
+	assert.Equal(t, []string{"/blog/post-1", "/blog/post-2"}, revalidatedPathSlice[:2])
+}
+
+// switchingDocuments lists the documents that were stored last.
+type switchingDocuments struct {
+	documents atomic.Value
+}
+
+func (s *switchingDocuments) ListDocuments(context.Context) (*DocumentsResponse, error) {
+	return &DocumentsResponse{Documents: s.documents.Load().([]DocumentsItem)}, nil
+}
+
+func TestHandler_revalidateRemovedDocuments(t *testing.T) {
+	var (
+		mx                   sync.Mutex
+		revalidatedPathSlice []string
+	)
+	next := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body revalidateRequestBody
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mx.Lock()
+		for _, document := range body.Documents {
+			revalidatedPathSlice = append(revalidatedPathSlice, document.RoutePath)
+		}
+		mx.Unlock()
+	}))
+	defer next.Close()
+
+	source := &switchingDocuments{}
+	source.documents.Store([]DocumentsItem{{RoutePath: "/"}, {RoutePath: "/about"}, {RoutePath: "/old"}})
+
+	h := NewHandler(HandlerOpts{
+		Revalidator:    NewRevalidator(RevalidatorOpts{URL: next.URL}),
+		DocumentSource: source,
+	})
+	defer h.ShutdownAndWait()
+
+	events, unsubscribe := h.Subscribe()
+	defer unsubscribe()
+
+	fullRevalidate := func() (enqueued Event) {
+		t.Helper()
+
+		mx.Lock()
+		revalidatedPathSlice = nil
+		mx.Unlock()
+
+		require.NoError(t, h.FullRevalidate(context.Background()))
+		for event := range events {
+			if event.Type == EventEnqueued {
+				enqueued = event
+			}
+			if event.Type == EventQueueDrained {
+				return enqueued
+			}
+		}
+		return enqueued
+	}
+
+	// The first listing has nothing to compare with
+	enqueued := fullRevalidate()
+	assert.Equal(t, 0, enqueued.Invalidated)
+	assert.Equal(t, 3, enqueued.All)
+
+	// The removed route path is not listed anymore, but enqueued like an invalidated route path
+	source.documents.Store([]DocumentsItem{{RoutePath: "/"}, {RoutePath: "/about"}})
+	enqueued = fullRevalidate()
+	assert.Equal(t, 1, enqueued.Invalidated)
+	assert.Equal(t, 2, enqueued.All)
+
+	mx.Lock()
+	assert.Equal(t, []string{"/old", "/", "/about"}, revalidatedPathSlice)
+	mx.Unlock()
+
+	diff := h.LastDocumentsDiff()
+	require.NotNil(t, diff)
+	assert.Equal(t, []string{"/old"}, diff.Removed)
+}
+
+func TestHandler_Subscribe(t *testing.T) {
+	next := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body revalidateRequestBody
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body.Documents[0].RoutePath == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer next.Close()
+
+	h := NewHandler(HandlerOpts{
+		Revalidator: NewRevalidator(RevalidatorOpts{
+			URL: next.URL,
+		}),
+		DocumentSource:    staticDocuments{{RoutePath: "/"}, {RoutePath: "/broken"}},
+		RevalidateRetries: 1,
+		AdminToken:        "admin-token",
+	})
+	defer h.ShutdownAndWait()
+
+	events, unsubscribe := h.Subscribe()
+	defer unsubscribe()
+
+	require.NoError(t, h.FullRevalidate(context.Background()))
+
+	var (
+		types         []EventType
+		sweepFinished *SweepProgress
+	)
+	for event := range events {
+		types = append(types, event.Type)
+		if event.Type == EventSweepFinished {
+			sweepFinished = event.Sweep
+		}
+		if event.Type == EventQueueDrained {
+			break
+		}
+	}
+
+	assert.Equal(t, []EventType{
+		EventEnqueued,
+		EventBatchStarted, EventPathRevalidated, EventSweepProgress,
+		EventBatchStarted, EventRetried, EventPathFailed, EventSweepProgress, EventSweepFinished,
+		EventQueueDrained,
+	}, types)
+	require.NotNil(t, sweepFinished)
+	assert.Equal(t, SweepProgress{Total: 2, Done: 2, Failed: 1}, *sweepFinished)
+
+	// Revalidations are recorded in the history
+	req := httptest.NewRequest(http.MethodGet, "/api/history?routePath=/broken&outcome=failure", nil)
+	req.Header.Set("Authorization", "Bearer admin-token")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var records []HistoryRecord
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&records))
+	require.Len(t, records, 1)
+	assert.Equal(t, HistoryRevalidation, records[0].Type)
+	assert.Equal(t, DefaultRevalidatorName, records[0].Target)
+	assert.Equal(t, 2, records[0].Attempts)
+	assert.NotEmpty(t, records[0].Error)
+}
+
+func TestHandler_revalidateHints(t *testing.T) {
+	var (
+		mx                   sync.Mutex
+		revalidatedPathSlice []string
+	)
+	next := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body revalidateRequestBody
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mx.Lock()
+		for _, document := range body.Documents {
+			revalidatedPathSlice = append(revalidatedPathSlice, document.RoutePath)
+		}
+		mx.Unlock()
+	}))
+	defer next.Close()
+
+	h := NewHandler(HandlerOpts{
+		RevalidateToken: "a-token",
+		Revalidator: NewRevalidator(RevalidatorOpts{
+			URL: next.URL,
+		}),
+		DocumentSource: staticDocuments{{RoutePath: "/"}, {RoutePath: "/import/1"}, {RoutePath: "/import/2"}},
+	})
+	defer h.ShutdownAndWait()
+
+	do := func(body string) revalidateResponseBody {
+		req := httptest.NewRequest(http.MethodPost, "/api/revalidate?wait=true&timeout=5s", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer a-token")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var resp revalidateResponseBody
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		return resp
+	}
+
+	resp := do(`{"documents":[{"routePath":"/import/1"},{"routePath":"/import/2"}],"priority":"low","skipFullRevalidate":true}`)
+	assert.Equal(t, JobStateDone, resp.Job.State)
+
+	notBefore := time.Now().Add(50 * time.Millisecond)
+	resp = do(`{"documents":[{"routePath":"/import/1"}],"skipFullRevalidate":true,"notBefore":"` + notBefore.Format(time.RFC3339Nano) + `"}`)
+	assert.Equal(t, JobStateDone, resp.Job.State)
+	assert.False(t, resp.Job.FinishedAt.Before(notBefore))
+
+	mx.Lock()
+	defer mx.Unlock()
+	// No full revalidation was triggered
+	assert.Equal(t, []string{"/import/1", "/import/2", "/import/1"}, revalidatedPathSlice)
+}
+
+func TestRevalidator_dryRun(t *testing.T) {
+	called := false
+	next := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer next.Close()
+
+	h := NewHandler(HandlerOpts{
+		Revalidator: NewRevalidator(RevalidatorOpts{
+			URL:    next.URL,
+			DryRun: true,
+		}),
+		DocumentSource: staticDocuments{{RoutePath: "/"}},
+	})
+	defer h.ShutdownAndWait()
+
+	events, unsubscribe := h.Subscribe()
+	defer unsubscribe()
+
+	require.NoError(t, h.FullRevalidate(context.Background()))
+	for event := range events {
+		if event.Type == EventQueueDrained {
+			break
+		}
+	}
+
+	assert.False(t, called)
+
+	records := h.ctrl.history.Query(HistoryQuery{Type: HistoryRevalidation})
+	require.Len(t, records, 1)
+	assert.True(t, records[0].DryRun)
+	assert.Equal(t, OutcomeSuccess, records[0].Outcome)
+	assert.Equal(t, []string{"/"}, records[0].RoutePaths)
+}
+
+// blockingDocuments lists documents after release was closed.
+type blockingDocuments struct {
+	listing chan struct{}
+	release chan struct{}
+}
+
+func (s blockingDocuments) ListDocuments(context.Context) (*DocumentsResponse, error) {
+	s.listing <- struct{}{}
+	<-s.release
+	return &DocumentsResponse{Documents: []DocumentsItem{{RoutePath: "/"}}}, nil
+}
+
+func TestHandler_revalidateDuringShutdown(t *testing.T) {
+	source := blockingDocuments{
+		listing: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	h := NewHandler(HandlerOpts{
+		Revalidator:    NewRevalidator(RevalidatorOpts{URL: "http://localhost", DryRun: true}),
+		DocumentSource: source,
+	})
+
+	// A revalidation that is listing documents while the controller shuts down must not signal the stopped run loop
+	revalidated := make(chan error)
+	go func() {
+		revalidated <- h.FullRevalidate(context.Background())
+	}()
+	<-source.listing
+	h.ShutdownAndWait()
+	close(source.release)
+	require.NoError(t, <-revalidated)
+
+	// New work is refused after the shutdown
+	require.ErrorIs(t, h.FullRevalidate(context.Background()), errStopped)
+}
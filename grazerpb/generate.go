@@ -0,0 +1,4 @@
+// Package grazerpb contains the generated protobuf messages and gRPC service of the grazer gRPC API.
+package grazerpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative grazer.proto
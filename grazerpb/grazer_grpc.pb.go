@@ -0,0 +1,258 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: grazer.proto
+
+package grazerpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Grazer_Invalidate_FullMethodName     = "/grazer.v1.Grazer/Invalidate"
+	Grazer_FullRevalidate_FullMethodName = "/grazer.v1.Grazer/FullRevalidate"
+	Grazer_QueueStatus_FullMethodName    = "/grazer.v1.Grazer/QueueStatus"
+	Grazer_StreamEvents_FullMethodName   = "/grazer.v1.Grazer/StreamEvents"
+)
+
+// GrazerClient is the client API for Grazer service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type GrazerClient interface {
+	// Invalidate enqueues invalidated documents, it needs the invalidate scope.
+	Invalidate(ctx context.Context, in *InvalidateRequest, opts ...grpc.CallOption) (*InvalidateResponse, error)
+	// FullRevalidate lists and enqueues all documents, it needs the full-revalidate or admin:write scope.
+	FullRevalidate(ctx context.Context, in *FullRevalidateRequest, opts ...grpc.CallOption) (*FullRevalidateResponse, error)
+	// QueueStatus returns the status of the queue, it needs the admin:read scope.
+	QueueStatus(ctx context.Context, in *QueueStatusRequest, opts ...grpc.CallOption) (*QueueStatusResponse, error)
+	// StreamEvents streams revalidation events until the client cancels or the server shuts down.
+	// It needs the invalidate or admin:read scope.
+	StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (Grazer_StreamEventsClient, error)
+}
+
+type grazerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewGrazerClient(cc grpc.ClientConnInterface) GrazerClient {
+	return &grazerClient{cc}
+}
+
+func (c *grazerClient) Invalidate(ctx context.Context, in *InvalidateRequest, opts ...grpc.CallOption) (*InvalidateResponse, error) {
+	out := new(InvalidateResponse)
+	err := c.cc.Invoke(ctx, Grazer_Invalidate_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *grazerClient) FullRevalidate(ctx context.Context, in *FullRevalidateRequest, opts ...grpc.CallOption) (*FullRevalidateResponse, error) {
+	out := new(FullRevalidateResponse)
+	err := c.cc.Invoke(ctx, Grazer_FullRevalidate_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *grazerClient) QueueStatus(ctx context.Context, in *QueueStatusRequest, opts ...grpc.CallOption) (*QueueStatusResponse, error) {
+	out := new(QueueStatusResponse)
+	err := c.cc.Invoke(ctx, Grazer_QueueStatus_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *grazerClient) StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (Grazer_StreamEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Grazer_ServiceDesc.Streams[0], Grazer_StreamEvents_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grazerStreamEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Grazer_StreamEventsClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type grazerStreamEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *grazerStreamEventsClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// GrazerServer is the server API for Grazer service.
+// All implementations must embed UnimplementedGrazerServer
+// for forward compatibility
+type GrazerServer interface {
+	// Invalidate enqueues invalidated documents, it needs the invalidate scope.
+	Invalidate(context.Context, *InvalidateRequest) (*InvalidateResponse, error)
+	// FullRevalidate lists and enqueues all documents, it needs the full-revalidate or admin:write scope.
+	FullRevalidate(context.Context, *FullRevalidateRequest) (*FullRevalidateResponse, error)
+	// QueueStatus returns the status of the queue, it needs the admin:read scope.
+	QueueStatus(context.Context, *QueueStatusRequest) (*QueueStatusResponse, error)
+	// StreamEvents streams revalidation events until the client cancels or the server shuts down.
+	// It needs the invalidate or admin:read scope.
+	StreamEvents(*StreamEventsRequest, Grazer_StreamEventsServer) error
+	mustEmbedUnimplementedGrazerServer()
+}
+
+// UnimplementedGrazerServer must be embedded to have forward compatible implementations.
+type UnimplementedGrazerServer struct {
+}
+
+func (UnimplementedGrazerServer) Invalidate(context.Context, *InvalidateRequest) (*InvalidateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Invalidate not implemented")
+}
+func (UnimplementedGrazerServer) FullRevalidate(context.Context, *FullRevalidateRequest) (*FullRevalidateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FullRevalidate not implemented")
+}
+func (UnimplementedGrazerServer) QueueStatus(context.Context, *QueueStatusRequest) (*QueueStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QueueStatus not implemented")
+}
+func (UnimplementedGrazerServer) StreamEvents(*StreamEventsRequest, Grazer_StreamEventsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamEvents not implemented")
+}
+func (UnimplementedGrazerServer) mustEmbedUnimplementedGrazerServer() {}
+
+// UnsafeGrazerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to GrazerServer will
+// result in compilation errors.
+type UnsafeGrazerServer interface {
+	mustEmbedUnimplementedGrazerServer()
+}
+
+func RegisterGrazerServer(s grpc.ServiceRegistrar, srv GrazerServer) {
+	s.RegisterService(&Grazer_ServiceDesc, srv)
+}
+
+func _Grazer_Invalidate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InvalidateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GrazerServer).Invalidate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Grazer_Invalidate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GrazerServer).Invalidate(ctx, req.(*InvalidateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Grazer_FullRevalidate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FullRevalidateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GrazerServer).FullRevalidate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Grazer_FullRevalidate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GrazerServer).FullRevalidate(ctx, req.(*FullRevalidateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Grazer_QueueStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueueStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GrazerServer).QueueStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Grazer_QueueStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GrazerServer).QueueStatus(ctx, req.(*QueueStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Grazer_StreamEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(GrazerServer).StreamEvents(m, &grazerStreamEventsServer{stream})
+}
+
+type Grazer_StreamEventsServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type grazerStreamEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *grazerStreamEventsServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Grazer_ServiceDesc is the grpc.ServiceDesc for Grazer service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Grazer_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "grazer.v1.Grazer",
+	HandlerType: (*GrazerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Invalidate",
+			Handler:    _Grazer_Invalidate_Handler,
+		},
+		{
+			MethodName: "FullRevalidate",
+			Handler:    _Grazer_FullRevalidate_Handler,
+		},
+		{
+			MethodName: "QueueStatus",
+			Handler:    _Grazer_QueueStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamEvents",
+			Handler:       _Grazer_StreamEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "grazer.proto",
+}
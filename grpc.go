@@ -0,0 +1,280 @@
+package grazer
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/apex/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/networkteam/grazer/grazerpb"
+)
+
+type GRPCServerOpts struct {
+	// Handler provides the controller and tokens, which are shared with the HTTP API
+	Handler *Handler
+	// ServerOptions are passed to the gRPC server (e.g. for TLS credentials)
+	ServerOptions []grpc.ServerOption
+}
+
+// GRPCServer serves the grazer gRPC API and the standard gRPC health service.
+type GRPCServer struct {
+	h      *Handler
+	server *grpc.Server
+	health *health.Server
+
+	grazerpb.UnimplementedGrazerServer
+}
+
+func NewGRPCServer(opts GRPCServerOpts) *GRPCServer {
+	s := &GRPCServer{
+		h:      opts.Handler,
+		server: grpc.NewServer(opts.ServerOptions...),
+		health: health.NewServer(),
+	}
+
+	grazerpb.RegisterGrazerServer(s.server, s)
+	healthpb.RegisterHealthServer(s.server, s.health)
+	s.health.SetServingStatus(grazerpb.Grazer_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+
+	return s
+}
+
+// Serve accepts connections on the listener until GracefulStop is called.
+func (s *GRPCServer) Serve(lis net.Listener) error {
+	return s.server.Serve(lis)
+}
+
+// GracefulStop reports all services as not serving, ends event streams and waits for pending RPCs to finish.
+func (s *GRPCServer) GracefulStop() {
+	s.health.Shutdown()
+	s.h.CloseStreams()
+	s.server.GracefulStop()
+}
+
+func (s *GRPCServer) Invalidate(ctx context.Context, req *grazerpb.InvalidateRequest) (*grazerpb.InvalidateResponse, error) {
+	token, err := s.authorize(ctx, ScopeInvalidate)
+	if err != nil {
+		return nil, err
+	}
+
+	body := revalidateRequestBody{
+		Documents:          make([]revalidateRequestDocument, len(req.Documents)),
+		SkipFullRevalidate: req.SkipFullRevalidate,
+	}
+	for i, document := range req.Documents {
+		body.Documents[i] = revalidateRequestDocument{
+			RoutePath:       document.GetRoutePath(),
+			RoutePathPrefix: document.GetRoutePathPrefix(),
+		}
+	}
+	switch req.Priority {
+	case grazerpb.Priority_PRIORITY_URGENT:
+		body.Priority = PriorityUrgent
+	case grazerpb.Priority_PRIORITY_NORMAL, grazerpb.Priority_PRIORITY_UNSPECIFIED:
+		body.Priority = PriorityNormal
+	case grazerpb.Priority_PRIORITY_LOW:
+		body.Priority = PriorityLow
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown priority %d", req.Priority)
+	}
+	if req.NotBefore != nil {
+		notBefore := req.NotBefore.AsTime()
+		body.NotBefore = &notBefore
+	}
+
+	resp, _, errResp := s.h.invalidate(ctx, body, revalidateRequest{
+		source:     SourceGRPC,
+		remoteAddr: remoteAddr(ctx),
+		token:      token.Name,
+	})
+	if errResp != nil {
+		return nil, grpcError(errResp)
+	}
+
+	result := &grazerpb.InvalidateResponse{
+		JobId: resp.JobID,
+	}
+	for _, e := range resp.Expanded {
+		result.Expanded = append(result.Expanded, &grazerpb.ExpandedPattern{
+			Pattern: e.Pattern,
+			Count:   int64(e.Count),
+		})
+	}
+	return result, nil
+}
+
+func (s *GRPCServer) FullRevalidate(ctx context.Context, _ *grazerpb.FullRevalidateRequest) (*grazerpb.FullRevalidateResponse, error) {
+	token, err := s.authorize(ctx, ScopeFullRevalidate, ScopeAdminWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	log.
+		WithField("component", "grpc").
+		WithField("token", token.Name).
+		Info("Revalidating all documents")
+
+	_, err = s.h.ctrl.revalidate(ctx, revalidateRequest{
+		source:     SourceGRPC,
+		remoteAddr: remoteAddr(ctx),
+		token:      token.Name,
+	})
+	if err != nil {
+		log.
+			WithField("component", "grpc").
+			WithError(err).
+			Warn("Full revalidate failed")
+		return nil, grpcError(&ErrorResponse{
+			Code:    ErrorCodeUpstreamFailed,
+			Message: err.Error(),
+		})
+	}
+
+	return &grazerpb.FullRevalidateResponse{}, nil
+}
+
+func (s *GRPCServer) QueueStatus(ctx context.Context, _ *grazerpb.QueueStatusRequest) (*grazerpb.QueueStatusResponse, error) {
+	if _, err := s.authorize(ctx, ScopeAdminRead); err != nil {
+		return nil, err
+	}
+
+	queue := s.h.Status(ctx).Queue
+	resp := &grazerpb.QueueStatusResponse{
+		Paused:      queue.Paused,
+		Length:      int64(queue.Length),
+		Invalidated: int64(queue.Invalidated),
+		Sweep:       int64(queue.Sweep),
+		Items:       make([]*grazerpb.QueueItem, len(queue.Items)),
+	}
+	for i, item := range queue.Items {
+		resp.Items[i] = &grazerpb.QueueItem{
+			RoutePath: item.RoutePath,
+			Tier:      item.Tier,
+			Priority:  item.Priority,
+			Targets:   item.Targets,
+		}
+	}
+	if queue.NextSweepAt != nil {
+		resp.NextSweepAt = timestamppb.New(*queue.NextSweepAt)
+	}
+	return resp, nil
+}
+
+func (s *GRPCServer) StreamEvents(_ *grazerpb.StreamEventsRequest, stream grazerpb.Grazer_StreamEventsServer) error {
+	ctx := stream.Context()
+	if _, err := s.authorize(ctx, ScopeInvalidate, ScopeAdminRead); err != nil {
+		return err
+	}
+
+	events, unsubscribe := s.h.Subscribe()
+	defer unsubscribe()
+
+	log.
+		WithField("component", "grpc").
+		WithField("remoteAddr", remoteAddr(ctx)).
+		Debug("Event stream opened")
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.
+				WithField("component", "grpc").
+				WithField("remoteAddr", remoteAddr(ctx)).
+				Debug("Event stream closed")
+			return nil
+		case <-s.h.streamsDone:
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(eventToProto(event)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// authorize verifies the bearer token of the metadata has any of the scopes.
+func (s *GRPCServer) authorize(ctx context.Context, scopes ...Scope) (Token, error) {
+	var secret string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, value := range md.Get("authorization") {
+			if v, ok := strings.CutPrefix(value, "Bearer "); ok {
+				secret = v
+			}
+		}
+	}
+
+	token, ok := s.h.lookupSecret(secret)
+	if !ok || !hasAnyScope(token, scopes) {
+		method, _ := grpc.Method(ctx)
+		log.
+			WithField("component", "grpc").
+			WithField("method", method).
+			WithField("token", token.Name).
+			Warn("Invalid token or missing scope")
+		if !ok {
+			return token, status.Error(codes.Unauthenticated, "invalid token")
+		}
+		return token, status.Error(codes.PermissionDenied, "missing scope")
+	}
+	return token, nil
+}
+
+// grpcError converts an error response of the API to a gRPC status error.
+func grpcError(errResp *ErrorResponse) error {
+	code := codes.InvalidArgument
+	if errResp.Code == ErrorCodeUpstreamFailed {
+		code = codes.Unavailable
+	}
+	return status.Error(code, errResp.Message)
+}
+
+func remoteAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+func eventToProto(event Event) *grazerpb.Event {
+	e := &grazerpb.Event{
+		Type:        string(event.Type),
+		Time:        timestamppb.New(event.Time),
+		JobId:       event.JobID,
+		Schedule:    event.Schedule,
+		Target:      event.Target,
+		RoutePath:   event.RoutePath,
+		RoutePaths:  event.RoutePaths,
+		Invalidated: int64(event.Invalidated),
+		All:         int64(event.All),
+		Dropped:     int64(event.Dropped),
+		Attempt:     int64(event.Attempt),
+		DurationMs:  event.DurationMs,
+		Error:       event.Error,
+		Upstream:    event.Upstream,
+		BuildId:     event.BuildID,
+	}
+	if event.Job != nil {
+		e.JobState = string(event.Job.State)
+	}
+	if event.Sweep != nil {
+		e.Sweep = &grazerpb.SweepProgress{
+			Total:     int64(event.Sweep.Total),
+			Done:      int64(event.Sweep.Done),
+			Remaining: int64(event.Sweep.Remaining),
+			Failed:    int64(event.Sweep.Failed),
+		}
+	}
+	return e
+}
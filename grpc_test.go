@@ -0,0 +1,100 @@
+package grazer
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/networkteam/grazer/grazerpb"
+)
+
+func TestGRPCServer(t *testing.T) {
+	next := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer next.Close()
+
+	h := NewHandler(HandlerOpts{
+		RevalidateToken: "revalidate-token",
+		AdminToken:      "admin-token",
+		Revalidator:     NewRevalidator(RevalidatorOpts{URL: next.URL}),
+		DocumentSource:  staticDocuments{{RoutePath: "/"}, {RoutePath: "/blog/post-1"}, {RoutePath: "/blog/post-2"}},
+	})
+	defer h.ShutdownAndWait()
+
+	lis := bufconn.Listen(1024 * 1024)
+	srv := NewGRPCServer(GRPCServerOpts{Handler: h})
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	defer srv.GracefulStop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx := context.Background()
+	withToken := func(token string) context.Context {
+		return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+	}
+	c := grazerpb.NewGrazerClient(conn)
+
+	healthResp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: "grazer.v1.Grazer"})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, healthResp.Status)
+
+	_, err = c.Invalidate(ctx, &grazerpb.InvalidateRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = c.QueueStatus(withToken("revalidate-token"), &grazerpb.QueueStatusRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = c.Invalidate(withToken("revalidate-token"), &grazerpb.InvalidateRequest{
+		Documents: []*grazerpb.Document{{Target: &grazerpb.Document_RoutePath{RoutePath: "blog"}}},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	events, err := c.StreamEvents(withToken("admin-token"), &grazerpb.StreamEventsRequest{})
+	require.NoError(t, err)
+
+	resp, err := c.Invalidate(withToken("revalidate-token"), &grazerpb.InvalidateRequest{
+		Documents: []*grazerpb.Document{{Target: &grazerpb.Document_RoutePath{RoutePath: "/blog/*"}}},
+		Priority:  grazerpb.Priority_PRIORITY_URGENT,
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.JobId)
+	require.Len(t, resp.Expanded, 1)
+	assert.Equal(t, int64(2), resp.Expanded[0].Count)
+
+	for {
+		event, err := events.Recv()
+		require.NoError(t, err)
+		if event.Type == string(EventQueueDrained) {
+			break
+		}
+	}
+
+	queueResp, err := c.QueueStatus(withToken("admin-token"), &grazerpb.QueueStatusRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), queueResp.Length)
+
+	records := h.ctrl.history.Query(HistoryQuery{Type: HistoryInvalidation})
+	require.Len(t, records, 1)
+	assert.Equal(t, SourceGRPC, records[0].Source)
+	assert.Equal(t, "revalidate-token", records[0].Token)
+}
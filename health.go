@@ -0,0 +1,249 @@
+package grazer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// HealthChecker is implemented by upstreams that can check if they are reachable.
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+// ReadinessChecker is implemented by upstreams that need a stricter check than reachability before they can be used.
+type ReadinessChecker interface {
+	CheckReady(ctx context.Context) error
+}
+
+var (
+	_ ReadinessChecker = &Fetcher{}
+	_ ReadinessChecker = MultiSource{}
+
+	_ HealthChecker = &Fetcher{}
+	_ HealthChecker = &Revalidator{}
+	_ HealthChecker = &SitemapSource{}
+	_ HealthChecker = &StaticSource{}
+	_ HealthChecker = MultiSource{}
+)
+
+// UpstreamStatus is the result of a health check of an upstream.
+type UpstreamStatus struct {
+	Name      string    `json:"name"`
+	Reachable bool      `json:"reachable"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMs int64     `json:"latencyMs"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// checkReachable sends a HEAD request to the URL, any response that is not a server error means the URL is reachable.
+func checkReachable(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return fmt.Errorf("building request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// CheckHealth checks if the Neos instance is reachable.
+func (f *Fetcher) CheckHealth(ctx context.Context) error {
+	return checkReachable(ctx, f.client, f.neosBaseURL)
+}
+
+// CheckReady checks if the Neos content API responds to a request for the documents with a 2xx status.
+// Unlike CheckHealth it fails on client errors, e.g. if the content API is not installed yet or the authentication is rejected.
+func (f *Fetcher) CheckReady(ctx context.Context) error {
+	req, err := f.newDocumentsRequest(ctx)
+	if err != nil {
+		return err
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// CheckHealth checks if the revalidate URL is reachable.
+func (r *Revalidator) CheckHealth(ctx context.Context) error {
+	return checkReachable(ctx, r.client, r.url)
+}
+
+// CheckHealth checks if the sitemap URL is reachable.
+func (s *SitemapSource) CheckHealth(ctx context.Context) error {
+	return checkReachable(ctx, s.client, s.url)
+}
+
+// CheckHealth checks if the file exists.
+func (s *StaticSource) CheckHealth(_ context.Context) error {
+	_, err := os.Stat(s.filename)
+	return err
+}
+
+// CheckReady checks all sources that implement ReadinessChecker or HealthChecker.
+func (m MultiSource) CheckReady(ctx context.Context) error {
+	var errs []error
+	for i, source := range m {
+		if checker := readinessCheckerOf(source); checker != nil {
+			if err := checker.CheckHealth(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("source %d (%T): %w", i, source, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CheckHealth checks all sources that implement HealthChecker.
+func (m MultiSource) CheckHealth(ctx context.Context) error {
+	var errs []error
+	for i, source := range m {
+		if checker, ok := source.(HealthChecker); ok {
+			if err := checker.CheckHealth(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("source %d (%T): %w", i, source, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type namedHealthChecker struct {
+	name    string
+	checker HealthChecker
+}
+
+// checkUpstreams runs the health checks concurrently.
+func checkUpstreams(ctx context.Context, checkers []namedHealthChecker) []UpstreamStatus {
+	result := make([]UpstreamStatus, len(checkers))
+
+	var wg sync.WaitGroup
+	for i, c := range checkers {
+		wg.Add(1)
+		go func(i int, c namedHealthChecker) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := c.checker.CheckHealth(ctx)
+			status := UpstreamStatus{
+				Name:      c.name,
+				Reachable: err == nil,
+				LatencyMs: time.Since(start).Milliseconds(),
+				CheckedAt: start,
+			}
+			if err != nil {
+				status.Error = err.Error()
+			}
+			result[i] = status
+		}(i, c)
+	}
+	wg.Wait()
+
+	return result
+}
+
+// upstreamMonitor publishes events when the reachability of an upstream changes.
+type upstreamMonitor struct {
+	checkers []namedHealthChecker
+	events   *eventBroker
+	// unreachable are the names of upstreams that failed the last check
+	unreachable map[string]bool
+}
+
+// check runs all health checks and publishes an event for each upstream that became unreachable or recovered.
+// It returns the status of all upstreams.
+func (m *upstreamMonitor) check(ctx context.Context) []UpstreamStatus {
+	ctx, cancel := context.WithTimeout(ctx, upstreamCheckTimeout)
+	defer cancel()
+
+	statuses := checkUpstreams(ctx, m.checkers)
+	for _, status := range statuses {
+		switch {
+		case !status.Reachable && !m.unreachable[status.Name]:
+			m.unreachable[status.Name] = true
+			log.
+				WithField("component", "monitor").
+				WithField("upstream", status.Name).
+				WithField("error", status.Error).
+				Warn("Upstream is unreachable")
+			m.events.publish(Event{
+				Type:     EventUpstreamUnreachable,
+				Upstream: status.Name,
+				Error:    status.Error,
+			})
+		case status.Reachable && m.unreachable[status.Name]:
+			delete(m.unreachable, status.Name)
+			log.
+				WithField("component", "monitor").
+				WithField("upstream", status.Name).
+				Info("Upstream recovered")
+			m.events.publish(Event{
+				Type:     EventUpstreamRecovered,
+				Upstream: status.Name,
+			})
+		}
+	}
+	return statuses
+}
+
+// MonitorUpstreams checks the document source and all targets every interval until ctx is done.
+// EventUpstreamUnreachable and EventUpstreamRecovered are published when the reachability of an upstream changes.
+// The results of the last check are reported by Status.
+func (h *Handler) MonitorUpstreams(ctx context.Context, interval time.Duration) {
+	m := &upstreamMonitor{
+		events:      h.ctrl.events,
+		unreachable: make(map[string]bool),
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// Targets can be replaced by a reload
+		m.checkers = h.ctrl.healthCheckers()
+		statuses := m.check(ctx)
+		if ctx.Err() == nil {
+			h.setUpstreams(statuses)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *Handler) setUpstreams(statuses []UpstreamStatus) {
+	h.upstreamsMx.Lock()
+	defer h.upstreamsMx.Unlock()
+
+	h.upstreams = statuses
+}
+
+func (h *Handler) currentUpstreams() []UpstreamStatus {
+	h.upstreamsMx.RLock()
+	defer h.upstreamsMx.RUnlock()
+
+	return append([]UpstreamStatus(nil), h.upstreams...)
+}
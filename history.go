@@ -0,0 +1,627 @@
+package grazer
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// HistoryRecordType is the type of a history record.
+type HistoryRecordType string
+
+const (
+	// HistoryInvalidation is an accepted invalidation request.
+	HistoryInvalidation HistoryRecordType = "invalidation"
+	// HistoryFullRevalidation is a full revalidation (initial, scheduled or requested).
+	HistoryFullRevalidation HistoryRecordType = "fullRevalidation"
+	// HistoryRevalidation is a revalidate request to a target (including retries).
+	HistoryRevalidation HistoryRecordType = "revalidation"
+)
+
+// Outcomes of history records.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// HistoryRecord is an entry in the revalidation history.
+type HistoryRecord struct {
+	Type HistoryRecordType `json:"type"`
+	Time time.Time         `json:"time"`
+
+	RoutePaths []string `json:"routePaths,omitempty"`
+
+	// Source of an invalidation or full revalidation (see SourceAPI, SourceSchedule, SourceManual, SourceGRPC and SourceDeployment)
+	Source     string `json:"source,omitempty"`
+	RemoteAddr string `json:"remoteAddr,omitempty"`
+	// Token is the name of the token of an API request
+	Token    string `json:"token,omitempty"`
+	Schedule string `json:"schedule,omitempty"`
+	JobID    string `json:"jobId,omitempty"`
+
+	// Outcome is failure if listing documents for an invalidation or a revalidate request failed
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+
+	// Fields of revalidation records
+	Target     string `json:"target,omitempty"`
+	DurationMs int64  `json:"durationMs,omitempty"`
+	Attempts   int    `json:"attempts,omitempty"`
+	// DryRun is true if the revalidate request was only logged and not sent to the target
+	DryRun bool `json:"dryRun,omitempty"`
+	// Sweep is true for a revalidation of route paths of a full revalidation, they are kept apart from other records
+	Sweep bool `json:"sweep,omitempty"`
+}
+
+// LastRevalidation is the last revalidate request of a route path to a target.
+type LastRevalidation struct {
+	RoutePath string    `json:"routePath"`
+	Target    string    `json:"target"`
+	Time      time.Time `json:"time"`
+	Outcome   string    `json:"outcome"`
+	Error     string    `json:"error,omitempty"`
+	Sweep     bool      `json:"sweep,omitempty"`
+	DryRun    bool      `json:"dryRun,omitempty"`
+	// LastSuccess is the time of the last successful revalidation (empty if none is known)
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+}
+
+// HistoryQuery filters history records, empty fields match all records.
+type HistoryQuery struct {
+	// RoutePath matches records containing the route path
+	RoutePath string
+	Since     time.Time
+	Until     time.Time
+	Type      HistoryRecordType
+	// Outcome matches records with the outcome (OutcomeSuccess or OutcomeFailure)
+	Outcome string
+	// Limit is the maximum number of records (all if 0)
+	Limit int
+}
+
+func (q HistoryQuery) match(record HistoryRecord) bool {
+	if q.Type != "" && record.Type != q.Type {
+		return false
+	}
+	if q.Outcome != "" && record.Outcome != q.Outcome {
+		return false
+	}
+	if !q.Since.IsZero() && record.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && record.Time.After(q.Until) {
+		return false
+	}
+	if q.RoutePath != "" && !containsString(record.RoutePaths, q.RoutePath) {
+		return false
+	}
+	return true
+}
+
+type HistoryOpts struct {
+	// Filename of the history file (NDJSON), the history is only kept in memory if empty
+	Filename string
+	// MaxRecords is the number of records that are kept, revalidations of full revalidations are kept separately with the same limit
+	MaxRecords int
+}
+
+// historyFlushDelay is the delay for writing buffered records to the history file.
+const historyFlushDelay = time.Second
+
+// History is a bounded history of invalidations and revalidations.
+// Revalidations of full revalidations are kept apart from other records, so a full revalidation of a large site does not evict invalidations.
+// The last revalidation of every route path is indexed until no kept record contains the route path anymore and the history is compacted.
+// Records are appended to a file, which is compacted to the last records in the background if it grows too large.
+type History struct {
+	mx         sync.Mutex
+	filename   string
+	maxRecords int
+
+	records      []HistoryRecord
+	sweepRecords []HistoryRecord
+	// last indexes the last revalidation of route paths by route path and target
+	last map[string]map[string]*LastRevalidation
+
+	file *os.File
+	// w buffers writes to the file, it is flushed after historyFlushDelay
+	w          *bufio.Writer
+	flushTimer *time.Timer
+	// fileRecords is the number of records in the file, without a file the records since the last compaction are counted as if they were written
+	fileRecords int
+
+	// compactDone is closed when the running compaction finished (nil if no compaction is running)
+	compactDone chan struct{}
+	// compactPending are records added while a compaction is running, they are written to the compacted file
+	compactPending []HistoryRecord
+}
+
+// NewHistory creates a history and loads existing records from the file.
+func NewHistory(opts HistoryOpts) (*History, error) {
+	if opts.MaxRecords == 0 {
+		opts.MaxRecords = 10000
+	}
+
+	h := &History{
+		filename:   opts.Filename,
+		maxRecords: opts.MaxRecords,
+		last:       make(map[string]map[string]*LastRevalidation),
+	}
+
+	if h.filename == "" {
+		return h, nil
+	}
+
+	err := h.load()
+	if err != nil {
+		return nil, fmt.Errorf("loading history: %w", err)
+	}
+
+	h.file, err = os.OpenFile(h.filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening history file: %w", err)
+	}
+	h.w = bufio.NewWriter(h.file)
+
+	return h, nil
+}
+
+func (h *History) load() error {
+	f, err := os.Open(h.filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		h.fileRecords++
+
+		var record HistoryRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// Skip broken lines (e.g. a partially written last line)
+			continue
+		}
+		h._append(record)
+	}
+	return scanner.Err()
+}
+
+// Add appends a record to the history.
+func (h *History) Add(record HistoryRecord) {
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	h._append(record)
+	if h.compactDone != nil {
+		h.compactPending = append(h.compactPending, record)
+	}
+
+	if h.filename == "" {
+		h.fileRecords++
+		h._compactIfNeeded()
+		return
+	}
+	if h.file == nil {
+		return
+	}
+
+	err := h._write(record)
+	if err != nil {
+		log.
+			WithField("component", "history").
+			WithError(err).
+			Warn("Writing history record failed")
+	}
+}
+
+// _append adds a record to its bounded records and updates the index of the last revalidations.
+func (h *History) _append(record HistoryRecord) {
+	if record.Sweep {
+		h.sweepRecords = appendBounded(h.sweepRecords, record, h.maxRecords)
+	} else {
+		h.records = appendBounded(h.records, record, h.maxRecords)
+	}
+
+	if record.Type != HistoryRevalidation {
+		return
+	}
+	for _, routePath := range record.RoutePaths {
+		targets := h.last[routePath]
+		if targets == nil {
+			targets = make(map[string]*LastRevalidation)
+			h.last[routePath] = targets
+		}
+		last := targets[record.Target]
+		if last == nil {
+			last = &LastRevalidation{
+				RoutePath: routePath,
+				Target:    record.Target,
+			}
+			targets[record.Target] = last
+		}
+		last.Time = record.Time
+		last.Outcome = record.Outcome
+		last.Error = record.Error
+		last.Sweep = record.Sweep
+		last.DryRun = record.DryRun
+		if record.Outcome == OutcomeSuccess {
+			t := record.Time
+			last.LastSuccess = &t
+		}
+	}
+}
+
+func appendBounded(records []HistoryRecord, record HistoryRecord, maxRecords int) []HistoryRecord {
+	records = append(records, record)
+	if len(records) > maxRecords {
+		// Re-allocate from time to time, so the backing array does not grow indefinitely
+		records = append([]HistoryRecord(nil), records[len(records)-maxRecords:]...)
+	}
+	return records
+}
+
+func (h *History) _write(record HistoryRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("encoding record: %w", err)
+	}
+	data = append(data, '\n')
+
+	_, err = h.w.Write(data)
+	if err != nil {
+		return fmt.Errorf("writing record: %w", err)
+	}
+	h.fileRecords++
+	h._compactIfNeeded()
+
+	if h.flushTimer == nil {
+		h.flushTimer = time.AfterFunc(historyFlushDelay, h.flush)
+	}
+	return nil
+}
+
+// flush writes buffered records to the file.
+func (h *History) flush() {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	h.flushTimer = nil
+	if h.file == nil {
+		return
+	}
+	if err := h.w.Flush(); err != nil {
+		log.
+			WithField("component", "history").
+			WithError(err).
+			Warn("Writing history records failed")
+	}
+}
+
+// _compactIfNeeded starts a compaction if the file has twice as many records as the records in memory.
+func (h *History) _compactIfNeeded() {
+	if h.compactDone != nil || h.fileRecords <= 2*(len(h.records)+len(h.sweepRecords)) {
+		return
+	}
+
+	records := make([]HistoryRecord, 0, len(h.records)+len(h.sweepRecords))
+	records = append(records, h.records...)
+	records = append(records, h.sweepRecords...)
+
+	done := make(chan struct{})
+	h.compactDone = done
+	go func() {
+		defer close(done)
+		h.compact(records)
+	}()
+}
+
+// compact replaces the history file with the records and removes route paths without records from the index of the last revalidations.
+// The records are written without holding the lock, so adding records is not blocked by the compaction.
+func (h *History) compact(records []HistoryRecord) {
+	var (
+		tmp *os.File
+		err error
+	)
+	if h.filename != "" {
+		tmp, err = writeHistoryTemp(h.filename, records)
+	}
+
+	routePaths := make(map[string]struct{})
+	addRoutePaths := func(records []HistoryRecord) {
+		for _, record := range records {
+			if record.Type != HistoryRevalidation {
+				continue
+			}
+			for _, routePath := range record.RoutePaths {
+				routePaths[routePath] = struct{}{}
+			}
+		}
+	}
+	addRoutePaths(records)
+
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	pending := h.compactPending
+	h.compactPending = nil
+	h.compactDone = nil
+
+	addRoutePaths(pending)
+	for routePath := range h.last {
+		if _, ok := routePaths[routePath]; !ok {
+			delete(h.last, routePath)
+		}
+	}
+
+	if h.filename == "" {
+		h.fileRecords = len(records) + len(pending)
+		return
+	}
+	if err == nil {
+		err = h._replaceFile(tmp, pending)
+	}
+	if err != nil {
+		log.
+			WithField("component", "history").
+			WithError(err).
+			Warn("Compacting history file failed")
+	}
+	h.fileRecords = len(records) + len(pending)
+}
+
+// writeHistoryTemp writes the records to a temporary file next to the history file, the returned file is open for appending.
+func writeHistoryTemp(filename string, records []HistoryRecord) (*os.File, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary file: %w", err)
+	}
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return nil, fmt.Errorf("encoding record: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("writing temporary file: %w", err)
+	}
+	return tmp, nil
+}
+
+// _replaceFile appends the pending records to the temporary file and replaces the history file with it.
+func (h *History) _replaceFile(tmp *os.File, pending []HistoryRecord) error {
+	defer os.Remove(tmp.Name())
+
+	// The history was closed during the compaction
+	if h.file == nil {
+		tmp.Close()
+		return nil
+	}
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, record := range pending {
+		if err := enc.Encode(record); err != nil {
+			tmp.Close()
+			return fmt.Errorf("encoding record: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing temporary file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), h.filename); err != nil {
+		return fmt.Errorf("replacing history file: %w", err)
+	}
+
+	// Buffered records are part of the compacted file
+	_ = h.file.Close()
+	var err error
+	h.file, err = os.OpenFile(h.filename, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening history file: %w", err)
+	}
+	h.w.Reset(h.file)
+
+	return nil
+}
+
+// Query returns matching records, newest first.
+func (h *History) Query(q HistoryQuery) []HistoryRecord {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	result := []HistoryRecord{}
+	// Merge the records and the records of full revalidations by time
+	i, j := len(h.records)-1, len(h.sweepRecords)-1
+	for i >= 0 || j >= 0 {
+		var record HistoryRecord
+		if j < 0 || (i >= 0 && !h.records[i].Time.Before(h.sweepRecords[j].Time)) {
+			record = h.records[i]
+			i--
+		} else {
+			record = h.sweepRecords[j]
+			j--
+		}
+
+		if !q.match(record) {
+			continue
+		}
+		result = append(result, record)
+		if q.Limit > 0 && len(result) == q.Limit {
+			break
+		}
+	}
+	return result
+}
+
+// LastRevalidations returns the last revalidation of the route path for each target, ordered by target.
+func (h *History) LastRevalidations(routePath string) []LastRevalidation {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	targets := h.last[routePath]
+	result := make([]LastRevalidation, 0, len(targets))
+	for _, last := range targets {
+		result = append(result, *last)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Target < result[j].Target
+	})
+	return result
+}
+
+// Close waits for a running compaction, writes buffered records and closes the history file.
+func (h *History) Close() error {
+	h.mx.Lock()
+	done := h.compactDone
+	h.mx.Unlock()
+	// The compacted file contains all records added until the compaction finished
+	if done != nil {
+		<-done
+	}
+
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	if h.file == nil {
+		return nil
+	}
+	if h.flushTimer != nil {
+		h.flushTimer.Stop()
+		h.flushTimer = nil
+	}
+	flushErr := h.w.Flush()
+	err := h.file.Close()
+	h.file = nil
+	if flushErr != nil {
+		return fmt.Errorf("writing records: %w", flushErr)
+	}
+	return err
+}
+
+const (
+	defaultHistoryQueryLimit = 100
+	maxHistoryQueryLimit     = 1000
+)
+
+func (h *Handler) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r, ScopeAdminRead) {
+		return
+	}
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	q, err := parseHistoryQuery(r)
+	if err != nil {
+		log.
+			WithField("component", "http").
+			WithError(err).
+			Warn("Invalid history query")
+		writeError(w, http.StatusBadRequest, ErrorResponse{
+			Code:    ErrorCodeInvalidParameter,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.ctrl.history.Query(q))
+}
+
+// handleLastRevalidations handles GET /api/history/last with the required routePath parameter.
+func (h *Handler) handleLastRevalidations(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r, ScopeAdminRead) {
+		return
+	}
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	routePath := r.URL.Query().Get("routePath")
+	if routePath == "" {
+		writeError(w, http.StatusBadRequest, ErrorResponse{
+			Code:    ErrorCodeInvalidParameter,
+			Message: "routePath is required",
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.ctrl.history.LastRevalidations(routePath))
+}
+
+// parseHistoryQuery parses the query parameters routePath, since, until (RFC 3339), type, outcome and limit.
+func parseHistoryQuery(r *http.Request) (HistoryQuery, error) {
+	query := r.URL.Query()
+	q := HistoryQuery{
+		RoutePath: query.Get("routePath"),
+		Type:      HistoryRecordType(query.Get("type")),
+		Outcome:   query.Get("outcome"),
+		Limit:     defaultHistoryQueryLimit,
+	}
+
+	var err error
+	if v := query.Get("since"); v != "" {
+		q.Since, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			return q, fmt.Errorf("parsing since: %w", err)
+		}
+	}
+	if v := query.Get("until"); v != "" {
+		q.Until, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			return q, fmt.Errorf("parsing until: %w", err)
+		}
+	}
+	switch q.Type {
+	case "", HistoryInvalidation, HistoryFullRevalidation, HistoryRevalidation:
+	default:
+		return q, fmt.Errorf("invalid type: %q", q.Type)
+	}
+	switch q.Outcome {
+	case "", OutcomeSuccess, OutcomeFailure:
+	default:
+		return q, fmt.Errorf("invalid outcome: %q", q.Outcome)
+	}
+	if v := query.Get("limit"); v != "" {
+		q.Limit, err = strconv.Atoi(v)
+		if err != nil {
+			return q, fmt.Errorf("parsing limit: %w", err)
+		}
+		if q.Limit <= 0 || q.Limit > maxHistoryQueryLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d", maxHistoryQueryLimit)
+		}
+	}
+
+	return q, nil
+}
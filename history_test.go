@@ -0,0 +1,175 @@
+package grazer
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func TestHistory(t *testing.T) {
+	t.Run("query", func(t *testing.T) {
+		h, err := NewHistory(HistoryOpts{})
+		require.NoError(t, err)
+
+		t0 := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+		h.Add(HistoryRecord{Type: HistoryInvalidation, Time: t0, RoutePaths: []string{"/products/x"}, Source: SourceAPI, Outcome: OutcomeSuccess})
+		h.Add(HistoryRecord{Type: HistoryRevalidation, Time: t0.Add(time.Second), RoutePaths: []string{"/products/x"}, Target: "next", Outcome: OutcomeFailure, Error: "unexpected status code: 500"})
+		h.Add(HistoryRecord{Type: HistoryRevalidation, Time: t0.Add(time.Minute), RoutePaths: []string{"/products/x", "/about"}, Target: "next", Outcome: OutcomeSuccess})
+		h.Add(HistoryRecord{Type: HistoryRevalidation, Time: t0.Add(time.Hour), RoutePaths: []string{"/about"}, Target: "next", Outcome: OutcomeSuccess})
+
+		records := h.Query(HistoryQuery{RoutePath: "/products/x"})
+		require.Len(t, records, 3)
+		// Newest first
+		assert.Equal(t, t0.Add(time.Minute), records[0].Time)
+
+		records = h.Query(HistoryQuery{RoutePath: "/products/x", Outcome: OutcomeFailure})
+		require.Len(t, records, 1)
+		assert.Equal(t, "unexpected status code: 500", records[0].Error)
+
+		records = h.Query(HistoryQuery{Since: t0.Add(time.Second), Until: t0.Add(time.Minute)})
+		assert.Len(t, records, 2)
+
+		records = h.Query(HistoryQuery{Type: HistoryRevalidation, Limit: 1})
+		require.Len(t, records, 1)
+		assert.Equal(t, []string{"/about"}, records[0].RoutePaths)
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		h, err := NewHistory(HistoryOpts{MaxRecords: 2})
+		require.NoError(t, err)
+
+		h.Add(HistoryRecord{Type: HistoryRevalidation, RoutePaths: []string{"/a"}})
+		h.Add(HistoryRecord{Type: HistoryRevalidation, RoutePaths: []string{"/b"}})
+		h.Add(HistoryRecord{Type: HistoryRevalidation, RoutePaths: []string{"/c"}})
+
+		records := h.Query(HistoryQuery{})
+		require.Len(t, records, 2)
+		assert.Equal(t, []string{"/c"}, records[0].RoutePaths)
+		assert.Equal(t, []string{"/b"}, records[1].RoutePaths)
+	})
+
+	t.Run("persisted and compacted", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "history.ndjson")
+
+		h, err := NewHistory(HistoryOpts{Filename: filename, MaxRecords: 2})
+		require.NoError(t, err)
+		for _, routePath := range []string{"/a", "/b", "/c", "/d", "/e"} {
+			h.Add(HistoryRecord{Type: HistoryRevalidation, RoutePaths: []string{routePath}})
+		}
+		require.NoError(t, h.Close())
+
+		// The file was compacted after exceeding twice the number of records
+		assert.Equal(t, 2, countLines(t, filename))
+
+		h, err = NewHistory(HistoryOpts{Filename: filename, MaxRecords: 2})
+		require.NoError(t, err)
+		defer h.Close()
+
+		records := h.Query(HistoryQuery{})
+		require.Len(t, records, 2)
+		assert.Equal(t, []string{"/e"}, records[0].RoutePaths)
+		assert.Equal(t, []string{"/d"}, records[1].RoutePaths)
+
+		// Records are written after a delay or on close
+		h.Add(HistoryRecord{Type: HistoryRevalidation, RoutePaths: []string{"/f"}})
+		assert.Equal(t, 2, countLines(t, filename))
+		require.NoError(t, h.Close())
+		assert.Equal(t, 3, countLines(t, filename))
+	})
+
+	t.Run("full revalidations do not evict other records", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "history.ndjson")
+
+		h, err := NewHistory(HistoryOpts{Filename: filename, MaxRecords: 2})
+		require.NoError(t, err)
+
+		t0 := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+		h.Add(HistoryRecord{Type: HistoryInvalidation, Time: t0, RoutePaths: []string{"/products/x"}, Outcome: OutcomeSuccess})
+		h.Add(HistoryRecord{Type: HistoryRevalidation, Time: t0.Add(time.Second), RoutePaths: []string{"/products/x"}, Target: "next", Outcome: OutcomeSuccess})
+		for i, routePath := range []string{"/a", "/b", "/c", "/products/x", "/d"} {
+			h.Add(HistoryRecord{Type: HistoryRevalidation, Time: t0.Add(time.Duration(i+2) * time.Minute), RoutePaths: []string{routePath}, Target: "next", Outcome: OutcomeSuccess, Sweep: true})
+		}
+		require.NoError(t, h.Close())
+
+		h, err = NewHistory(HistoryOpts{Filename: filename, MaxRecords: 2})
+		require.NoError(t, err)
+		defer h.Close()
+
+		records := h.Query(HistoryQuery{})
+		require.Len(t, records, 4)
+		assert.Equal(t, []string{"/d"}, records[0].RoutePaths)
+		assert.Equal(t, []string{"/products/x"}, records[1].RoutePaths)
+		assert.True(t, records[1].Sweep)
+		assert.Equal(t, HistoryRevalidation, records[2].Type)
+		assert.Equal(t, HistoryInvalidation, records[3].Type)
+
+		// The last revalidation of a route path is indexed
+		last := h.LastRevalidations("/products/x")
+		require.Len(t, last, 1)
+		assert.Equal(t, "next", last[0].Target)
+		assert.Equal(t, t0.Add(5*time.Minute), last[0].Time)
+		assert.True(t, last[0].Sweep)
+		require.NotNil(t, last[0].LastSuccess)
+		assert.Empty(t, h.LastRevalidations("/unknown"))
+	})
+
+	t.Run("compaction prunes the index", func(t *testing.T) {
+		h, err := NewHistory(HistoryOpts{MaxRecords: 2})
+		require.NoError(t, err)
+
+		for _, routePath := range []string{"/a", "/b", "/c", "/d", "/e"} {
+			h.Add(HistoryRecord{Type: HistoryRevalidation, RoutePaths: []string{routePath}, Target: "next", Outcome: OutcomeSuccess})
+		}
+		// Close waits for the compaction
+		require.NoError(t, h.Close())
+
+		assert.Empty(t, h.LastRevalidations("/a"))
+		assert.Empty(t, h.LastRevalidations("/c"))
+		assert.Len(t, h.LastRevalidations("/d"), 1)
+		assert.Len(t, h.LastRevalidations("/e"), 1)
+	})
+
+	t.Run("records added during a compaction are kept", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "history.ndjson")
+
+		h, err := NewHistory(HistoryOpts{Filename: filename, MaxRecords: 2})
+		require.NoError(t, err)
+		for _, routePath := range []string{"/a", "/b", "/c", "/d", "/e", "/f", "/g"} {
+			h.Add(HistoryRecord{Type: HistoryRevalidation, RoutePaths: []string{routePath}, Target: "next", Outcome: OutcomeSuccess})
+		}
+		require.NoError(t, h.Close())
+		assert.LessOrEqual(t, countLines(t, filename), 4)
+
+		h, err = NewHistory(HistoryOpts{Filename: filename, MaxRecords: 2})
+		require.NoError(t, err)
+		defer h.Close()
+
+		records := h.Query(HistoryQuery{})
+		require.Len(t, records, 2)
+		assert.Equal(t, []string{"/g"}, records[0].RoutePaths)
+		assert.Equal(t, []string{"/f"}, records[1].RoutePaths)
+		assert.Len(t, h.LastRevalidations("/f"), 1)
+		assert.Empty(t, h.LastRevalidations("/a"))
+	})
+}
+
+func countLines(t *testing.T, filename string) int {
+	t.Helper()
+
+	f, err := os.Open(filename)
+	require.NoError(t, err)
+	defer f.Close()
+
+	n := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		n++
+	}
+	require.NoError(t, scanner.Err())
+	return n
+}
@@ -0,0 +1,359 @@
+package grazer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxJobAge is the age after which unfinished jobs fail, it is longer than the maximum delay of an invalidation.
+const maxJobAge = 2 * maxNotBeforeDelay
+
+// errExpired is the error of job route paths without a result after maxJobAge.
+var errExpired = errors.New("expired")
+
+// JobState is the state of a revalidation job.
+type JobState string
+
+const (
+	// JobStatePending means the documents were not listed and enqueued yet.
+	JobStatePending JobState = "pending"
+	// JobStateRunning means at least one route path of the job was not revalidated yet.
+	JobStateRunning JobState = "running"
+	// JobStateDone means all route paths of the job were revalidated.
+	JobStateDone JobState = "done"
+	// JobStateFailed means the revalidation of at least one route path or the listing of documents failed.
+	JobStateFailed JobState = "failed"
+)
+
+// PathState is the state of a single route path in a revalidation job.
+type PathState string
+
+const (
+	PathStateQueued   PathState = "queued"
+	PathStateInFlight PathState = "inFlight"
+	PathStateDone     PathState = "done"
+	PathStateFailed   PathState = "failed"
+)
+
+// JobStatus is the status of a revalidation job.
+type JobStatus struct {
+	ID         string          `json:"id"`
+	State      JobState        `json:"state"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	FinishedAt *time.Time      `json:"finishedAt,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Paths      []JobPathStatus `json:"paths"`
+}
+
+// JobPathStatus is the status of a single route path in a revalidation job.
+type JobPathStatus struct {
+	RoutePath string    `json:"routePath"`
+	State     PathState `json:"state"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Error     string    `json:"error,omitempty"`
+}
+
+type job struct {
+	id         string
+	createdAt  time.Time
+	finishedAt time.Time
+	// started is set after the route paths of the job were registered
+	started bool
+	err     error
+	paths   map[string]*JobPathStatus
+	pending int
+	done    chan struct{}
+}
+
+func (j *job) finished() bool {
+	return !j.finishedAt.IsZero()
+}
+
+// jobRegistry keeps track of revalidation jobs and the state of their route paths.
+// Only a limited number of jobs is kept, the oldest finished jobs are removed first.
+// Unfinished jobs fail after a maximum age, so jobs waiting for route paths that are never revalidated are removed eventually.
+type jobRegistry struct {
+	mx      sync.Mutex
+	maxJobs int
+	maxAge  time.Duration
+
+	jobs map[string]*job
+	// ids in order of creation for removing old jobs
+	ids []string
+	// waiting are jobs by route path that wait for the route path to be dispatched
+	waiting map[string][]*job
+	// inFlight are jobs by route path that wait for the result of a dispatched route path
+	inFlight map[string][]*job
+
+	// onFinished is called with the status of each finished job while the registry is locked (optional)
+	onFinished func(status *JobStatus)
+}
+
+func newJobRegistry(maxJobs int, maxAge time.Duration) *jobRegistry {
+	return &jobRegistry{
+		maxJobs:  maxJobs,
+		maxAge:   maxAge,
+		jobs:     make(map[string]*job),
+		waiting:  make(map[string][]*job),
+		inFlight: make(map[string][]*job),
+	}
+}
+
+// create adds a new pending job.
+func (r *jobRegistry) create() *job {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	j := &job{
+		id:        newJobID(),
+		createdAt: time.Now(),
+		paths:     make(map[string]*JobPathStatus),
+		done:      make(chan struct{}),
+	}
+	r.jobs[j.id] = j
+	r.ids = append(r.ids, j.id)
+
+	r._removeOldJobs()
+
+	return j
+}
+
+// register sets the route paths of a job, they must be registered before they are enqueued.
+func (r *jobRegistry) register(j *job, routePaths []string) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	now := time.Now()
+	j.started = true
+	for _, routePath := range routePaths {
+		if _, exists := j.paths[routePath]; exists {
+			continue
+		}
+		j.paths[routePath] = &JobPathStatus{
+			RoutePath: routePath,
+			State:     PathStateQueued,
+			UpdatedAt: now,
+		}
+		j.pending++
+		r.waiting[routePath] = append(r.waiting[routePath], j)
+	}
+
+	r._finishIfDone(j, now)
+}
+
+// fail marks a job as failed before its route paths were registered.
+func (r *jobRegistry) fail(j *job, err error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	j.err = err
+	r._finishIfDone(j, time.Now())
+}
+
+// dispatched marks the route paths as in flight for all waiting jobs.
+func (r *jobRegistry) dispatched(routePaths []string) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	now := time.Now()
+	for _, routePath := range routePaths {
+		jobs := r.waiting[routePath]
+		if len(jobs) == 0 {
+			continue
+		}
+		delete(r.waiting, routePath)
+
+		for _, j := range jobs {
+			status := j.paths[routePath]
+			status.State = PathStateInFlight
+			status.UpdatedAt = now
+		}
+		r.inFlight[routePath] = append(r.inFlight[routePath], jobs...)
+	}
+}
+
+// completed sets the result of dispatched route paths for all jobs that wait for the result.
+func (r *jobRegistry) completed(results map[string]error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	now := time.Now()
+	for routePath, err := range results {
+		jobs := r.inFlight[routePath]
+		delete(r.inFlight, routePath)
+
+		for _, j := range jobs {
+			r._setPathResult(j, routePath, err, now)
+		}
+	}
+}
+
+// canceled fails route paths for all jobs that wait for the route paths to be dispatched.
+func (r *jobRegistry) canceled(routePaths []string, err error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	now := time.Now()
+	for _, routePath := range routePaths {
+		jobs := r.waiting[routePath]
+		delete(r.waiting, routePath)
+
+		for _, j := range jobs {
+			r._setPathResult(j, routePath, err, now)
+		}
+	}
+}
+
+func (r *jobRegistry) _setPathResult(j *job, routePath string, err error, now time.Time) {
+	status := j.paths[routePath]
+	if status.State == PathStateDone || status.State == PathStateFailed {
+		return
+	}
+
+	status.UpdatedAt = now
+	if err != nil {
+		status.State = PathStateFailed
+		status.Error = err.Error()
+	} else {
+		status.State = PathStateDone
+	}
+	j.pending--
+
+	r._finishIfDone(j, now)
+}
+
+func (r *jobRegistry) _finishIfDone(j *job, now time.Time) {
+	if j.finished() {
+		return
+	}
+	if j.err == nil && (!j.started || j.pending > 0) {
+		return
+	}
+	j.finishedAt = now
+	close(j.done)
+
+	if r.onFinished != nil {
+		r.onFinished(r._status(j))
+	}
+}
+
+func (r *jobRegistry) _removeOldJobs() {
+	// Jobs are ordered by creation, so only the oldest jobs need to be checked
+	now := time.Now()
+	for _, id := range r.ids {
+		j := r.jobs[id]
+		if now.Sub(j.createdAt) < r.maxAge {
+			break
+		}
+		r._expire(j, now)
+	}
+
+	for i := 0; len(r.jobs) > r.maxJobs && i < len(r.ids); {
+		j := r.jobs[r.ids[i]]
+		if !j.finished() {
+			i++
+			continue
+		}
+		delete(r.jobs, j.id)
+		r.ids = append(r.ids[:i], r.ids[i+1:]...)
+	}
+}
+
+// _expire fails all route paths of an unfinished job that have no result yet.
+func (r *jobRegistry) _expire(j *job, now time.Time) {
+	if j.finished() {
+		return
+	}
+
+	for routePath, status := range j.paths {
+		if status.State == PathStateDone || status.State == PathStateFailed {
+			continue
+		}
+		r.waiting[routePath] = removeJob(r.waiting[routePath], j)
+		if len(r.waiting[routePath]) == 0 {
+			delete(r.waiting, routePath)
+		}
+		r.inFlight[routePath] = removeJob(r.inFlight[routePath], j)
+		if len(r.inFlight[routePath]) == 0 {
+			delete(r.inFlight, routePath)
+		}
+	}
+	if !j.started {
+		j.err = errExpired
+	}
+	for routePath := range j.paths {
+		r._setPathResult(j, routePath, errExpired, now)
+	}
+	// The job is finished by the last route path, unless it had none
+	r._finishIfDone(j, now)
+}
+
+func removeJob(jobs []*job, j *job) []*job {
+	for i, other := range jobs {
+		if other == j {
+			return append(jobs[:i], jobs[i+1:]...)
+		}
+	}
+	return jobs
+}
+
+// status returns the status of a job or nil if the job does not exist.
+func (r *jobRegistry) status(id string) *JobStatus {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	j := r.jobs[id]
+	if j == nil {
+		return nil
+	}
+	return r._status(j)
+}
+
+func (r *jobRegistry) _status(j *job) *JobStatus {
+	status := &JobStatus{
+		ID:        j.id,
+		CreatedAt: j.createdAt,
+		Paths:     make([]JobPathStatus, 0, len(j.paths)),
+	}
+
+	failed := false
+	for _, pathStatus := range j.paths {
+		status.Paths = append(status.Paths, *pathStatus)
+		if pathStatus.State == PathStateFailed {
+			failed = true
+		}
+	}
+	sort.Slice(status.Paths, func(i, k int) bool {
+		return status.Paths[i].RoutePath < status.Paths[k].RoutePath
+	})
+
+	switch {
+	case j.err != nil:
+		status.State = JobStateFailed
+		status.Error = j.err.Error()
+	case !j.started:
+		status.State = JobStatePending
+	case !j.finished():
+		status.State = JobStateRunning
+	case failed:
+		status.State = JobStateFailed
+	default:
+		status.State = JobStateDone
+	}
+	if j.finished() {
+		finishedAt := j.finishedAt
+		status.FinishedAt = &finishedAt
+	}
+
+	return status
+}
+
+func newJobID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,102 @@
+package grazer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func Test_jobRegistry(t *testing.T) {
+	t.Run("path states", func(t *testing.T) {
+		r := newJobRegistry(10, time.Hour)
+		j := r.create()
+		assert.Equal(t, JobStatePending, r.status(j.id).State)
+
+		r.register(j, []string{"/about", "/contact"})
+		assert.Equal(t, JobStateRunning, r.status(j.id).State)
+
+		r.dispatched([]string{"/about"})
+		status := r.status(j.id)
+		require.Len(t, status.Paths, 2)
+		assert.Equal(t, PathStateInFlight, status.Paths[0].State)
+		assert.Equal(t, PathStateQueued, status.Paths[1].State)
+
+		r.completed(map[string]error{"/about": nil})
+		r.dispatched([]string{"/contact"})
+		r.completed(map[string]error{"/contact": errors.New("unexpected status code: 500")})
+
+		status = r.status(j.id)
+		assert.Equal(t, JobStateFailed, status.State)
+		assert.Equal(t, PathStateDone, status.Paths[0].State)
+		assert.Equal(t, PathStateFailed, status.Paths[1].State)
+		assert.NotNil(t, status.FinishedAt)
+
+		select {
+		case <-j.done:
+		default:
+			t.Fatal("expected job to be done")
+		}
+	})
+
+	t.Run("in flight path is not completed for later jobs", func(t *testing.T) {
+		r := newJobRegistry(10, time.Hour)
+		j1 := r.create()
+		r.register(j1, []string{"/about"})
+		r.dispatched([]string{"/about"})
+
+		j2 := r.create()
+		r.register(j2, []string{"/about"})
+		r.completed(map[string]error{"/about": nil})
+
+		assert.Equal(t, JobStateDone, r.status(j1.id).State)
+		assert.Equal(t, JobStateRunning, r.status(j2.id).State)
+	})
+
+	t.Run("removes oldest finished jobs", func(t *testing.T) {
+		r := newJobRegistry(2, time.Hour)
+		j1 := r.create()
+		r.register(j1, nil)
+		j2 := r.create()
+		j3 := r.create()
+
+		assert.Nil(t, r.status(j1.id))
+		assert.NotNil(t, r.status(j2.id))
+		assert.NotNil(t, r.status(j3.id))
+	})
+
+	t.Run("expires unfinished jobs", func(t *testing.T) {
+		r := newJobRegistry(3, time.Hour)
+		j1 := r.create()
+		r.register(j1, []string{"/about", "/contact"})
+		r.dispatched([]string{"/contact"})
+		j2 := r.create()
+		j1.createdAt = time.Now().Add(-2 * time.Hour)
+		j2.createdAt = j1.createdAt
+
+		// A job that is never revalidated fails after the maximum age
+		j3 := r.create()
+		status := r.status(j1.id)
+		assert.Equal(t, JobStateFailed, status.State)
+		require.Len(t, status.Paths, 2)
+		assert.Equal(t, "expired", status.Paths[0].Error)
+		assert.Equal(t, "expired", status.Paths[1].Error)
+		assert.Equal(t, JobStateFailed, r.status(j2.id).State)
+		assert.Empty(t, r.waiting)
+		assert.Empty(t, r.inFlight)
+
+		select {
+		case <-j1.done:
+		default:
+			t.Fatal("expected job to be done")
+		}
+
+		// Expired jobs are removed like finished jobs
+		r.create()
+		assert.Nil(t, r.status(j1.id))
+		assert.NotNil(t, r.status(j2.id))
+		assert.NotNil(t, r.status(j3.id))
+	})
+}
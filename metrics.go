@@ -0,0 +1,21 @@
+package grazer
+
+import (
+	"expvar"
+)
+
+// metrics are published as expvar variables and served by the handler at /debug/vars.
+var metrics = expvar.NewMap("grazer")
+
+const (
+	// metricFilteredRoutePaths counts route paths that were filtered by path rules
+	metricFilteredRoutePaths = "filteredRoutePaths"
+	// metricDroppedEvents counts events that were dropped for slow subscribers
+	metricDroppedEvents = "droppedEvents"
+	// metricWebhookDeliveries counts successful webhook deliveries
+	metricWebhookDeliveries = "webhookDeliveries"
+	// metricWebhookFailedDeliveries counts webhook deliveries that failed after all retries
+	metricWebhookFailedDeliveries = "webhookFailedDeliveries"
+	// metricWebhookDroppedBatches counts batches of events dropped because the delivery queue of a webhook was full
+	metricWebhookDroppedBatches = "webhookDroppedBatches"
+)
@@ -0,0 +1,21 @@
+package grazer
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// OpenAPISpec is the OpenAPI document of the grazer API.
+//
+//go:embed openapi.json
+var OpenAPISpec []byte
+
+// handleOpenAPI serves the OpenAPI document, it does not need a token.
+func (h *Handler) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(OpenAPISpec)
+}
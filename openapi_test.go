@@ -0,0 +1,33 @@
+package grazer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func TestHandler_openAPI(t *testing.T) {
+	h := NewHandler(HandlerOpts{
+		Revalidator:    NewRevalidator(RevalidatorOpts{URL: "http://localhost"}),
+		DocumentSource: staticDocuments{},
+	})
+	defer h.ShutdownAndWait()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var spec struct {
+		OpenAPI string                     `json:"openapi"`
+		Paths   map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&spec))
+	assert.Equal(t, "3.0.3", spec.OpenAPI)
+	for _, path := range []string{"/api/revalidate", "/api/full-revalidate", "/api/jobs/{id}", "/api/history", "/api/history/last", "/api/status", "/api/queue/{action}"} {
+		assert.Contains(t, spec.Paths, path)
+	}
+}
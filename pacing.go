@@ -0,0 +1,97 @@
+package grazer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SweepPacing spreads the route paths of a full revalidation over a target duration to avoid load spikes on the targets.
+// Invalidated route paths are never paced.
+type SweepPacing struct {
+	// Duration is the target duration of a full revalidation, the dispatch rate is derived from the number of route paths (not paced if 0)
+	Duration time.Duration
+	// QuietHours are daily windows in local time in which the sweep is slowed down by QuietFactor
+	QuietHours []TimeWindow
+	// QuietFactor multiplies the delay between sweep batches during quiet hours (4 if 0)
+	QuietFactor float64
+}
+
+// TimeWindow is a daily window between two times of day, it wraps around midnight if End is before Start.
+type TimeWindow struct {
+	// Start and End are offsets since midnight
+	Start time.Duration
+	End   time.Duration
+}
+
+// ParseTimeWindow parses a window in the format "HH:MM-HH:MM" (e.g. "22:00-06:00").
+func ParseTimeWindow(s string) (TimeWindow, error) {
+	start, end, ok := strings.Cut(s, "-")
+	if !ok {
+		return TimeWindow{}, fmt.Errorf("invalid time window %q, expected HH:MM-HH:MM", s)
+	}
+
+	startOffset, err := parseTimeOfDay(strings.TrimSpace(start))
+	if err != nil {
+		return TimeWindow{}, err
+	}
+	endOffset, err := parseTimeOfDay(strings.TrimSpace(end))
+	if err != nil {
+		return TimeWindow{}, err
+	}
+	return TimeWindow{
+		Start: startOffset,
+		End:   endOffset,
+	}, nil
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (w TimeWindow) String() string {
+	format := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return format(w.Start) + "-" + format(w.End)
+}
+
+// contains checks if the time of day of t is in the window.
+func (w TimeWindow) contains(t time.Time) bool {
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if w.Start <= w.End {
+		return offset >= w.Start && offset < w.End
+	}
+	return offset >= w.Start || offset < w.End
+}
+
+func (p SweepPacing) enabled() bool {
+	return p.Duration > 0
+}
+
+// quiet checks if t is in any of the quiet hours.
+func (p SweepPacing) quiet(t time.Time) bool {
+	for _, w := range p.QuietHours {
+		if w.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns the delay after dispatching count route paths of a sweep with total route paths at time t.
+func (p SweepPacing) delay(count, total int, t time.Time) time.Duration {
+	if !p.enabled() || total == 0 {
+		return 0
+	}
+
+	delay := p.Duration * time.Duration(count) / time.Duration(total)
+	if p.quiet(t) {
+		delay = time.Duration(float64(delay) * p.QuietFactor)
+	}
+	return delay
+}
@@ -0,0 +1,106 @@
+package grazer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func TestParseTimeWindow(t *testing.T) {
+	w, err := ParseTimeWindow("22:00-06:30")
+	require.NoError(t, err)
+	assert.Equal(t, "22:00-06:30", w.String())
+
+	day := func(hour, min int) time.Time {
+		return time.Date(2023, 1, 1, hour, min, 0, 0, time.Local)
+	}
+	assert.True(t, w.contains(day(23, 0)))
+	assert.True(t, w.contains(day(6, 29)))
+	assert.False(t, w.contains(day(6, 30)))
+	assert.False(t, w.contains(day(12, 0)))
+
+	w, err = ParseTimeWindow("08:00-18:00")
+	require.NoError(t, err)
+	assert.True(t, w.contains(day(8, 0)))
+	assert.False(t, w.contains(day(18, 0)))
+
+	_, err = ParseTimeWindow("08:00")
+	assert.Error(t, err)
+	_, err = ParseTimeWindow("8-18")
+	assert.Error(t, err)
+}
+
+func TestSweepPacing_delay(t *testing.T) {
+	quiet, _ := ParseTimeWindow("00:00-12:00")
+	p := SweepPacing{
+		Duration:    time.Hour,
+		QuietHours:  []TimeWindow{quiet},
+		QuietFactor: 4,
+	}
+	noon := time.Date(2023, 1, 1, 12, 0, 0, 0, time.Local)
+
+	assert.Equal(t, time.Minute, p.delay(1, 60, noon))
+	assert.Equal(t, 2*time.Minute, p.delay(2, 60, noon))
+	assert.Equal(t, 4*time.Minute, p.delay(1, 60, noon.Add(-time.Hour)))
+	assert.Equal(t, time.Duration(0), SweepPacing{}.delay(1, 60, noon))
+}
+
+func TestHandler_pacedSweep(t *testing.T) {
+	var (
+		mx       sync.Mutex
+		received []string
+	)
+	next := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body revalidateRequestBody
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mx.Lock()
+		defer mx.Unlock()
+		for _, document := range body.Documents {
+			received = append(received, document.RoutePath)
+		}
+	}))
+	defer next.Close()
+
+	h := NewHandler(HandlerOpts{
+		RevalidateToken: "a-token",
+		Revalidator:     NewRevalidator(RevalidatorOpts{URL: next.URL}),
+		DocumentSource:  staticDocuments{{RoutePath: "/a"}, {RoutePath: "/b"}, {RoutePath: "/c"}},
+		SweepPacing:     SweepPacing{Duration: 30 * time.Second},
+	})
+	defer h.ShutdownAndWait()
+
+	receivedPaths := func() []string {
+		mx.Lock()
+		defer mx.Unlock()
+		return append([]string(nil), received...)
+	}
+
+	require.NoError(t, h.FullRevalidate(context.Background()))
+	// The first sweep batch is sent immediately, the next one after 10 seconds
+	require.Eventually(t, func() bool {
+		return len(receivedPaths()) == 1
+	}, time.Second, 5*time.Millisecond)
+	status := h.Status(context.Background())
+	require.NotNil(t, status.Queue.NextSweepAt)
+	assert.True(t, status.Queue.NextSweepAt.After(time.Now().Add(5*time.Second)))
+
+	// Invalidations bypass the pacing
+	req := httptest.NewRequest(http.MethodPost, "/api/revalidate", strings.NewReader(`{"documents":[{"routePath":"/x"}],"skipFullRevalidate":true}`))
+	req.Header.Set("Authorization", "Bearer a-token")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	require.Eventually(t, func() bool {
+		return len(receivedPaths()) == 2
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"/a", "/x"}, receivedPaths())
+}
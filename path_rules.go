@@ -0,0 +1,177 @@
+package grazer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PathRule includes or excludes route paths matching a glob or regular expression pattern.
+type PathRule struct {
+	Exclude bool
+	// Pattern is a glob ("*" matches within a path segment, "**" across segments) or a regular expression with a "re:" prefix
+	Pattern string
+	// Schedules restricts the rule to revalidations of the named schedules (all revalidations if empty)
+	Schedules []string
+	// Targets restricts the rule to the named revalidation targets (all targets if empty)
+	Targets []string
+
+	matcher *regexp.Regexp
+}
+
+// ParsePathRule parses a rule in the format "<pattern>[;schedule=<name>][;target=<name>]".
+// Scopes can be repeated to apply the rule to multiple schedules or targets.
+// Invalidations and full revalidations without a schedule use an empty schedule name.
+func ParsePathRule(s string, exclude bool) (PathRule, error) {
+	parts := strings.Split(s, ";")
+
+	rule, err := NewPathRule(strings.TrimSpace(parts[0]), exclude)
+	if err != nil {
+		return PathRule{}, err
+	}
+
+	for _, part := range parts[1:] {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return PathRule{}, fmt.Errorf("invalid scope %q, expected key=value", part)
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "schedule":
+			rule.Schedules = append(rule.Schedules, value)
+		case "target":
+			rule.Targets = append(rule.Targets, value)
+		default:
+			return PathRule{}, fmt.Errorf("unknown scope %q", key)
+		}
+	}
+
+	return rule, nil
+}
+
+// NewPathRule creates a rule for all schedules and targets.
+func NewPathRule(pattern string, exclude bool) (PathRule, error) {
+	matcher, err := compilePattern(pattern)
+	if err != nil {
+		return PathRule{}, err
+	}
+	return PathRule{
+		Exclude: exclude,
+		Pattern: pattern,
+		matcher: matcher,
+	}, nil
+}
+
+func (r PathRule) String() string {
+	var sb strings.Builder
+	if r.Exclude {
+		sb.WriteString("exclude ")
+	} else {
+		sb.WriteString("include ")
+	}
+	sb.WriteString(r.Pattern)
+	for _, schedule := range r.Schedules {
+		sb.WriteString(";schedule=")
+		sb.WriteString(schedule)
+	}
+	for _, target := range r.Targets {
+		sb.WriteString(";target=")
+		sb.WriteString(target)
+	}
+	return sb.String()
+}
+
+// Match returns true if the route path matches the pattern of the rule.
+func (r PathRule) Match(routePath string) bool {
+	return r.matcher != nil && r.matcher.MatchString(routePath)
+}
+
+func (r PathRule) appliesTo(schedule, target string) bool {
+	return (len(r.Schedules) == 0 || containsString(r.Schedules, schedule)) &&
+		(len(r.Targets) == 0 || containsString(r.Targets, target))
+}
+
+// PathRules decide which route paths are revalidated.
+type PathRules []PathRule
+
+// allowed checks if a route path is revalidated for the given schedule and target.
+// If include rules apply, the route path must match at least one of them. It must not match any applying exclude rule.
+// The returned reason describes why the route path was filtered.
+func (rules PathRules) allowed(routePath, schedule, target string) (ok bool, reason string) {
+	hasIncludes := false
+	included := false
+	for _, rule := range rules {
+		if !rule.appliesTo(schedule, target) {
+			continue
+		}
+		if rule.Exclude {
+			if rule.Match(routePath) {
+				return false, rule.String()
+			}
+			continue
+		}
+		hasIncludes = true
+		if !included && rule.Match(routePath) {
+			included = true
+		}
+	}
+	if hasIncludes && !included {
+		return false, "no include rule matched"
+	}
+	return true, ""
+}
+
+// compilePattern compiles a glob or a regular expression with a "re:" prefix to a regular expression.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+
+	if expr, ok := strings.CutPrefix(pattern, "re:"); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %w", expr, err)
+		}
+		return re, nil
+	}
+
+	// A glob that does not start with a slash or wildcard never matches a route path, e.g. the rest of a split value
+	if pattern[0] != '/' && pattern[0] != '*' {
+		return nil, fmt.Errorf("glob %q must start with / or *", pattern)
+	}
+
+	return regexp.MustCompile(globToRegexp(pattern)), nil
+}
+
+// globToRegexp converts a glob to an anchored regular expression: "**" matches any characters, "*" and "?" match any
+// characters or a single character except "/".
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				sb.WriteString(".*")
+				i++
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
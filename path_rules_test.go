@@ -0,0 +1,52 @@
+package grazer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func TestPathRules_allowed(t *testing.T) {
+	mustParse := func(s string, exclude bool) PathRule {
+		t.Helper()
+		rule, err := ParsePathRule(s, exclude)
+		require.NoError(t, err)
+		return rule
+	}
+
+	rules := PathRules{
+		mustParse("/archive/**", true),
+		mustParse("re:^/events/\\d+$;schedule=nightly", true),
+		mustParse("/search;target=search", false),
+	}
+
+	tests := []struct {
+		routePath string
+		schedule  string
+		target    string
+		expected  bool
+	}{
+		{"/about", "", "next", true},
+		{"/archive", "", "next", true},
+		{"/archive/2020/01", "", "next", false},
+		{"/events/123", "", "next", true},
+		{"/events/123", "nightly", "next", false},
+		{"/events/123/details", "nightly", "next", true},
+		{"/search", "", "search", true},
+		{"/about", "", "search", false},
+	}
+	for _, tt := range tests {
+		ok, _ := rules.allowed(tt.routePath, tt.schedule, tt.target)
+		assert.Equal(t, tt.expected, ok, "%s (schedule %q, target %q)", tt.routePath, tt.schedule, tt.target)
+	}
+}
+
+func Test_globToRegexp(t *testing.T) {
+	rule, err := NewPathRule("/blog/*/comments", false)
+	require.NoError(t, err)
+
+	assert.True(t, rule.Match("/blog/post-1/comments"))
+	assert.False(t, rule.Match("/blog/2023/post-1/comments"))
+	assert.False(t, rule.Match("/blog/post-1/comments/1"))
+}
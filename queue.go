@@ -7,7 +7,9 @@ import (
 
 func newQueue() *queue {
 	q := &queue{
-		q:       make(queueItems, 0),
+		q: queueItems{
+			items: make([]*queueItem, 0),
+		},
 		pathIdx: make(map[string]*queueItem),
 	}
 
@@ -22,11 +24,43 @@ type queue struct {
 	currentPriority uint64
 	q               queueItems
 	pathIdx         map[string]*queueItem
+	// sweepLen is the number of items with zero priority
+	sweepLen int
 }
 
-// enqueue adds the given route paths to the queue.
-// The invalidatedRoutePaths are added with a higher priority than allRoutePaths.
-func (q *queue) enqueue(invalidatedRoutePaths []string, allRoutePaths []string) {
+// setOrder sets the order of items in the zero-priority tier.
+func (q *queue) setOrder(order SweepOrder) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+
+	q.q.order = order
+	heap.Init(&q.q)
+}
+
+// queueTier orders invalidated items before the priority of the invalidation, a lower tier is more urgent.
+type queueTier int
+
+const (
+	tierUrgent queueTier = -1
+	tierNormal queueTier = 0
+	tierLow    queueTier = 1
+)
+
+// queueEntry is a document in the queue with an optional restriction of revalidation targets.
+type queueEntry struct {
+	document DocumentsItem
+	// targets restricts the revalidation to the named targets, nil means all targets
+	targets []string
+	// tier of an invalidated entry
+	tier queueTier
+	// priority of the entry when it was popped from the queue
+	priority uint64
+}
+
+// enqueueEntries adds the given entries to the queue.
+// The invalidated entries are added with a higher priority than all entries, which are ordered by the sweep order.
+// Invalidated entries are ordered by their tier first.
+func (q *queue) enqueueEntries(invalidated []queueEntry, all []queueEntry) {
 	q.mx.Lock()
 	defer q.mx.Unlock()
 
@@ -34,39 +68,152 @@ func (q *queue) enqueue(invalidatedRoutePaths []string, allRoutePaths []string)
 	q.currentPriority++
 	prio := q.currentPriority
 
-	for _, routePath := range invalidatedRoutePaths {
-		q._addOrUpdate(routePath, prio)
+	for _, entry := range invalidated {
+		q._addOrUpdate(entry, prio)
 	}
 
-	for _, routePath := range allRoutePaths {
-		q._addOrUpdate(routePath, 0)
+	for _, entry := range all {
+		q._addOrUpdate(entry, 0)
 	}
 }
 
-func (q *queue) pop() *string {
+func (q *queue) popEntry() *queueEntry {
 	q.mx.Lock()
 	defer q.mx.Unlock()
 
-	if len(q.q) == 0 {
+	if q.q.Len() == 0 {
 		return nil
 	}
+	return q._pop()
+}
+
+// popInvalidatedEntry pops the next entry if it is not a zero-priority entry.
+func (q *queue) popInvalidatedEntry() *queueEntry {
+	q.mx.Lock()
+	defer q.mx.Unlock()
 
+	if q.q.Len() == 0 || q.q.Len() == q.sweepLen {
+		return nil
+	}
+	return q._pop()
+}
+
+func (q *queue) _pop() *queueEntry {
 	item := heap.Pop(&q.q).(*queueItem)
 
 	delete(q.pathIdx, item.routePath)
+	if item.priority == 0 {
+		q.sweepLen--
+	}
 
-	return &item.routePath
+	return &queueEntry{
+		document: item.document,
+		targets:  item.targets,
+		tier:     item.tier,
+		priority: item.priority,
+	}
 }
 
-func (q *queue) _addOrUpdate(routePath string, prio uint64) {
+// len returns the number of all items and the number of items with zero priority.
+func (q *queue) len() (total int, sweep int) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+
+	return q.q.Len(), q.sweepLen
+}
+
+// snapshot returns up to limit entries in the order they will be popped.
+// Only the first limit entries are selected, so large queues are not copied and sorted as a whole.
+func (q *queue) snapshot(limit int) []queueEntry {
+	if limit <= 0 {
+		return nil
+	}
+
+	q.mx.Lock()
+	top := &snapshotItems{order: q.q.order}
+	for _, item := range q.q.items {
+		if len(top.items) < limit {
+			heap.Push(top, item)
+		} else if top.order.before(item, top.items[0]) {
+			top.items[0] = item
+			heap.Fix(top, 0)
+		}
+	}
+	// Copy the entries, items are modified by later updates of the queue
+	result := make([]queueEntry, len(top.items))
+	for i := len(result) - 1; i >= 0; i-- {
+		item := heap.Pop(top).(*queueItem)
+		result[i] = queueEntry{
+			document: item.document,
+			targets:  item.targets,
+			tier:     item.tier,
+			priority: item.priority,
+		}
+	}
+	q.mx.Unlock()
+
+	return result
+}
+
+// drop removes all items or only the zero-priority items if sweepOnly is set and returns the removed entries.
+func (q *queue) drop(sweepOnly bool) []queueEntry {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+
+	var (
+		dropped []queueEntry
+		kept    = make([]*queueItem, 0, len(q.q.items))
+	)
+	for _, item := range q.q.items {
+		if sweepOnly && item.priority != 0 {
+			item.index = len(kept)
+			kept = append(kept, item)
+			continue
+		}
+		delete(q.pathIdx, item.routePath)
+		dropped = append(dropped, queueEntry{
+			document: item.document,
+			targets:  item.targets,
+			tier:     item.tier,
+			priority: item.priority,
+		})
+	}
+
+	q.q.items = kept
+	q.sweepLen = 0
+	heap.Init(&q.q)
+
+	return dropped
+}
+
+func (q *queue) _addOrUpdate(entry queueEntry, prio uint64) {
+	document := entry.document
+	routePath := document.RoutePath
+
 	// Check for an existing item
 	existingItem := q.pathIdx[routePath]
 	if existingItem != nil {
+		existingItem.targets = mergeTargets(existingItem.targets, entry.targets)
+
+		// Documents from a listing carry metadata for ordering, invalidated route paths do not
+		if document.hasMetadata() {
+			existingItem.document = document
+			heap.Fix(&q.q, existingItem.index)
+		}
+
 		// We only need to update priority if the item had a zero priority, and it is non-zero now.
 		// If the item already had a non-zero priority, we don't want to reduce the priority
 		// (due to next invalidation having an increased priority) or make it zero.
 		if existingItem.priority == 0 && prio != 0 {
+			q.sweepLen--
 			existingItem.priority = prio
+			existingItem.tier = entry.tier
+			heap.Fix(&q.q, existingItem.index)
+		}
+		// A more urgent invalidation moves the item to its tier
+		if existingItem.priority != 0 && prio != 0 && entry.tier < existingItem.tier {
+			existingItem.priority = prio
+			existingItem.tier = entry.tier
 			heap.Fix(&q.q, existingItem.index)
 		}
 		return
@@ -75,64 +222,141 @@ func (q *queue) _addOrUpdate(routePath string, prio uint64) {
 	item := &queueItem{
 		priority:  prio,
 		routePath: routePath,
+		document:  document,
+		targets:   entry.targets,
+	}
+	if prio != 0 {
+		item.tier = entry.tier
 	}
 	heap.Push(&q.q, item)
 	q.pathIdx[routePath] = item
+	if prio == 0 {
+		q.sweepLen++
+	}
 }
 
 type queueItem struct {
 	// the priority of the item in the queue. A lower non-zero value means higher priority - while 0 means no priority.
 	priority  uint64
 	routePath string
-	index     int
+	// document with optional metadata for ordering in the zero-priority tier
+	document DocumentsItem
+	// targets restricts the revalidation to the named targets, nil means all targets
+	targets []string
+	// tier of an item with non-zero priority
+	tier  queueTier
+	index int
+}
+
+// mergeTargets returns the union of two target restrictions, where nil means all targets.
+func mergeTargets(a, b []string) []string {
+	if a == nil || b == nil {
+		return nil
+	}
+	result := append([]string(nil), a...)
+	for _, target := range b {
+		if !containsString(result, target) {
+			result = append(result, target)
+		}
+	}
+	return result
+}
+
+type queueItems struct {
+	items []*queueItem
+	order SweepOrder
 }
 
-type queueItems []*queueItem
+func (q *queueItems) Len() int {
+	return len(q.items)
+}
 
-func (q queueItems) Len() int {
-	return len(q)
+func (q *queueItems) Less(i, j int) bool {
+	return q.order.before(q.items[i], q.items[j])
 }
 
-func (q queueItems) Less(i, j int) bool {
-	pi := q[i].priority
-	pj := q[j].priority
+// before returns true if item a is popped before item b.
+func (o SweepOrder) before(a, b *queueItem) bool {
+	pa := a.priority
+	pb := b.priority
 
-	// Stable sort by route path
-	if pi == pj {
-		return q[i].routePath < q[j].routePath
+	// Order zero-priority items according to the sweep order
+	if pa == 0 && pb == 0 {
+		return o.compare(a.document, b.document) < 0
 	}
 
 	// Sort 0 always last
-	if pi == 0 {
+	if pa == 0 {
 		return false
 	}
-	if pj == 0 {
+	if pb == 0 {
 		return true
 	}
 
+	// Lower tier is more urgent
+	if a.tier != b.tier {
+		return a.tier < b.tier
+	}
+
+	if pa == pb {
+		// Stable sort by route path
+		return a.routePath < b.routePath
+	}
+
 	// Lower priority value means higher priority
-	return pi < pj
+	return pa < pb
 }
 
-func (q queueItems) Swap(i, j int) {
-	q[i], q[j] = q[j], q[i]
-	q[i].index = i
-	q[j].index = j
+func (q *queueItems) Swap(i, j int) {
+	q.items[i], q.items[j] = q.items[j], q.items[i]
+	q.items[i].index = i
+	q.items[j].index = j
 }
 
 func (q *queueItems) Push(x any) {
-	n := len(*q)
+	n := len(q.items)
 	item := x.(*queueItem)
 	item.index = n
-	*q = append(*q, item)
+	q.items = append(q.items, item)
 }
 
 func (q *queueItems) Pop() any {
-	old := *q
+	old := q.items
 	n := len(old)
 	item := old[n-1]
 	old[n-1] = nil  // avoid memory leak
 	item.index = -1 // for safety
-	*q = old[0 : n-1]
+	q.items = old[0 : n-1]
+	return item
+}
+
+// snapshotItems is a heap with the item that is popped last from the queue on top.
+// It does not update the index of items, since they are still part of the queue.
+type snapshotItems struct {
+	items []*queueItem
+	order SweepOrder
+}
+
+func (s *snapshotItems) Len() int {
+	return len(s.items)
+}
+
+func (s *snapshotItems) Less(i, j int) bool {
+	return s.order.before(s.items[j], s.items[i])
+}
+
+func (s *snapshotItems) Swap(i, j int) {
+	s.items[i], s.items[j] = s.items[j], s.items[i]
+}
+
+func (s *snapshotItems) Push(x any) {
+	s.items = append(s.items, x.(*queueItem))
+}
+
+func (s *snapshotItems) Pop() any {
+	old := s.items
+	n := len(old)
+	item := old[n-1]
+	s.items = old[0 : n-1]
 	return item
 }
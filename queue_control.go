@@ -0,0 +1,202 @@
+package grazer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/apex/log"
+)
+
+// errCanceled is the error of job route paths that were dropped from the queue.
+var errCanceled = errors.New("canceled")
+
+// QueueControlResponse is the result of a queue control.
+type QueueControlResponse struct {
+	Paused bool `json:"paused"`
+	// Dropped is the number of route paths dropped from the queue
+	Dropped int `json:"dropped"`
+	// Length is the number of route paths in the queue after the control
+	Length int `json:"length"`
+}
+
+// isPaused returns true if dispatching of the queue is paused.
+func (c *controller) isPaused() bool {
+	c.pausedMx.RLock()
+	defer c.pausedMx.RUnlock()
+
+	return c.paused
+}
+
+// pause stops dispatching of the queue after the current batch, new route paths are still enqueued.
+func (c *controller) pause() {
+	c.pausedMx.Lock()
+	changed := !c.paused
+	c.paused = true
+	c.pausedMx.Unlock()
+
+	if changed {
+		log.
+			WithField("component", "controller").
+			Info("Paused queue processing")
+		c.events.publish(Event{Type: EventQueuePaused})
+	}
+}
+
+// resume continues dispatching of the queue.
+func (c *controller) resume() {
+	c.pausedMx.Lock()
+	changed := c.paused
+	c.paused = false
+	c.pausedMx.Unlock()
+
+	if changed {
+		log.
+			WithField("component", "controller").
+			Info("Resumed queue processing")
+		c.events.publish(Event{Type: EventQueueResumed})
+	}
+
+	c.ensureProcessQueue()
+}
+
+// delayedInvalidation is an invalidation waiting for its notBefore time.
+type delayedInvalidation struct {
+	// documents is the number of invalidated documents of the request
+	documents int
+	// canceled is closed if the invalidation was dropped
+	canceled chan struct{}
+}
+
+// delay registers an invalidation that waits for its notBefore time, the returned channel is closed if it is dropped meanwhile.
+func (c *controller) delay(req revalidateRequest) <-chan struct{} {
+	c.delayedMx.Lock()
+	defer c.delayedMx.Unlock()
+
+	d := &delayedInvalidation{
+		documents: len(req.invalidatedDocuments),
+		canceled:  make(chan struct{}),
+	}
+	c.delayed[req.job] = d
+	return d.canceled
+}
+
+// undelay removes a delayed invalidation before it is enqueued.
+// It returns false if the invalidation was dropped meanwhile and must not be enqueued.
+func (c *controller) undelay(j *job) bool {
+	c.delayedMx.Lock()
+	defer c.delayedMx.Unlock()
+
+	if _, ok := c.delayed[j]; !ok {
+		return false
+	}
+	delete(c.delayed, j)
+	return true
+}
+
+// dropDelayed cancels all delayed invalidations, fails their jobs and returns the number of their documents.
+func (c *controller) dropDelayed() int {
+	c.delayedMx.Lock()
+	delayed := c.delayed
+	c.delayed = make(map[*job]*delayedInvalidation)
+	c.delayedMx.Unlock()
+
+	dropped := 0
+	for j, d := range delayed {
+		close(d.canceled)
+		c.jobs.fail(j, errCanceled)
+		dropped += d.documents
+	}
+	return dropped
+}
+
+// drop removes route paths of full revalidations (or all route paths and delayed invalidations) from the queue and fails them for waiting jobs.
+func (c *controller) drop(sweepOnly bool) int {
+	dropped := c.queue.drop(sweepOnly)
+	c.jobs.canceled(entriesRoutePaths(dropped), errCanceled)
+
+	droppedCount := len(dropped)
+	if !sweepOnly {
+		droppedCount += c.dropDelayed()
+	}
+
+	c.sweepMx.Lock()
+	c.sweepTotal = 0
+	c.sweepDone = 0
+	c.sweepFailed = 0
+	c.sweepMx.Unlock()
+
+	eventType := EventQueueCanceled
+	if sweepOnly {
+		eventType = EventSweepDrained
+	}
+	log.
+		WithField("component", "controller").
+		WithField("dropped", droppedCount).
+		WithField("sweepOnly", sweepOnly).
+		Info("Dropped route paths from queue")
+	c.events.publish(Event{
+		Type:    eventType,
+		Dropped: droppedCount,
+	})
+
+	return droppedCount
+}
+
+// PauseQueue stops dispatching revalidations after the current batch, invalidations are still accepted and enqueued.
+func (h *Handler) PauseQueue() {
+	h.ctrl.pause()
+}
+
+// ResumeQueue continues dispatching revalidations.
+func (h *Handler) ResumeQueue() {
+	h.ctrl.resume()
+}
+
+// DrainSweep drops all route paths of full revalidations from the queue and returns the number of dropped route paths.
+// Invalidated route paths are kept.
+func (h *Handler) DrainSweep() int {
+	return h.ctrl.drop(true)
+}
+
+// CancelQueue drops all route paths from the queue and all delayed invalidations and returns the number of dropped route paths.
+// Documents of delayed invalidations are counted as requested, before patterns are expanded.
+func (h *Handler) CancelQueue() int {
+	return h.ctrl.drop(false)
+}
+
+// handleQueueControl handles POST /api/queue/{pause,resume,drain,cancel}.
+func (h *Handler) handleQueueControl(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r, ScopeAdminWrite) {
+		return
+	}
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var resp QueueControlResponse
+	switch action := strings.TrimPrefix(r.URL.Path, "/api/queue/"); action {
+	case "pause":
+		h.PauseQueue()
+	case "resume":
+		h.ResumeQueue()
+	case "drain":
+		resp.Dropped = h.DrainSweep()
+	case "cancel":
+		resp.Dropped = h.CancelQueue()
+	default:
+		writeError(w, http.StatusNotFound, ErrorResponse{
+			Code:    ErrorCodeNotFound,
+			Message: fmt.Sprintf("unknown action %q", action),
+		})
+		return
+	}
+
+	resp.Paused = h.ctrl.isPaused()
+	resp.Length, _ = h.ctrl.queue.len()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
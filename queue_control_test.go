@@ -0,0 +1,144 @@
+package grazer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func Test_queue_drop(t *testing.T) {
+	q := newQueue()
+	q.enqueue([]string{"/contact"}, []string{"/about", "/home"})
+
+	dropped := q.drop(true)
+	assert.Equal(t, []string{"/about", "/home"}, sortedRoutePaths(dropped))
+
+	total, sweep := q.len()
+	assert.Equal(t, 1, total)
+	assert.Equal(t, 0, sweep)
+
+	q.enqueue(nil, []string{"/about"})
+	assertPop(t, q, "/contact")
+	assertPop(t, q, "/about")
+	assert.Nil(t, q.pop())
+
+	q.enqueue([]string{"/contact"}, []string{"/about"})
+	assert.Len(t, q.drop(false), 2)
+	assert.Nil(t, q.pop())
+}
+
+func TestHandler_queueControls(t *testing.T) {
+	var (
+		mx          sync.Mutex
+		revalidated int
+	)
+	next := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Ignore health checks of the status
+		if r.Method != http.MethodPost {
+			return
+		}
+		mx.Lock()
+		revalidated++
+		mx.Unlock()
+	}))
+	defer next.Close()
+
+	h := NewHandler(HandlerOpts{
+		AdminToken:     "admin-token",
+		Revalidator:    NewRevalidator(RevalidatorOpts{URL: next.URL}),
+		DocumentSource: staticDocuments{{RoutePath: "/"}, {RoutePath: "/about"}},
+	})
+	defer h.ShutdownAndWait()
+
+	control := func(action string) QueueControlResponse {
+		req := httptest.NewRequest(http.MethodPost, "/api/queue/"+action, nil)
+		req.Header.Set("Authorization", "Bearer admin-token")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var resp QueueControlResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		return resp
+	}
+
+	resp := control("pause")
+	assert.True(t, resp.Paused)
+
+	// Documents are enqueued but not dispatched while paused
+	require.NoError(t, h.FullRevalidate(context.Background()))
+	status := h.Status(context.Background())
+	assert.True(t, status.Queue.Paused)
+	assert.Equal(t, 2, status.Queue.Length)
+
+	resp = control("drain")
+	assert.Equal(t, 2, resp.Dropped)
+	assert.Equal(t, 0, resp.Length)
+
+	require.NoError(t, h.FullRevalidate(context.Background()))
+
+	events, unsubscribe := h.Subscribe()
+	defer unsubscribe()
+
+	resp = control("resume")
+	assert.False(t, resp.Paused)
+
+	for event := range events {
+		if event.Type == EventQueueDrained {
+			break
+		}
+	}
+
+	mx.Lock()
+	defer mx.Unlock()
+	assert.Equal(t, 2, revalidated)
+}
+
+func TestHandler_cancelDelayedInvalidations(t *testing.T) {
+	h := NewHandler(HandlerOpts{
+		RevalidateToken: "a-token",
+		AdminToken:      "admin-token",
+		Revalidator:     NewRevalidator(RevalidatorOpts{URL: "http://localhost", DryRun: true}),
+		DocumentSource:  staticDocuments{{RoutePath: "/"}},
+	})
+	defer h.ShutdownAndWait()
+
+	notBefore := time.Now().Add(100 * time.Millisecond)
+	req := httptest.NewRequest(http.MethodPost, "/api/revalidate", strings.NewReader(`{"documents":[{"routePath":"/import/1"},{"routePath":"/import/2"}],"skipFullRevalidate":true,"notBefore":"`+notBefore.Format(time.RFC3339Nano)+`"}`))
+	req.Header.Set("Authorization", "Bearer a-token")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp revalidateResponseBody
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+
+	// Delayed invalidations are dropped with the queue
+	assert.Equal(t, 2, h.CancelQueue())
+
+	status := h.ctrl.jobs.status(resp.JobID)
+	require.NotNil(t, status)
+	assert.Equal(t, JobStateFailed, status.State)
+	assert.Equal(t, errCanceled.Error(), status.Error)
+
+	// Nothing is enqueued after notBefore
+	time.Sleep(time.Until(notBefore) + 50*time.Millisecond)
+	total, _ := h.ctrl.queue.len()
+	assert.Equal(t, 0, total)
+	_, recent := h.ctrl.tracker.snapshot()
+	assert.Empty(t, recent)
+}
+
+func sortedRoutePaths(entries []queueEntry) []string {
+	routePaths := entriesRoutePaths(entries)
+	sort.Strings(routePaths)
+	return routePaths
+}
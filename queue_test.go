@@ -1,12 +1,45 @@
 package grazer
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 	"github.com/tj/assert"
 )
 
+// enqueue adds the given route paths to the queue.
+// The invalidatedRoutePaths are added with a higher priority than allRoutePaths.
+func (q *queue) enqueue(invalidatedRoutePaths []string, allRoutePaths []string) {
+	allDocuments := make([]DocumentsItem, len(allRoutePaths))
+	for i, routePath := range allRoutePaths {
+		allDocuments[i] = DocumentsItem{RoutePath: routePath}
+	}
+	q.enqueueDocuments(invalidatedRoutePaths, allDocuments)
+}
+
+// enqueueDocuments adds the given route paths and documents to the queue for all targets.
+func (q *queue) enqueueDocuments(invalidatedRoutePaths []string, allDocuments []DocumentsItem) {
+	invalidatedEntries := make([]queueEntry, len(invalidatedRoutePaths))
+	for i, routePath := range invalidatedRoutePaths {
+		invalidatedEntries[i] = queueEntry{document: DocumentsItem{RoutePath: routePath}}
+	}
+	allEntries := make([]queueEntry, len(allDocuments))
+	for i, document := range allDocuments {
+		allEntries[i] = queueEntry{document: document}
+	}
+	q.enqueueEntries(invalidatedEntries, allEntries)
+}
+
+// pop removes the entry with the highest priority and returns its route path (nil if the queue is empty).
+func (q *queue) pop() *string {
+	entry := q.popEntry()
+	if entry == nil {
+		return nil
+	}
+	return &entry.document.RoutePath
+}
+
 func Test_queue_enqueue(t *testing.T) {
 	t.Run("simple", func(t *testing.T) {
 		q := newQueue()
@@ -63,6 +96,96 @@ func Test_queue_enqueue(t *testing.T) {
 	})
 }
 
+func Test_queue_enqueueEntries_tiers(t *testing.T) {
+	entries := func(tier queueTier, routePaths ...string) []queueEntry {
+		result := make([]queueEntry, len(routePaths))
+		for i, routePath := range routePaths {
+			result[i] = queueEntry{document: DocumentsItem{RoutePath: routePath}, tier: tier}
+		}
+		return result
+	}
+
+	q := newQueue()
+	q.enqueueEntries(entries(tierNormal, "/products/a", "/products/b"), entries(tierNormal, "/", "/about"))
+	q.enqueueEntries(entries(tierLow, "/import/1"), nil)
+	q.enqueueEntries(entries(tierUrgent, "/"), nil)
+	// A less urgent invalidation does not move an item to a lower tier
+	q.enqueueEntries(entries(tierLow, "/products/b"), nil)
+
+	assertPop(t, q, "/")
+	assertPop(t, q, "/products/a")
+	assertPop(t, q, "/products/b")
+	assertPop(t, q, "/import/1")
+	assertPop(t, q, "/about")
+	assert.Nil(t, q.pop())
+}
+
+func Test_queue_enqueueDocuments_sweepOrder(t *testing.T) {
+	rules, err := ParseSweepOrder("priority,depth")
+	require.NoError(t, err)
+
+	q := newQueue()
+	q.setOrder(SweepOrder{
+		Rules: rules,
+		NodeTypePriorities: map[string]float64{
+			"Neos.Neos:Shortcut": -1,
+		},
+	})
+	q.enqueueDocuments([]string{"/contact"}, []DocumentsItem{
+		{RoutePath: "/about", Depth: intPtr(1)},
+		{RoutePath: "/", Depth: intPtr(0), Priority: floatPtr(10)},
+		{RoutePath: "/zz-archive", Depth: intPtr(1), Priority: floatPtr(1)},
+		{RoutePath: "/shortcut", NodeType: "Neos.Neos:Shortcut"},
+		{RoutePath: "/blog/post", Depth: intPtr(2)},
+		{RoutePath: "/contact", Depth: intPtr(1)},
+	})
+
+	assertPop(t, q, "/contact")
+	assertPop(t, q, "/")
+	assertPop(t, q, "/zz-archive")
+	assertPop(t, q, "/shortcut")
+	assertPop(t, q, "/about")
+	assertPop(t, q, "/blog/post")
+	assert.Nil(t, q.pop())
+}
+
+func Test_queue_snapshot(t *testing.T) {
+	q := newQueue()
+	var invalidated, all []queueEntry
+	for i := 0; i < 50; i++ {
+		routePath := fmt.Sprintf("/page-%02d", (i*17)%50)
+		switch i % 3 {
+		case 0:
+			invalidated = append(invalidated, queueEntry{document: DocumentsItem{RoutePath: routePath}, tier: tierLow})
+		case 1:
+			invalidated = append(invalidated, queueEntry{document: DocumentsItem{RoutePath: routePath}, tier: tierUrgent})
+		default:
+			all = append(all, queueEntry{document: DocumentsItem{RoutePath: routePath}})
+		}
+	}
+	q.enqueueEntries(invalidated, all)
+
+	snapshot := q.snapshot(10)
+	assert.Empty(t, q.snapshot(0))
+	assert.Len(t, q.snapshot(100), 50)
+
+	// The snapshot has the order of popped entries and does not change the queue
+	require.Len(t, snapshot, 10)
+	for _, entry := range snapshot {
+		assertPop(t, q, entry.document.RoutePath)
+	}
+	length, _ := q.len()
+	assert.Equal(t, 40, length)
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
 func assertPop(t *testing.T, q *queue, expectedRoutePath string) {
 	t.Helper()
 
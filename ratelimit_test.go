@@ -0,0 +1,28 @@
+package grazer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func Test_rateLimiter(t *testing.T) {
+	assert.Nil(t, newRateLimiter(0))
+	assert.NoError(t, newRateLimiter(0).wait(context.Background()))
+
+	l := newRateLimiter(50)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, l.wait(context.Background()))
+	}
+	// The first request is not delayed, the next ones are spaced by 20ms
+	assert.True(t, time.Since(start) >= 40*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l = newRateLimiter(0.1)
+	assert.NoError(t, l.wait(ctx))
+	assert.Equal(t, context.Canceled, l.wait(ctx))
+}
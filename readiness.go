@@ -0,0 +1,150 @@
+package grazer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/apex/log"
+)
+
+type ReadinessOpts struct {
+	// NextHealthURL is probed with GET until it responds with a 2xx status (the targets are checked for reachability if empty)
+	NextHealthURL string
+	// Interval between probes of upstreams that are not ready (2 seconds if 0)
+	Interval time.Duration
+
+	Transport http.RoundTripper
+}
+
+// WaitReady probes the document source and Next.js until all of them respond or ctx is done.
+// The Neos content API must respond to a request for the documents with a 2xx status.
+// Upstreams are not probed again after they responded once. The error contains the last error of each upstream that was not ready.
+func (h *Handler) WaitReady(ctx context.Context, opts ReadinessOpts) error {
+	if opts.Interval == 0 {
+		opts.Interval = 2 * time.Second
+	}
+
+	var (
+		pending = h.readinessCheckers(opts)
+		// errs of the last probe that was not interrupted by ctx
+		errs []error
+	)
+	for {
+		probeCtx, cancel := context.WithTimeout(ctx, upstreamCheckTimeout)
+		statuses := checkUpstreams(probeCtx, pending)
+		cancel()
+		if ctx.Err() != nil && errs != nil {
+			return fmt.Errorf("upstreams not ready: %w", errors.Join(errs...))
+		}
+
+		var notReady []namedHealthChecker
+		errs = nil
+		for i, status := range statuses {
+			if status.Reachable {
+				log.
+					WithField("component", "readiness").
+					WithField("upstream", status.Name).
+					Info("Upstream is ready")
+				continue
+			}
+			log.
+				WithField("component", "readiness").
+				WithField("upstream", status.Name).
+				WithField("error", status.Error).
+				Debug("Upstream is not ready")
+			notReady = append(notReady, pending[i])
+			errs = append(errs, fmt.Errorf("%s: %s", status.Name, status.Error))
+		}
+		if len(notReady) == 0 {
+			return nil
+		}
+		pending = notReady
+
+		timer := time.NewTimer(opts.Interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("upstreams not ready: %w", errors.Join(errs...))
+		case <-timer.C:
+		}
+	}
+}
+
+// readinessCheckers returns checkers for the document source and the Next.js health URL or all targets.
+func (h *Handler) readinessCheckers(opts ReadinessOpts) []namedHealthChecker {
+	var checkers []namedHealthChecker
+	if checker := readinessCheckerOf(h.ctrl.source); checker != nil {
+		checkers = append(checkers, namedHealthChecker{
+			name:    "source",
+			checker: checker,
+		})
+	}
+
+	if opts.NextHealthURL != "" {
+		checkers = append(checkers, namedHealthChecker{
+			name: "next health",
+			checker: urlHealthChecker{
+				client: &http.Client{Transport: opts.Transport},
+				url:    opts.NextHealthURL,
+			},
+		})
+		return checkers
+	}
+
+	targets, _ := h.ctrl.config()
+	for _, target := range targets {
+		checkers = append(checkers, namedHealthChecker{
+			name:    fmt.Sprintf("target %s", target.Name()),
+			checker: target,
+		})
+	}
+	return checkers
+}
+
+// readinessCheckerOf returns a checker for the readiness of an upstream, it prefers CheckReady over CheckHealth.
+// It returns nil if the upstream cannot be checked.
+func readinessCheckerOf(upstream any) HealthChecker {
+	switch checker := upstream.(type) {
+	case ReadinessChecker:
+		return readyHealthChecker{checker}
+	case HealthChecker:
+		return checker
+	}
+	return nil
+}
+
+// readyHealthChecker uses CheckReady of an upstream as health check.
+type readyHealthChecker struct {
+	ReadinessChecker
+}
+
+func (c readyHealthChecker) CheckHealth(ctx context.Context) error {
+	return c.CheckReady(ctx)
+}
+
+// urlHealthChecker checks if a GET request to a health URL responds with a 2xx status.
+type urlHealthChecker struct {
+	client *http.Client
+	url    string
+}
+
+func (c urlHealthChecker) CheckHealth(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return fmt.Errorf("building request: %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
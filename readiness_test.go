@@ -0,0 +1,106 @@
+package grazer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func TestHandler_WaitReady(t *testing.T) {
+	var probes int32
+	next := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Ready after the third probe
+		if atomic.AddInt32(&probes, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer next.Close()
+
+	h := NewHandler(HandlerOpts{
+		Revalidator:    NewRevalidator(RevalidatorOpts{URL: "http://localhost"}),
+		DocumentSource: staticDocuments{{RoutePath: "/"}},
+	})
+	defer h.ShutdownAndWait()
+
+	err := h.WaitReady(context.Background(), ReadinessOpts{
+		NextHealthURL: next.URL,
+		Interval:      time.Millisecond,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&probes))
+
+	// The deadline is reached if Next.js never responds
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer unavailable.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = h.WaitReady(ctx, ReadinessOpts{
+		NextHealthURL: unavailable.URL,
+		Interval:      time.Millisecond,
+	})
+	require.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "next health: unexpected status code: 404"), err.Error())
+}
+
+func TestHandler_WaitReadyContentAPI(t *testing.T) {
+	var (
+		deployed int32
+		path     atomic.Value
+	)
+	neos := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path.Store(r.URL.Path)
+		// The content API is not installed during a deployment and rejects missing credentials
+		if atomic.LoadInt32(&deployed) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if username, password, ok := r.BasicAuth(); !ok || username != "neos" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"documents":[]}`))
+	}))
+	defer neos.Close()
+	next := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer next.Close()
+
+	transport, err := NewContentAPITransport(ContentAPIAuth{
+		BasicAuthUsername: "neos",
+		BasicAuthPassword: "secret",
+	}, nil)
+	require.NoError(t, err)
+
+	h := NewHandler(HandlerOpts{
+		Revalidator: NewRevalidator(RevalidatorOpts{URL: next.URL}),
+		Fetcher:     NewFetcher(FetcherOpts{NeosBaseURL: neos.URL, Transport: transport}),
+	})
+	defer h.ShutdownAndWait()
+
+	// A 404 of the content API is not ready, although Neos is reachable
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = h.WaitReady(ctx, ReadinessOpts{
+		NextHealthURL: next.URL,
+		Interval:      time.Millisecond,
+	})
+	require.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "source: unexpected status code: 404"), err.Error())
+	assert.Equal(t, "/neos/content-api/documents", path.Load())
+
+	atomic.StoreInt32(&deployed, 1)
+	err = h.WaitReady(context.Background(), ReadinessOpts{
+		NextHealthURL: next.URL,
+		Interval:      time.Millisecond,
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,451 @@
+package grazer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// refreshScheduleName is the schedule name of route paths enqueued by the rolling refresh for scoping path rules.
+const refreshScheduleName = "refresh"
+
+// RefreshOpts configures a rolling refresh, it continuously revalidates the route paths with the oldest successful revalidation.
+// The number of route paths per interval is derived from the number of documents and the maximum age, so the load on Next.js is even.
+type RefreshOpts struct {
+	// MaxAge is the maximum age of a route path since its last successful revalidation (disabled if 0)
+	MaxAge time.Duration
+	// Interval between listings of documents for enqueuing the oldest route paths (5 minutes if 0)
+	Interval time.Duration
+	// StateFile keeps the last successful revalidation of each route path across restarts (kept in memory only if empty)
+	StateFile string
+}
+
+func (o RefreshOpts) enabled() bool {
+	return o.MaxAge > 0
+}
+
+// RefreshStatus is the state of the rolling refresh.
+type RefreshStatus struct {
+	MaxAgeSeconds int64 `json:"maxAgeSeconds"`
+	// Documents is the number of listed route paths allowed by the path rules
+	Documents int `json:"documents"`
+	// Stale is the number of route paths that were not revalidated within the maximum age
+	Stale int `json:"stale"`
+	// Enqueued is the number of route paths selected by the last check, they are enqueued evenly over the interval
+	Enqueued int `json:"enqueued"`
+	// Oldest is the oldest successful revalidation of a listed route path (empty if any route path was never revalidated)
+	Oldest    *time.Time `json:"oldest,omitempty"`
+	CheckedAt *time.Time `json:"checkedAt,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// freshness records the last successful revalidation of each route path.
+type freshness struct {
+	mx            sync.RWMutex
+	revalidatedAt map[string]time.Time
+	// inFlight are route paths of dispatched batches, they are not enqueued again before the revalidation finished
+	inFlight map[string]struct{}
+}
+
+func newFreshness() *freshness {
+	return &freshness{
+		revalidatedAt: make(map[string]time.Time),
+		inFlight:      make(map[string]struct{}),
+	}
+}
+
+// dispatched marks route paths as in flight, it is a no-op on a nil freshness.
+func (f *freshness) dispatched(routePaths []string) {
+	if f == nil {
+		return
+	}
+
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	for _, routePath := range routePaths {
+		f.inFlight[routePath] = struct{}{}
+	}
+}
+
+// finished removes the in-flight mark of route paths, it is a no-op on a nil freshness.
+func (f *freshness) finished(routePaths []string) {
+	if f == nil {
+		return
+	}
+
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	for _, routePath := range routePaths {
+		delete(f.inFlight, routePath)
+	}
+}
+
+// revalidated records a successful revalidation, it is a no-op on a nil freshness.
+func (f *freshness) revalidated(routePath string, t time.Time) {
+	if f == nil {
+		return
+	}
+
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	f.revalidatedAt[routePath] = t
+}
+
+// retain removes route paths that are not listed anymore and returns the last revalidation of the listed route paths
+// together with the listed route paths that are in flight.
+func (f *freshness) retain(routePaths map[string]struct{}) (map[string]time.Time, map[string]struct{}) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	result := make(map[string]time.Time, len(routePaths))
+	for routePath, t := range f.revalidatedAt {
+		if _, ok := routePaths[routePath]; !ok {
+			delete(f.revalidatedAt, routePath)
+			continue
+		}
+		result[routePath] = t
+	}
+	inFlight := make(map[string]struct{}, len(f.inFlight))
+	for routePath := range f.inFlight {
+		if _, ok := routePaths[routePath]; ok {
+			inFlight[routePath] = struct{}{}
+		}
+	}
+	return result, inFlight
+}
+
+type freshnessState struct {
+	RevalidatedAt map[string]time.Time `json:"revalidatedAt"`
+}
+
+// load reads the state file, a missing file is not an error.
+func (f *freshness) load(filename string) error {
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading state file: %w", err)
+	}
+
+	var state freshnessState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("decoding state file: %w", err)
+	}
+
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	for routePath, t := range state.RevalidatedAt {
+		// Revalidations since the start are newer
+		if _, exists := f.revalidatedAt[routePath]; !exists {
+			f.revalidatedAt[routePath] = t
+		}
+	}
+	return nil
+}
+
+// save writes the state file by replacing it, so it is never read partially written.
+func (f *freshness) save(filename string) error {
+	f.mx.RLock()
+	data, err := json.Marshal(freshnessState{RevalidatedAt: f.revalidatedAt})
+	f.mx.RUnlock()
+	if err != nil {
+		return fmt.Errorf("encoding state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing temporary file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return fmt.Errorf("replacing state file: %w", err)
+	}
+	return nil
+}
+
+// refresher enqueues the route paths with the oldest successful revalidation in every interval.
+type refresher struct {
+	ctrl *controller
+	opts RefreshOpts
+
+	pendingMx sync.Mutex
+	// pending are route paths of the last check that were not enqueued yet
+	pending []queueEntry
+
+	statusMx sync.RWMutex
+	status   RefreshStatus
+}
+
+func newRefresher(ctrl *controller, opts RefreshOpts) *refresher {
+	if opts.Interval == 0 {
+		opts.Interval = 5 * time.Minute
+	}
+
+	r := &refresher{
+		ctrl: ctrl,
+		opts: opts,
+		status: RefreshStatus{
+			MaxAgeSeconds: int64(opts.MaxAge / time.Second),
+		},
+	}
+
+	if opts.StateFile != "" {
+		// The state only prevents unnecessary revalidations, so grazer starts without it
+		if err := ctrl.freshness.load(opts.StateFile); err != nil {
+			log.
+				WithField("component", "refresh").
+				WithField("file", opts.StateFile).
+				WithError(err).
+				Warn("Loading refresh state failed, starting without it")
+		}
+	}
+
+	return r
+}
+
+// run checks for stale route paths every interval until done is closed.
+func (r *refresher) run(done <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.check(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			log.
+				WithField("component", "refresh").
+				WithError(err).
+				Warn("Refreshing stale route paths failed")
+		}
+		r.saveState()
+
+		if !r.enqueuePending(ctx, ticker.C) {
+			return
+		}
+	}
+}
+
+// enqueuePending enqueues the route paths of the last check in batches evenly spread until the next check.
+// It returns false if ctx is done.
+func (r *refresher) enqueuePending(ctx context.Context, next <-chan time.Time) bool {
+	batchSize := r.ctrl.revalidateBatchSize
+	r.pendingMx.Lock()
+	batches := (len(r.pending) + batchSize - 1) / batchSize
+	r.pendingMx.Unlock()
+
+	pace := r.opts.Interval
+	if batches > 0 {
+		pace = r.opts.Interval / time.Duration(batches)
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-next:
+			return true
+		case <-timer.C:
+			if r.enqueueNext(batchSize) > 0 {
+				timer.Reset(pace)
+			}
+		}
+	}
+}
+
+// enqueueNext enqueues up to n pending route paths at low priority and returns the number of enqueued route paths.
+// They are not part of a full revalidation, so they are neither slowed down by sweep pacing nor counted in the sweep progress.
+func (r *refresher) enqueueNext(n int) int {
+	r.pendingMx.Lock()
+	if n > len(r.pending) {
+		n = len(r.pending)
+	}
+	entries := r.pending[:n]
+	r.pending = r.pending[n:]
+	r.pendingMx.Unlock()
+
+	if len(entries) == 0 {
+		return 0
+	}
+
+	r.ctrl.queue.enqueueEntries(entries, nil)
+	r.ctrl.events.publish(Event{
+		Type:        EventEnqueued,
+		Schedule:    refreshScheduleName,
+		Invalidated: len(entries),
+	})
+	r.ctrl.ensureProcessQueue()
+	return len(entries)
+}
+
+// check lists all documents and selects the route paths with the oldest revalidation, replacing the pending route paths of the previous check.
+// They are enqueued evenly over the interval at low priority by the run loop, so invalidations are not delayed and the load is even.
+// Route paths revalidated within half of the maximum age are skipped, since they are refreshed in time by a later check.
+func (r *refresher) check(ctx context.Context, now time.Time) error {
+	documentsResponse, err := r.ctrl.source.ListDocuments(ctx)
+	if err != nil {
+		err = fmt.Errorf("listing documents: %w", err)
+		r.setStatus(func(status *RefreshStatus) {
+			status.CheckedAt = &now
+			status.Error = err.Error()
+		})
+		return err
+	}
+	documents := documentsResponse.Documents
+
+	type candidate struct {
+		entry         queueEntry
+		revalidatedAt time.Time
+	}
+	var (
+		routePaths       = make(map[string]struct{}, len(documents))
+		candidates       []candidate
+		allowed          int
+		stale            int
+		oldest           time.Time
+		neverRevalidated bool
+	)
+	for _, document := range documents {
+		routePaths[document.RoutePath] = struct{}{}
+	}
+	revalidatedAt, inFlight := r.ctrl.freshness.retain(routePaths)
+
+	for _, document := range documents {
+		entry, ok := r.ctrl.filterEntry(document, refreshScheduleName)
+		if !ok {
+			continue
+		}
+		allowed++
+
+		t, revalidated := revalidatedAt[document.RoutePath]
+		if !revalidated {
+			neverRevalidated = true
+		} else if oldest.IsZero() || t.Before(oldest) {
+			oldest = t
+		}
+		age := now.Sub(t)
+		if !revalidated || age >= r.opts.MaxAge {
+			stale++
+		}
+		if revalidated && age < r.opts.MaxAge/2 {
+			continue
+		}
+		// The revalidation of a dispatched route path is recorded when its batch finished
+		if _, ok := inFlight[document.RoutePath]; ok {
+			continue
+		}
+
+		candidates = append(candidates, candidate{
+			entry:         entry,
+			revalidatedAt: t,
+		})
+	}
+
+	// Never revalidated route paths have a zero time and come first
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].revalidatedAt.Before(candidates[j].revalidatedAt)
+	})
+	if limit := r.limit(allowed); len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	entries := make([]queueEntry, len(candidates))
+	for i, c := range candidates {
+		entries[i] = c.entry
+		entries[i].tier = tierLow
+	}
+	if len(entries) > 0 {
+		log.
+			WithField("component", "refresh").
+			WithField("count", len(entries)).
+			WithField("stale", stale).
+			Info("Refreshing route paths with the oldest revalidation")
+	}
+
+	r.pendingMx.Lock()
+	r.pending = entries
+	r.pendingMx.Unlock()
+
+	r.setStatus(func(status *RefreshStatus) {
+		status.Documents = allowed
+		status.Stale = stale
+		status.Enqueued = len(entries)
+		status.Oldest = nil
+		if !neverRevalidated && !oldest.IsZero() {
+			status.Oldest = &oldest
+		}
+		status.CheckedAt = &now
+		status.Error = ""
+	})
+	return nil
+}
+
+// limit returns the number of route paths per interval to revalidate all route paths within the maximum age.
+func (r *refresher) limit(documents int) int {
+	intervals := int64(r.opts.MaxAge / r.opts.Interval)
+	if intervals < 1 {
+		return documents
+	}
+	return int((int64(documents) + intervals - 1) / intervals)
+}
+
+func (r *refresher) setStatus(update func(status *RefreshStatus)) {
+	r.statusMx.Lock()
+	defer r.statusMx.Unlock()
+
+	update(&r.status)
+}
+
+func (r *refresher) currentStatus() *RefreshStatus {
+	r.statusMx.RLock()
+	defer r.statusMx.RUnlock()
+
+	status := r.status
+	return &status
+}
+
+// saveState writes the state file if configured, errors are logged since the state is only an optimization.
+func (r *refresher) saveState() {
+	if r.opts.StateFile == "" {
+		return
+	}
+	if err := r.ctrl.freshness.save(r.opts.StateFile); err != nil {
+		log.
+			WithField("component", "refresh").
+			WithField("file", r.opts.StateFile).
+			WithError(err).
+			Warn("Saving refresh state failed")
+	}
+}
@@ -0,0 +1,215 @@
+package grazer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func TestRefresher_check(t *testing.T) {
+	h := NewHandler(HandlerOpts{
+		Revalidator:    NewRevalidator(RevalidatorOpts{URL: "http://localhost", DryRun: true}),
+		DocumentSource: staticDocuments{{RoutePath: "/a"}, {RoutePath: "/b"}, {RoutePath: "/c"}, {RoutePath: "/d"}, {RoutePath: "/e"}, {RoutePath: "/f"}},
+	})
+	defer h.ShutdownAndWait()
+	h.ctrl.pause()
+
+	// Checks are triggered by the test instead of the run loop
+	h.ctrl.freshness = newFreshness()
+	r := newRefresher(h.ctrl, RefreshOpts{
+		MaxAge:   3 * time.Hour,
+		Interval: time.Hour,
+	})
+	h.refresher = r
+
+	now := time.Now()
+	h.ctrl.freshness.revalidated("/a", now.Add(-4*time.Hour))
+	h.ctrl.freshness.revalidated("/b", now.Add(-2*time.Hour))
+	h.ctrl.freshness.revalidated("/c", now.Add(-10*time.Minute))
+	h.ctrl.freshness.revalidated("/d", now.Add(-5*time.Hour))
+	// Not listed anymore
+	h.ctrl.freshness.revalidated("/removed", now)
+
+	require.NoError(t, r.check(context.Background(), now))
+
+	// 6 documents within 3 intervals are 2 route paths per interval, never revalidated route paths first
+	assert.ElementsMatch(t, []string{"/e", "/f"}, entriesRoutePaths(r.pending))
+	assert.Empty(t, h.ctrl.queue.snapshot(10))
+
+	// Pending route paths are enqueued at low priority by the run loop
+	assert.Equal(t, 1, r.enqueueNext(1))
+	assert.Equal(t, 1, r.enqueueNext(2))
+	assert.Equal(t, 0, r.enqueueNext(1))
+	assert.ElementsMatch(t, []string{"/e", "/f"}, entriesRoutePaths(h.ctrl.queue.snapshot(10)))
+	for _, entry := range h.ctrl.queue.snapshot(10) {
+		assert.Equal(t, string(PriorityLow), tierName(entry))
+	}
+
+	status := h.Status(context.Background()).Refresh
+	require.NotNil(t, status)
+	assert.Equal(t, 6, status.Documents)
+	assert.Equal(t, 4, status.Stale)
+	assert.Equal(t, 2, status.Enqueued)
+	assert.Nil(t, status.Oldest)
+
+	// The oldest revalidated route paths follow, /c was revalidated recently and is skipped
+	h.ctrl.queue.drop(false)
+	h.ctrl.freshness.revalidated("/e", now)
+	h.ctrl.freshness.revalidated("/f", now)
+	require.NoError(t, r.check(context.Background(), now))
+	assert.ElementsMatch(t, []string{"/a", "/d"}, entriesRoutePaths(r.pending))
+
+	status = h.Status(context.Background()).Refresh
+	require.NotNil(t, status.Oldest)
+	assert.True(t, status.Oldest.Equal(now.Add(-5*time.Hour)))
+
+	_, listed := h.ctrl.freshness.revalidatedAt["/removed"]
+	assert.False(t, listed)
+}
+
+func TestRefresher_runWithSweepPacing(t *testing.T) {
+	var (
+		mx       sync.Mutex
+		received = make(map[string]int)
+	)
+	next := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body revalidateRequestBody
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mx.Lock()
+		defer mx.Unlock()
+		for _, document := range body.Documents {
+			received[document.RoutePath]++
+		}
+	}))
+	defer next.Close()
+
+	h := NewHandler(HandlerOpts{
+		Revalidator:    NewRevalidator(RevalidatorOpts{URL: next.URL}),
+		DocumentSource: staticDocuments{{RoutePath: "/a"}, {RoutePath: "/b"}, {RoutePath: "/c"}, {RoutePath: "/d"}},
+		SweepPacing:    SweepPacing{Duration: time.Hour},
+	})
+	defer h.ShutdownAndWait()
+	h.ctrl.freshness = newFreshness()
+
+	events, unsubscribe := h.ctrl.events.subscribe(100)
+	defer unsubscribe()
+
+	receivedPaths := func() int {
+		mx.Lock()
+		defer mx.Unlock()
+		return len(received)
+	}
+
+	// The paced sweep dispatches one route path and waits for a quarter of an hour
+	require.NoError(t, h.FullRevalidate(context.Background()))
+	require.Eventually(t, func() bool {
+		return receivedPaths() == 1
+	}, time.Second, 5*time.Millisecond)
+	h.ctrl.sweepMx.Lock()
+	sweepTotal := h.ctrl.sweepTotal
+	h.ctrl.sweepMx.Unlock()
+
+	r := newRefresher(h.ctrl, RefreshOpts{
+		MaxAge:   400 * time.Millisecond,
+		Interval: 40 * time.Millisecond,
+	})
+	done := make(chan struct{})
+	defer close(done)
+	start := time.Now()
+	go r.run(done)
+
+	// All route paths are revalidated within the maximum age although the sweep is paced
+	require.Eventually(t, func() bool {
+		return receivedPaths() == 4
+	}, 2*time.Second, 5*time.Millisecond)
+	assert.Less(t, time.Since(start), 400*time.Millisecond)
+
+	// Refreshed route paths are neither counted in the sweep nor reported as sweep progress
+	h.ctrl.sweepMx.Lock()
+	assert.Equal(t, sweepTotal, h.ctrl.sweepTotal)
+	h.ctrl.sweepMx.Unlock()
+	sweepEvents := 0
+	for len(events) > 0 {
+		event := <-events
+		if event.Type == EventSweepProgress || event.Type == EventSweepFinished {
+			sweepEvents++
+		}
+		if event.Type == EventEnqueued && event.Schedule == refreshScheduleName {
+			assert.Zero(t, event.All)
+		}
+	}
+	assert.Equal(t, 1, sweepEvents)
+}
+
+func TestFreshness_saveAndLoad(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "refresh.json")
+	revalidatedAt := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	f := newFreshness()
+	f.revalidated("/a", revalidatedAt)
+	require.NoError(t, f.save(filename))
+
+	loaded := newFreshness()
+	// Revalidations since the start are kept
+	loaded.revalidated("/b", revalidatedAt)
+	require.NoError(t, loaded.load(filename))
+	assert.True(t, loaded.revalidatedAt["/a"].Equal(revalidatedAt))
+	assert.True(t, loaded.revalidatedAt["/b"].Equal(revalidatedAt))
+
+	// A missing file is not an error
+	require.NoError(t, newFreshness().load(filepath.Join(t.TempDir(), "missing.json")))
+}
+
+func TestHandler_rollingRefresh(t *testing.T) {
+	var (
+		mx       sync.Mutex
+		received = make(map[string]int)
+	)
+	next := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body revalidateRequestBody
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mx.Lock()
+		defer mx.Unlock()
+		for _, document := range body.Documents {
+			received[document.RoutePath]++
+		}
+	}))
+	defer next.Close()
+
+	stateFile := filepath.Join(t.TempDir(), "refresh.json")
+	h := NewHandler(HandlerOpts{
+		Revalidator:    NewRevalidator(RevalidatorOpts{URL: next.URL}),
+		DocumentSource: staticDocuments{{RoutePath: "/a"}, {RoutePath: "/b"}, {RoutePath: "/c"}},
+		Refresh: RefreshOpts{
+			MaxAge:    time.Minute,
+			Interval:  10 * time.Millisecond,
+			StateFile: stateFile,
+		},
+	})
+
+	// All route paths are revalidated once, they are not stale again within the maximum age
+	require.Eventually(t, func() bool {
+		mx.Lock()
+		defer mx.Unlock()
+		return len(received) == 3
+	}, time.Second, 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	h.ShutdownAndWait()
+
+	mx.Lock()
+	assert.Equal(t, map[string]int{"/a": 1, "/b": 1, "/c": 1}, received)
+	mx.Unlock()
+
+	// The state is kept for the next start
+	f := newFreshness()
+	require.NoError(t, f.load(stateFile))
+	assert.Len(t, f.revalidatedAt, 3)
+}
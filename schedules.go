@@ -0,0 +1,187 @@
+package grazer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Schedule is a named revalidation schedule, it revalidates all documents or only the documents matching its paths.
+// Scheduled revalidations share the queue with invalidations, so route paths enqueued by multiple schedules are only revalidated once.
+type Schedule struct {
+	// Name of the schedule for scoping path rules (the spec if empty)
+	Name string `yaml:"name"`
+	// Spec is a cron spec (e.g. "*/5 * * * *" or "@hourly")
+	Spec string `yaml:"spec"`
+	// Paths restricts the revalidation to route paths matching any of the glob or "re:" patterns (all documents if empty)
+	Paths []string `yaml:"paths"`
+	// Targets restricts the revalidation to the named revalidation targets (all targets if empty)
+	Targets []string `yaml:"targets"`
+	// Priority enqueues the route paths like an invalidation with the priority instead of sweeping them with a full revalidation
+	Priority Priority `yaml:"priority"`
+}
+
+// ParseSchedule parses a schedule in the format "<spec>[;name=<name>][;path=<pattern>][;target=<name>][;priority=<priority>]".
+// Paths and targets can be repeated. A plain spec revalidates all documents and uses the spec as name.
+func ParseSchedule(s string) (Schedule, error) {
+	parts := strings.Split(s, ";")
+
+	schedule := Schedule{
+		Spec: strings.TrimSpace(parts[0]),
+	}
+	if schedule.Spec == "" {
+		return Schedule{}, fmt.Errorf("empty spec")
+	}
+
+	if err := parseScheduleOptions(&schedule, parts[1:]); err != nil {
+		return Schedule{}, err
+	}
+	return schedule, nil
+}
+
+// ParseScheduleOptions parses schedule options in the format "[name=<name>][;path=<pattern>][;target=<name>][;priority=<priority>]"
+// for a revalidation that is not triggered by a cron spec.
+func ParseScheduleOptions(s string) (Schedule, error) {
+	var schedule Schedule
+	if strings.TrimSpace(s) == "" {
+		return schedule, nil
+	}
+	if err := parseScheduleOptions(&schedule, strings.Split(s, ";")); err != nil {
+		return Schedule{}, err
+	}
+	return schedule, nil
+}
+
+func parseScheduleOptions(schedule *Schedule, parts []string) error {
+	for _, part := range parts {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return fmt.Errorf("invalid option %q, expected key=value", part)
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "name":
+			schedule.Name = value
+		case "path":
+			schedule.Paths = append(schedule.Paths, value)
+		case "target":
+			schedule.Targets = append(schedule.Targets, value)
+		case "priority":
+			schedule.Priority = Priority(value)
+		default:
+			return fmt.Errorf("unknown option %q", key)
+		}
+	}
+
+	_, err := schedule.scope()
+	return err
+}
+
+// DisplayName returns the name of the schedule or the spec if the schedule has no name.
+func (s Schedule) DisplayName() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Spec
+}
+
+// scheduleScope restricts a scheduled revalidation to matching route paths and targets.
+type scheduleScope struct {
+	paths   PathRules
+	targets []string
+	// prioritized enqueues the matching route paths with the tier instead of the sweep tier
+	prioritized bool
+	tier        queueTier
+}
+
+func (s Schedule) scope() (*scheduleScope, error) {
+	scope := &scheduleScope{
+		targets: s.Targets,
+	}
+	for _, pattern := range s.Paths {
+		rule, err := NewPathRule(pattern, false)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path: %w", err)
+		}
+		scope.paths = append(scope.paths, rule)
+	}
+	if s.Priority != "" {
+		tier, err := s.Priority.tier()
+		if err != nil {
+			return nil, err
+		}
+		scope.prioritized = true
+		scope.tier = tier
+	}
+	return scope, nil
+}
+
+// matches returns true if the route path matches any path of the scope, a nil scope or a scope without paths matches all route paths.
+func (s *scheduleScope) matches(routePath string) bool {
+	if s == nil || len(s.paths) == 0 {
+		return true
+	}
+	for _, rule := range s.paths {
+		if rule.Match(routePath) {
+			return true
+		}
+	}
+	return false
+}
+
+// restrictTargets restricts the targets of an entry to the targets of the scope.
+// It returns false if none of the targets of the entry is in the scope.
+func (s *scheduleScope) restrictTargets(entry queueEntry, allTargets []string) (queueEntry, bool) {
+	if s == nil || len(s.targets) == 0 {
+		return entry, true
+	}
+
+	targets := entry.targets
+	if targets == nil {
+		targets = allTargets
+	}
+	var restricted []string
+	for _, target := range targets {
+		if containsString(s.targets, target) {
+			restricted = append(restricted, target)
+		}
+	}
+	if len(restricted) == 0 {
+		return entry, false
+	}
+	entry.targets = restricted
+	return entry, true
+}
+
+// ValidateSchedule checks the paths, priority and targets of a schedule.
+func (h *Handler) ValidateSchedule(schedule Schedule) error {
+	if _, err := schedule.scope(); err != nil {
+		return err
+	}
+	for _, target := range schedule.Targets {
+		if !containsString(h.ctrl.targetNames(), target) {
+			return fmt.Errorf("unknown target %q", target)
+		}
+	}
+	return nil
+}
+
+// RevalidateSchedule performs a revalidation triggered by the schedule.
+// Route paths outside the paths and targets of the schedule are skipped, path rules scoped to the schedule are applied.
+func (h *Handler) RevalidateSchedule(ctx context.Context, schedule Schedule) error {
+	if err := h.ValidateSchedule(schedule); err != nil {
+		return fmt.Errorf("invalid schedule: %w", err)
+	}
+	return h.revalidateSchedule(ctx, schedule, SourceSchedule)
+}
+
+func (h *Handler) revalidateSchedule(ctx context.Context, schedule Schedule, source string) error {
+	scope, _ := schedule.scope()
+
+	_, err := h.ctrl.revalidate(ctx, revalidateRequest{
+		schedule: schedule.DisplayName(),
+		scope:    scope,
+		source:   source,
+	})
+	return err
+}
@@ -0,0 +1,72 @@
+package grazer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func TestParseSchedule(t *testing.T) {
+	schedule, err := ParseSchedule("*/5 * * * *;name=news;path=/news/*;path=/;target=next;priority=urgent")
+	require.NoError(t, err)
+	assert.Equal(t, Schedule{
+		Name:     "news",
+		Spec:     "*/5 * * * *",
+		Paths:    []string{"/news/*", "/"},
+		Targets:  []string{"next"},
+		Priority: PriorityUrgent,
+	}, schedule)
+
+	schedule, err = ParseSchedule("@hourly")
+	require.NoError(t, err)
+	assert.Equal(t, "@hourly", schedule.DisplayName())
+
+	_, err = ParseSchedule("@hourly;priority=high")
+	assert.Error(t, err)
+	_, err = ParseSchedule("@hourly;path=re:[")
+	assert.Error(t, err)
+	_, err = ParseSchedule("@hourly;unknown=x")
+	assert.Error(t, err)
+
+	schedule, err = ParseScheduleOptions("path=/news/**;priority=low")
+	require.NoError(t, err)
+	assert.Equal(t, Schedule{Paths: []string{"/news/**"}, Priority: PriorityLow}, schedule)
+
+	schedule, err = ParseScheduleOptions("")
+	require.NoError(t, err)
+	assert.Equal(t, Schedule{}, schedule)
+}
+
+func TestHandler_RevalidateSchedule(t *testing.T) {
+	h := NewHandler(HandlerOpts{
+		Revalidators: []*Revalidator{
+			NewRevalidator(RevalidatorOpts{Name: "next", URL: "http://localhost", DryRun: true}),
+			NewRevalidator(RevalidatorOpts{Name: "preview", URL: "http://localhost", DryRun: true}),
+		},
+		DocumentSource: staticDocuments{{RoutePath: "/"}, {RoutePath: "/news/a"}, {RoutePath: "/news/b"}, {RoutePath: "/events/c"}},
+	})
+	defer h.ShutdownAndWait()
+	h.PauseQueue()
+
+	ctx := context.Background()
+	require.NoError(t, h.RevalidateSchedule(ctx, Schedule{Name: "events", Paths: []string{"/events/**"}}))
+	require.NoError(t, h.RevalidateSchedule(ctx, Schedule{Name: "news", Paths: []string{"/news/*"}, Targets: []string{"preview"}, Priority: PriorityUrgent}))
+	// Overlapping schedules share the queue
+	require.NoError(t, h.RevalidateSchedule(ctx, Schedule{Name: "events", Paths: []string{"/events/**"}}))
+
+	status := h.Status(ctx)
+	assert.Equal(t, 3, status.Queue.Length)
+	assert.Equal(t, 2, status.Queue.Invalidated)
+	require.Len(t, status.Queue.Items, 3)
+	assert.Equal(t, "/news/a", status.Queue.Items[0].RoutePath)
+	assert.Equal(t, "urgent", status.Queue.Items[0].Tier)
+	assert.Equal(t, []string{"preview"}, status.Queue.Items[0].Targets)
+	assert.Equal(t, "/events/c", status.Queue.Items[2].RoutePath)
+	assert.Equal(t, queueTierSweep, status.Queue.Items[2].Tier)
+	assert.Nil(t, status.Queue.Items[2].Targets)
+
+	err := h.RevalidateSchedule(ctx, Schedule{Name: "unknown", Targets: []string{"unknown"}})
+	assert.EqualError(t, err, `invalid schedule: unknown target "unknown"`)
+}
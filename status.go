@@ -0,0 +1,215 @@
+package grazer
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	statusQueueItemsLimit = 200
+	recentAttemptsLimit   = 100
+)
+
+// Status is a snapshot of the queue, revalidations and upstreams.
+type Status struct {
+	// DryRun is true if revalidate requests to any target are only logged
+	DryRun    bool                  `json:"dryRun"`
+	Queue     QueueStatus           `json:"queue"`
+	InFlight  []InFlightBatch       `json:"inFlight"`
+	Recent    []RevalidationAttempt `json:"recent"`
+	Schedules []ScheduleStatus      `json:"schedules"`
+	// Refresh is the state of the rolling refresh (empty if disabled)
+	Refresh *RefreshStatus `json:"refresh,omitempty"`
+	// Upstreams are the results of the last check of the upstream monitor (empty if upstreams are not monitored)
+	Upstreams []UpstreamStatus `json:"upstreams"`
+}
+
+// QueueStatus describes the queue contents.
+type QueueStatus struct {
+	// Paused is true if dispatching of the queue is paused
+	Paused      bool `json:"paused"`
+	Length      int  `json:"length"`
+	Invalidated int  `json:"invalidated"`
+	Sweep       int  `json:"sweep"`
+	// Items are the first queue items in the order they will be revalidated
+	Items []QueueItemStatus `json:"items"`
+	// NextSweepAt is the earliest time for the next batch of a paced full revalidation
+	NextSweepAt *time.Time `json:"nextSweepAt,omitempty"`
+}
+
+// QueueItemStatus is a route path in the queue.
+type QueueItemStatus struct {
+	RoutePath string `json:"routePath"`
+	// Tier is the priority of the invalidation ("urgent", "normal" or "low") or "sweep" for route paths of a full revalidation
+	Tier     string   `json:"tier"`
+	Priority uint64   `json:"priority"`
+	Targets  []string `json:"targets,omitempty"`
+}
+
+const queueTierSweep = "sweep"
+
+// tierName returns the name of the tier of a queue entry for the status.
+func tierName(entry queueEntry) string {
+	if entry.priority == 0 {
+		return queueTierSweep
+	}
+	switch entry.tier {
+	case tierUrgent:
+		return string(PriorityUrgent)
+	case tierLow:
+		return string(PriorityLow)
+	default:
+		return string(PriorityNormal)
+	}
+}
+
+// InFlightBatch is a batch that is currently sent to a target.
+type InFlightBatch struct {
+	Target     string    `json:"target"`
+	RoutePaths []string  `json:"routePaths"`
+	StartedAt  time.Time `json:"startedAt"`
+}
+
+// RevalidationAttempt is a finished revalidate request to a target (including retries).
+type RevalidationAttempt struct {
+	Time       time.Time `json:"time"`
+	Target     string    `json:"target"`
+	RoutePaths []string  `json:"routePaths"`
+	DurationMs int64     `json:"durationMs"`
+	Attempts   int       `json:"attempts"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// ScheduleStatus describes a schedule for revalidations.
+type ScheduleStatus struct {
+	Name     string    `json:"name"`
+	Spec     string    `json:"spec"`
+	Paths    []string  `json:"paths,omitempty"`
+	Targets  []string  `json:"targets,omitempty"`
+	Priority Priority  `json:"priority,omitempty"`
+	Next     time.Time `json:"next"`
+	Prev     time.Time `json:"prev"`
+}
+
+// revalidationTracker keeps track of in-flight batches and recent attempts for the status.
+type revalidationTracker struct {
+	mx       sync.Mutex
+	inFlight map[string]InFlightBatch
+	recent   []RevalidationAttempt
+}
+
+func newRevalidationTracker() *revalidationTracker {
+	return &revalidationTracker{
+		inFlight: make(map[string]InFlightBatch),
+	}
+}
+
+func (t *revalidationTracker) started(target string, routePaths []string, startedAt time.Time) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	t.inFlight[target] = InFlightBatch{
+		Target:     target,
+		RoutePaths: routePaths,
+		StartedAt:  startedAt,
+	}
+}
+
+func (t *revalidationTracker) finished(attempt RevalidationAttempt) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	delete(t.inFlight, attempt.Target)
+
+	t.recent = append(t.recent, attempt)
+	if len(t.recent) > recentAttemptsLimit {
+		t.recent = t.recent[len(t.recent)-recentAttemptsLimit:]
+	}
+}
+
+// snapshot returns the in-flight batches and recent attempts (newest first).
+func (t *revalidationTracker) snapshot() ([]InFlightBatch, []RevalidationAttempt) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	inFlight := make([]InFlightBatch, 0, len(t.inFlight))
+	for _, batch := range t.inFlight {
+		inFlight = append(inFlight, batch)
+	}
+	sort.Slice(inFlight, func(i, j int) bool {
+		return inFlight[i].Target < inFlight[j].Target
+	})
+
+	recent := make([]RevalidationAttempt, len(t.recent))
+	for i, attempt := range t.recent {
+		recent[len(t.recent)-1-i] = attempt
+	}
+
+	return inFlight, recent
+}
+
+// Status returns a snapshot of the queue, in-flight and recent revalidations, schedules and the reachability of upstreams.
+// Upstreams are not probed, the status reports the last check of MonitorUpstreams.
+func (h *Handler) Status(ctx context.Context) Status {
+	length, sweep := h.ctrl.queue.len()
+	entries := h.ctrl.queue.snapshot(statusQueueItemsLimit)
+
+	items := make([]QueueItemStatus, len(entries))
+	for i, entry := range entries {
+		items[i] = QueueItemStatus{
+			RoutePath: entry.document.RoutePath,
+			Tier:      tierName(entry),
+			Priority:  entry.priority,
+			Targets:   entry.targets,
+		}
+	}
+
+	inFlight, recent := h.ctrl.tracker.snapshot()
+
+	var schedules []ScheduleStatus
+	h.schedulesMx.RLock()
+	if h.schedules != nil {
+		schedules = h.schedules()
+	}
+	h.schedulesMx.RUnlock()
+
+	dryRun := false
+	targets, _ := h.ctrl.config()
+	for _, target := range targets {
+		if target.DryRun() {
+			dryRun = true
+		}
+	}
+
+	var refresh *RefreshStatus
+	if h.refresher != nil {
+		refresh = h.refresher.currentStatus()
+	}
+
+	return Status{
+		DryRun: dryRun,
+		Queue: QueueStatus{
+			Paused:      h.ctrl.isPaused(),
+			Length:      length,
+			Invalidated: length - sweep,
+			Sweep:       sweep,
+			Items:       items,
+			NextSweepAt: h.ctrl.nextSweepDispatch(),
+		},
+		InFlight:  inFlight,
+		Recent:    recent,
+		Schedules: schedules,
+		Refresh:   refresh,
+		Upstreams: h.currentUpstreams(),
+	}
+}
+
+// SetSchedules sets a function that returns the status of schedules (e.g. of a cron) for the status.
+func (h *Handler) SetSchedules(schedules func() []ScheduleStatus) {
+	h.schedulesMx.Lock()
+	defer h.schedulesMx.Unlock()
+
+	h.schedules = schedules
+}
@@ -0,0 +1,145 @@
+package grazer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Keys for ordering documents within the zero-priority tier of a full revalidation.
+const (
+	// SweepOrderPriority orders by the priority weight of a document, higher first.
+	SweepOrderPriority = "priority"
+	// SweepOrderDepth orders by the depth of a document, lower first.
+	SweepOrderDepth = "depth"
+	// SweepOrderLastModified orders by the last modification of a document, newer first.
+	SweepOrderLastModified = "lastModified"
+	// SweepOrderRoutePath orders alphabetically by route path.
+	SweepOrderRoutePath = "routePath"
+)
+
+// SweepOrderRule is a single key for ordering documents.
+type SweepOrderRule struct {
+	Key string
+	// Reverse inverts the default direction of the key.
+	Reverse bool
+}
+
+// SweepOrder defines the order of documents within a full revalidation.
+// Rules are applied in order until two documents differ, documents without a value for a key are ordered last.
+// Remaining ties are ordered by route path.
+type SweepOrder struct {
+	Rules []SweepOrderRule
+	// NodeTypePriorities are used as priority weight for documents of a node type without an explicit priority.
+	NodeTypePriorities map[string]float64
+}
+
+// ParseSweepOrder parses a comma separated list of sweep order keys.
+// A key can be suffixed with ":asc" or ":desc" to set the direction explicitly (e.g. "priority,depth,lastModified:asc").
+func ParseSweepOrder(s string) ([]SweepOrderRule, error) {
+	var rules []SweepOrderRule
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		key, direction, _ := strings.Cut(part, ":")
+		var defaultDescending bool
+		switch key {
+		case SweepOrderPriority, SweepOrderLastModified:
+			defaultDescending = true
+		case SweepOrderDepth, SweepOrderRoutePath:
+			defaultDescending = false
+		default:
+			return nil, fmt.Errorf("unknown sweep order key %q", key)
+		}
+
+		rule := SweepOrderRule{Key: key}
+		switch direction {
+		case "":
+		case "asc":
+			rule.Reverse = defaultDescending
+		case "desc":
+			rule.Reverse = !defaultDescending
+		default:
+			return nil, fmt.Errorf("invalid direction %q for sweep order key %q", direction, key)
+		}
+
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// compare returns a negative number if a should be revalidated before b, a positive number if b should be first and 0 if both are equal.
+func (o SweepOrder) compare(a, b DocumentsItem) int {
+	for _, rule := range o.Rules {
+		var (
+			result       int
+			aMiss, bMiss bool
+		)
+		switch rule.Key {
+		case SweepOrderPriority:
+			ap, aOk := o.priority(a)
+			bp, bOk := o.priority(b)
+			aMiss, bMiss = !aOk, !bOk
+			result = compareFloat(bp, ap)
+		case SweepOrderDepth:
+			aMiss, bMiss = a.Depth == nil, b.Depth == nil
+			if !aMiss && !bMiss {
+				result = *a.Depth - *b.Depth
+			}
+		case SweepOrderLastModified:
+			aMiss, bMiss = a.LastModified == nil, b.LastModified == nil
+			if !aMiss && !bMiss {
+				switch {
+				case a.LastModified.After(*b.LastModified):
+					result = -1
+				case a.LastModified.Before(*b.LastModified):
+					result = 1
+				}
+			}
+		case SweepOrderRoutePath:
+			result = strings.Compare(a.RoutePath, b.RoutePath)
+		}
+
+		// Missing values are always ordered last, regardless of the direction
+		if aMiss != bMiss {
+			if aMiss {
+				return 1
+			}
+			return -1
+		}
+		if aMiss {
+			continue
+		}
+
+		if rule.Reverse {
+			result = -result
+		}
+		if result != 0 {
+			return result
+		}
+	}
+
+	return strings.Compare(a.RoutePath, b.RoutePath)
+}
+
+func (o SweepOrder) priority(document DocumentsItem) (float64, bool) {
+	if document.Priority != nil {
+		return *document.Priority, true
+	}
+	if p, ok := o.NodeTypePriorities[document.NodeType]; ok && document.NodeType != "" {
+		return p, true
+	}
+	return 0, false
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
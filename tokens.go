@@ -0,0 +1,280 @@
+package grazer
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// Scope grants access to a group of API endpoints.
+type Scope string
+
+const (
+	// ScopeInvalidate allows invalidating documents and reading jobs, events, the documents diff and metrics.
+	ScopeInvalidate Scope = "invalidate"
+	// ScopeFullRevalidate allows triggering a full revalidation.
+	ScopeFullRevalidate Scope = "full-revalidate"
+	// ScopeAdminRead allows reading the dashboard, status, history, jobs, events, the documents diff and metrics.
+	ScopeAdminRead Scope = "admin:read"
+	// ScopeAdminWrite allows all admin operations, it implies ScopeAdminRead.
+	ScopeAdminWrite Scope = "admin:write"
+)
+
+func parseScope(s string) (Scope, error) {
+	switch scope := Scope(s); scope {
+	case ScopeInvalidate, ScopeFullRevalidate, ScopeAdminRead, ScopeAdminWrite:
+		return scope, nil
+	default:
+		return "", fmt.Errorf("unknown scope %q", s)
+	}
+}
+
+// Token is an inbound API token.
+type Token struct {
+	// Name identifies the token in logs and the history
+	Name   string
+	Secret string
+	Scopes []Scope
+}
+
+func (t Token) hasScope(scope Scope) bool {
+	for _, s := range t.Scopes {
+		if s == scope || (s == ScopeAdminWrite && scope == ScopeAdminRead) {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenSet holds inbound API tokens, the tokens can be replaced at runtime to rotate them without a restart.
+type TokenSet struct {
+	mx     sync.RWMutex
+	tokens []Token
+}
+
+func NewTokenSet(tokens []Token) *TokenSet {
+	return &TokenSet{
+		tokens: tokens,
+	}
+}
+
+// Replace replaces all tokens.
+func (s *TokenSet) Replace(tokens []Token) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.tokens = tokens
+}
+
+// Tokens returns the current tokens.
+func (s *TokenSet) Tokens() []Token {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	return s.tokens
+}
+
+// LoadFile replaces the tokens with the tokens of a tokens file.
+func (s *TokenSet) LoadFile(filename string) error {
+	tokens, err := LoadTokensFile(filename)
+	if err != nil {
+		return err
+	}
+	s.Replace(tokens)
+	return nil
+}
+
+// WatchFile reloads the tokens if the modification time of the file changes until the context is done.
+// The current tokens are kept if the file cannot be loaded.
+func (s *TokenSet) WatchFile(ctx context.Context, filename string, interval time.Duration) {
+	var lastModTime time.Time
+	if fi, err := os.Stat(filename); err == nil {
+		lastModTime = fi.ModTime()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		fi, err := os.Stat(filename)
+		if err != nil {
+			log.
+				WithField("component", "tokens").
+				WithField("filename", filename).
+				WithError(err).
+				Warn("Checking tokens file failed")
+			continue
+		}
+		if fi.ModTime().Equal(lastModTime) {
+			continue
+		}
+
+		err = s.LoadFile(filename)
+		if err != nil {
+			log.
+				WithField("component", "tokens").
+				WithField("filename", filename).
+				WithError(err).
+				Error("Reloading tokens failed, keeping current tokens")
+			continue
+		}
+		lastModTime = fi.ModTime()
+
+		log.
+			WithField("component", "tokens").
+			WithField("filename", filename).
+			WithField("count", len(s.Tokens())).
+			Info("Reloaded tokens")
+	}
+}
+
+// LoadTokensFile reads tokens from a file, see ParseTokens for the format.
+func LoadTokensFile(filename string) ([]Token, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("opening tokens file: %w", err)
+	}
+	defer f.Close()
+
+	tokens, err := ParseTokens(f)
+	if err != nil {
+		return nil, fmt.Errorf("parsing tokens file %s: %w", filename, err)
+	}
+	return tokens, nil
+}
+
+// ParseTokens parses one token per line as name, secret and comma separated scopes separated by whitespace
+// (e.g. "neos-live s3cr3t invalidate,full-revalidate").
+// Empty lines and lines starting with "#" are ignored.
+func ParseTokens(r io.Reader) ([]Token, error) {
+	var (
+		tokens  []Token
+		names   = make(map[string]struct{})
+		secrets = make(map[string]struct{})
+		lineNo  int
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: expected name, secret and scopes", lineNo)
+		}
+
+		token := Token{
+			Name:   fields[0],
+			Secret: fields[1],
+		}
+		if _, exists := names[token.Name]; exists {
+			return nil, fmt.Errorf("line %d: duplicate token name %q", lineNo, token.Name)
+		}
+		if _, exists := secrets[token.Secret]; exists {
+			return nil, fmt.Errorf("line %d: duplicate secret", lineNo)
+		}
+		names[token.Name] = struct{}{}
+		secrets[token.Secret] = struct{}{}
+
+		for _, s := range strings.Split(fields[2], ",") {
+			scope, err := parseScope(s)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			token.Scopes = append(token.Scopes, scope)
+		}
+
+		tokens = append(tokens, token)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// allTokens returns the static tokens of the handler options and the tokens of the token set.
+func (h *Handler) allTokens() []Token {
+	return append(h.staticTokens[:len(h.staticTokens):len(h.staticTokens)], h.tokens.Tokens()...)
+}
+
+// lookupToken returns the token with the secret given as bearer token or basic auth password (for browsers).
+func (h *Handler) lookupToken(r *http.Request) (Token, bool) {
+	secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if _, password, basicOK := r.BasicAuth(); basicOK {
+		secret, ok = password, true
+	}
+	if !ok {
+		return Token{}, false
+	}
+	return h.lookupSecret(secret)
+}
+
+// lookupSecret returns the token with the secret.
+func (h *Handler) lookupSecret(secret string) (Token, bool) {
+	if secret == "" {
+		return Token{}, false
+	}
+
+	var (
+		found Token
+		match bool
+	)
+	// Compare all tokens to not leak which token matched by timing
+	for _, token := range h.allTokens() {
+		if token.Secret == "" {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(token.Secret)) == 1 {
+			found = token
+			match = true
+		}
+	}
+	return found, match
+}
+
+func hasAnyScope(token Token, scopes []Scope) bool {
+	for _, scope := range scopes {
+		if token.hasScope(scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorize verifies a token with any of the scopes is given and writes a forbidden status otherwise.
+func (h *Handler) authorize(w http.ResponseWriter, r *http.Request, scopes ...Scope) (Token, bool) {
+	token, ok := h.lookupToken(r)
+	if !ok || !hasAnyScope(token, scopes) {
+		log.
+			WithField("component", "http").
+			WithField("path", r.URL.Path).
+			WithField("token", token.Name).
+			Warn("Invalid token or missing scope")
+		writeError(w, http.StatusForbidden, ErrorResponse{
+			Code:    ErrorCodeForbidden,
+			Message: "invalid token or missing scope",
+		})
+		return token, false
+	}
+	return token, true
+}
@@ -0,0 +1,77 @@
+package grazer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func TestParseTokens(t *testing.T) {
+	tokens, err := ParseTokens(strings.NewReader(`
+# Neos instances
+neos-live   s3cr3t-live   invalidate,full-revalidate
+ops         s3cr3t-ops    admin:write
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []Token{
+		{Name: "neos-live", Secret: "s3cr3t-live", Scopes: []Scope{ScopeInvalidate, ScopeFullRevalidate}},
+		{Name: "ops", Secret: "s3cr3t-ops", Scopes: []Scope{ScopeAdminWrite}},
+	}, tokens)
+
+	_, err = ParseTokens(strings.NewReader("neos s3cr3t invalidate,everything\n"))
+	assert.EqualError(t, err, `line 1: unknown scope "everything"`)
+
+	_, err = ParseTokens(strings.NewReader("neos s3cr3t\n"))
+	assert.EqualError(t, err, "line 1: expected name, secret and scopes")
+
+	_, err = ParseTokens(strings.NewReader("neos s3cr3t invalidate\nneos other invalidate\n"))
+	assert.EqualError(t, err, `line 2: duplicate token name "neos"`)
+}
+
+func TestHandler_tokens(t *testing.T) {
+	tokens := NewTokenSet([]Token{
+		{Name: "neos", Secret: "old-secret", Scopes: []Scope{ScopeInvalidate}},
+		{Name: "ops", Secret: "ops-secret", Scopes: []Scope{ScopeAdminWrite}},
+	})
+	h := NewHandler(HandlerOpts{
+		Revalidator:    NewRevalidator(RevalidatorOpts{URL: "http://localhost:0"}),
+		DocumentSource: staticDocuments{},
+		Tokens:         tokens,
+	})
+	defer h.ShutdownAndWait()
+
+	do := func(method, path, secret string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+secret)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusNoContent, do(http.MethodGet, "/api/documents/diff", "old-secret"))
+	// Missing full-revalidate scope
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/api/full-revalidate", "old-secret"))
+	// Missing admin scope
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/api/history", "old-secret"))
+	// admin:write implies admin:read
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/history", "ops-secret"))
+	assert.Equal(t, http.StatusAccepted, do(http.MethodPost, "/api/full-revalidate", "ops-secret"))
+
+	// Rotate the secret, both secrets are valid during the rotation
+	tokens.Replace([]Token{
+		{Name: "neos", Secret: "old-secret", Scopes: []Scope{ScopeInvalidate}},
+		{Name: "neos-rotated", Secret: "new-secret", Scopes: []Scope{ScopeInvalidate}},
+	})
+	assert.Equal(t, http.StatusNoContent, do(http.MethodGet, "/api/documents/diff", "new-secret"))
+
+	tokens.Replace([]Token{
+		{Name: "neos-rotated", Secret: "new-secret", Scopes: []Scope{ScopeInvalidate}},
+	})
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/api/documents/diff", "old-secret"))
+	// Admin endpoints are disabled without a token with an admin scope
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/history", "ops-secret"))
+}
@@ -0,0 +1,184 @@
+package grazer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/apex/log"
+)
+
+// DefaultMaxRequestBodyBytes is the default size limit of request bodies.
+const DefaultMaxRequestBodyBytes = 1 << 20
+
+// maxRoutePathLength is the maximum length of a route path in bytes.
+const maxRoutePathLength = 2048
+
+// Error codes of JSON error responses.
+const (
+	ErrorCodeMethodNotAllowed = "methodNotAllowed"
+	ErrorCodeBodyTooLarge     = "bodyTooLarge"
+	ErrorCodeInvalidBody      = "invalidBody"
+	ErrorCodeInvalidDocuments = "invalidDocuments"
+	ErrorCodeInvalidParameter = "invalidParameter"
+	ErrorCodeUnauthorized     = "unauthorized"
+	ErrorCodeForbidden        = "forbidden"
+	ErrorCodeNotFound         = "notFound"
+	ErrorCodeUpstreamFailed   = "upstreamFailed"
+	ErrorCodeInternal         = "internal"
+)
+
+// ErrorResponse is the body of an error response.
+type ErrorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Documents are the errors of invalid documents in a request
+	Documents []DocumentError `json:"documents,omitempty"`
+}
+
+// DocumentError describes an invalid document in a request.
+type DocumentError struct {
+	DocumentIndex int    `json:"documentIndex"`
+	Field         string `json:"field,omitempty"`
+	Message       string `json:"message"`
+}
+
+func writeError(w http.ResponseWriter, status int, resp ErrorResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// requireMethod writes a method not allowed error if the request does not use the method.
+func requireMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, ErrorResponse{
+		Code:    ErrorCodeMethodNotAllowed,
+		Message: fmt.Sprintf("method %s is not allowed, use %s", r.Method, method),
+	})
+	return false
+}
+
+// decodeJSONBody strictly decodes a JSON request body limited to maxBytes.
+// Unknown fields and data after the JSON value are rejected.
+// It writes an error response and returns false if the body is invalid.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, maxBytes int64, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBytes))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(v)
+	if err == nil && dec.Decode(&struct{}{}) != io.EOF {
+		err = errors.New("unexpected data after JSON value")
+	}
+	if err == nil {
+		return true
+	}
+
+	log.
+		WithField("component", "http").
+		WithField("path", r.URL.Path).
+		WithError(err).
+		Warn("Decoding request body")
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		writeError(w, http.StatusRequestEntityTooLarge, ErrorResponse{
+			Code:    ErrorCodeBodyTooLarge,
+			Message: fmt.Sprintf("request body must not be larger than %d bytes", maxBytesErr.Limit),
+		})
+		return false
+	}
+
+	writeError(w, http.StatusBadRequest, ErrorResponse{
+		Code:    ErrorCodeInvalidBody,
+		Message: fmt.Sprintf("invalid request body: %v", err),
+	})
+	return false
+}
+
+// validate checks the documents of a revalidate request.
+func (b revalidateRequestBody) validate() []DocumentError {
+	var errs []DocumentError
+	for i, document := range b.Documents {
+		switch {
+		case document.RoutePath == "" && document.RoutePathPrefix == "":
+			errs = append(errs, DocumentError{
+				DocumentIndex: i,
+				Message:       "either routePath or routePathPrefix is required",
+			})
+		case document.RoutePath != "" && document.RoutePathPrefix != "":
+			errs = append(errs, DocumentError{
+				DocumentIndex: i,
+				Message:       "only one of routePath or routePathPrefix is allowed",
+			})
+		case document.RoutePath != "":
+			if err := validateRoutePath(document.RoutePath); err != nil {
+				errs = append(errs, DocumentError{
+					DocumentIndex: i,
+					Field:         "routePath",
+					Message:       err.Error(),
+				})
+			}
+		default:
+			if err := validateRoutePath(document.RoutePathPrefix); err != nil {
+				errs = append(errs, DocumentError{
+					DocumentIndex: i,
+					Field:         "routePathPrefix",
+					Message:       err.Error(),
+				})
+			}
+		}
+	}
+	return errs
+}
+
+// validateRoutePath checks that a route path is an absolute path without scheme, host, fragment or control characters.
+// Dot segments and empty segments are rejected, since they would not match a normalized route path of a document.
+func validateRoutePath(routePath string) error {
+	if len(routePath) > maxRoutePathLength {
+		return fmt.Errorf("must not be longer than %d bytes", maxRoutePathLength)
+	}
+	if !utf8.ValidString(routePath) {
+		return errors.New("must be valid UTF-8")
+	}
+	for _, r := range routePath {
+		if unicode.IsControl(r) {
+			return errors.New("must not contain control characters")
+		}
+	}
+	u, err := url.Parse(routePath)
+	if err != nil {
+		return errors.New("must be a valid URL path")
+	}
+	if u.Scheme != "" {
+		return errors.New("must not contain a scheme or host")
+	}
+	if !strings.HasPrefix(routePath, "/") {
+		return errors.New("must be an absolute path starting with /")
+	}
+	if u.Host != "" {
+		return errors.New("must not contain a host")
+	}
+	if strings.Contains(routePath, "#") {
+		return errors.New("must not contain a fragment")
+	}
+	// Segments are checked without the query, which can contain any characters
+	for _, segment := range strings.Split(strings.TrimPrefix(u.Path, "/"), "/") {
+		if segment == "." || segment == ".." {
+			return errors.New("must not contain . or .. segments")
+		}
+	}
+	if strings.Contains(u.Path, "//") {
+		return errors.New("must not contain empty segments")
+	}
+	return nil
+}
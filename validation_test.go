@@ -0,0 +1,140 @@
+package grazer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func Test_validateRoutePath(t *testing.T) {
+	tests := []struct {
+		routePath string
+		expectErr string
+	}{
+		{routePath: "/"},
+		{routePath: "/blog/post-1"},
+		{routePath: "/blog/"},
+		{routePath: "/blog/**"},
+		{routePath: "/über-uns"},
+		{routePath: "/redirect?to=https://example.com/"},
+		{routePath: "blog/post-1", expectErr: "must be an absolute path starting with /"},
+		{routePath: "https://example.com/blog", expectErr: "must not contain a scheme or host"},
+		{routePath: "//example.com/blog", expectErr: "must not contain a host"},
+		{routePath: "mailto:info@example.com", expectErr: "must not contain a scheme or host"},
+		{routePath: "/blog/100%", expectErr: "must be a valid URL path"},
+		{routePath: "/blog\n/post-1", expectErr: "must not contain control characters"},
+		{routePath: "/blog\x00", expectErr: "must not contain control characters"},
+		{routePath: "/blog#comments", expectErr: "must not contain a fragment"},
+		{routePath: "/blog/../admin", expectErr: "must not contain . or .. segments"},
+		{routePath: "/blog/.", expectErr: "must not contain . or .. segments"},
+		{routePath: "/blog//post-1", expectErr: "must not contain empty segments"},
+		{routePath: "/" + strings.Repeat("a", maxRoutePathLength), expectErr: "must not be longer than 2048 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.routePath, func(t *testing.T) {
+			err := validateRoutePath(tt.routePath)
+			if tt.expectErr != "" {
+				assert.EqualError(t, err, tt.expectErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestHandler_revalidateValidation(t *testing.T) {
+	h := NewHandler(HandlerOpts{
+		RevalidateToken:     "a-token",
+		Revalidator:         NewRevalidator(RevalidatorOpts{URL: "http://localhost:0"}),
+		DocumentSource:      staticDocuments{},
+		MaxRequestBodyBytes: 256,
+	})
+	defer h.ShutdownAndWait()
+
+	do := func(method, body string) (int, ErrorResponse) {
+		req := httptest.NewRequest(method, "/api/revalidate", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer a-token")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		var resp ErrorResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		return rec.Code, resp
+	}
+
+	code, resp := do(http.MethodGet, "")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+	assert.Equal(t, ErrorCodeMethodNotAllowed, resp.Code)
+
+	code, resp = do(http.MethodPost, `{"documents":[{"routePath":"/`+strings.Repeat("a", 300)+`"}]}`)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+	assert.Equal(t, ErrorCodeBodyTooLarge, resp.Code)
+
+	code, resp = do(http.MethodPost, `{"documents":[{"routePath":"/","path":"/"}]}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, ErrorCodeInvalidBody, resp.Code)
+	assert.Contains(t, resp.Message, `unknown field "path"`)
+
+	// Without documents all documents are revalidated
+	code, _ = do(http.MethodPost, `{"documents":[]}`)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, resp = do(http.MethodPost, `{"documents":[],"skipFullRevalidate":true}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, ErrorCodeInvalidDocuments, resp.Code)
+
+	code, resp = do(http.MethodPost, `{"documents":[{"routePath":"/ok"},{"routePath":"https://example.com/"},{"routePath":"/a","routePathPrefix":"/b"}]}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, ErrorCodeInvalidDocuments, resp.Code)
+	assert.Equal(t, []DocumentError{
+		{DocumentIndex: 1, Field: "routePath", Message: "must not contain a scheme or host"},
+		{DocumentIndex: 2, Message: "only one of routePath or routePathPrefix is allowed"},
+	}, resp.Documents)
+}
+
+func TestHandler_errorResponses(t *testing.T) {
+	h := NewHandler(HandlerOpts{
+		Revalidator:    NewRevalidator(RevalidatorOpts{URL: "http://localhost:0"}),
+		DocumentSource: staticDocuments{},
+		Tokens: NewTokenSet([]Token{
+			{Name: "neos", Secret: "neos-secret", Scopes: []Scope{ScopeInvalidate}},
+			{Name: "ops", Secret: "ops-secret", Scopes: []Scope{ScopeAdminRead}},
+		}),
+	})
+	defer h.ShutdownAndWait()
+
+	tests := []struct {
+		method, path, secret string
+		status               int
+		code                 string
+	}{
+		{http.MethodPost, "/api/jobs/unknown", "neos-secret", http.StatusMethodNotAllowed, ErrorCodeMethodNotAllowed},
+		{http.MethodGet, "/api/jobs/unknown", "neos-secret", http.StatusNotFound, ErrorCodeNotFound},
+		{http.MethodPost, "/api/events", "neos-secret", http.StatusMethodNotAllowed, ErrorCodeMethodNotAllowed},
+		{http.MethodPost, "/api/documents/diff", "neos-secret", http.StatusMethodNotAllowed, ErrorCodeMethodNotAllowed},
+		{http.MethodPost, "/api/history", "ops-secret", http.StatusMethodNotAllowed, ErrorCodeMethodNotAllowed},
+		{http.MethodPost, "/api/history/last", "ops-secret", http.StatusMethodNotAllowed, ErrorCodeMethodNotAllowed},
+		{http.MethodPost, "/api/status", "ops-secret", http.StatusMethodNotAllowed, ErrorCodeMethodNotAllowed},
+		{http.MethodGet, "/api/status", "invalid", http.StatusUnauthorized, ErrorCodeUnauthorized},
+		{http.MethodGet, "/api/status", "neos-secret", http.StatusForbidden, ErrorCodeForbidden},
+		{http.MethodPost, "/api/queue/pause", "ops-secret", http.StatusForbidden, ErrorCodeForbidden},
+		{http.MethodGet, "/admin/unknown", "ops-secret", http.StatusNotFound, ErrorCodeNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("Authorization", "Bearer "+tt.secret)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, tt.status, rec.Code, "%s %s", tt.method, tt.path)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"), "%s %s", tt.method, tt.path)
+		var resp ErrorResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp), "%s %s", tt.method, tt.path)
+		assert.Equal(t, tt.code, resp.Code, "%s %s", tt.method, tt.path)
+	}
+}
@@ -0,0 +1,351 @@
+package grazer
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/cenkalti/backoff/v4"
+)
+
+// DefaultWebhookEvents are the event types sent to a webhook without configured events.
+var DefaultWebhookEvents = []EventType{
+	EventJobFinished,
+	EventPathFailed,
+	EventSweepFinished,
+	EventUpstreamUnreachable,
+	EventUpstreamRecovered,
+}
+
+const (
+	// WebhookSignatureHeader contains the hex encoded HMAC-SHA256 of "<timestamp>.<body>" with the secret of the webhook, prefixed with "sha256=".
+	WebhookSignatureHeader = "X-Grazer-Signature"
+	// WebhookTimestampHeader contains the Unix time of the delivery attempt, it should be checked to prevent replays.
+	WebhookTimestampHeader = "X-Grazer-Timestamp"
+)
+
+// webhookSubscriberBufferSize is larger than for event streams, since a webhook must not miss events during a busy sweep.
+const webhookSubscriberBufferSize = 1024
+
+type WebhookOpts struct {
+	// Name of the webhook for logs and the payload
+	Name string
+	URL  string
+	// Secret signs deliveries with HMAC-SHA256 (unsigned if empty)
+	Secret string
+	// Events are the event types sent to the webhook (DefaultWebhookEvents if empty)
+	Events []EventType
+	// BatchInterval is the maximum delay of an event until its batch is delivered (5 seconds if 0)
+	BatchInterval time.Duration
+	// BatchSize is the maximum number of events in a delivery (100 if 0)
+	BatchSize int
+	// Retries of a failed delivery with exponential backoff (no retries if 0)
+	Retries int
+	// QueueSize is the maximum number of batches waiting for delivery, new batches are dropped if the queue is full (100 if 0)
+	QueueSize int
+	// Timeout of a single delivery attempt (10 seconds if 0)
+	Timeout time.Duration
+
+	Transport http.RoundTripper
+}
+
+// Webhook delivers batches of events as signed JSON to a URL.
+// Batches are delivered one after another from a bounded queue, so a slow or failing receiver never blocks revalidations.
+type Webhook struct {
+	name          string
+	url           string
+	secret        []byte
+	events        map[EventType]bool
+	batchInterval time.Duration
+	batchSize     int
+	retries       int
+
+	client *http.Client
+
+	deliveries chan []Event
+	wg         sync.WaitGroup
+	// stopping is canceled after all events were collected, remaining batches are delivered without retries
+	stopping context.Context
+	stop     context.CancelFunc
+}
+
+// WebhookPayload is the JSON body of a webhook delivery.
+type WebhookPayload struct {
+	Webhook string `json:"webhook"`
+	// DeliveryID is the same for all attempts of a delivery
+	DeliveryID string  `json:"deliveryId"`
+	Events     []Event `json:"events"`
+}
+
+func NewWebhook(opts WebhookOpts) *Webhook {
+	if opts.BatchInterval == 0 {
+		opts.BatchInterval = 5 * time.Second
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = 100
+	}
+	if opts.QueueSize == 0 {
+		opts.QueueSize = 100
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if len(opts.Events) == 0 {
+		opts.Events = DefaultWebhookEvents
+	}
+
+	events := make(map[EventType]bool, len(opts.Events))
+	for _, eventType := range opts.Events {
+		events[eventType] = true
+	}
+
+	stopping, stop := context.WithCancel(context.Background())
+
+	return &Webhook{
+		name:          opts.Name,
+		url:           opts.URL,
+		secret:        []byte(opts.Secret),
+		events:        events,
+		batchInterval: opts.BatchInterval,
+		batchSize:     opts.BatchSize,
+		retries:       opts.Retries,
+		client: &http.Client{
+			Timeout:   opts.Timeout,
+			Transport: opts.Transport,
+		},
+		deliveries: make(chan []Event, opts.QueueSize),
+		stopping:   stopping,
+		stop:       stop,
+	}
+}
+
+// ParseWebhookEvents parses a comma separated list of event types.
+func ParseWebhookEvents(s string) ([]EventType, error) {
+	known := make(map[EventType]bool)
+	for _, eventType := range webhookEventTypes {
+		known[eventType] = true
+	}
+
+	var eventTypes []EventType
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		eventType := EventType(part)
+		if !known[eventType] {
+			return nil, fmt.Errorf("unknown event type %q", part)
+		}
+		eventTypes = append(eventTypes, eventType)
+	}
+	return eventTypes, nil
+}
+
+// webhookEventTypes are all event types that can be sent to a webhook.
+var webhookEventTypes = []EventType{
+	EventEnqueued,
+	EventBatchStarted,
+	EventPathRevalidated,
+	EventPathFailed,
+	EventRetried,
+	EventQueueDrained,
+	EventSweepProgress,
+	EventQueuePaused,
+	EventQueueResumed,
+	EventSweepDrained,
+	EventQueueCanceled,
+	EventJobFinished,
+	EventSweepFinished,
+	EventUpstreamUnreachable,
+	EventUpstreamRecovered,
+	EventDeploymentChanged,
+}
+
+// Name of the webhook.
+func (w *Webhook) Name() string {
+	return w.name
+}
+
+// start collects events into batches until the events channel is closed.
+func (w *Webhook) start(events <-chan Event) {
+	w.wg.Add(2)
+	go w.collect(events)
+	go w.deliver()
+}
+
+// wait waits until all batches were delivered after the events channel was closed.
+func (w *Webhook) wait() {
+	w.wg.Wait()
+}
+
+func (w *Webhook) collect(events <-chan Event) {
+	defer w.wg.Done()
+	defer close(w.deliveries)
+	// Remaining batches are delivered without retries after all events were collected
+	defer w.stop()
+
+	ticker := time.NewTicker(w.batchInterval)
+	defer ticker.Stop()
+
+	var batch []Event
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		select {
+		case w.deliveries <- batch:
+		default:
+			metrics.Add(metricWebhookDroppedBatches, 1)
+			log.
+				WithField("component", "webhook").
+				WithField("webhook", w.name).
+				WithField("events", len(batch)).
+				Warn("Delivery queue is full, dropping batch")
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				flush()
+				return
+			}
+			if !w.events[event.Type] {
+				continue
+			}
+			batch = append(batch, event)
+			if len(batch) >= w.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (w *Webhook) deliver() {
+	defer w.wg.Done()
+
+	for batch := range w.deliveries {
+		payload := WebhookPayload{
+			Webhook:    w.name,
+			DeliveryID: newJobID(),
+			Events:     batch,
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			log.
+				WithField("component", "webhook").
+				WithField("webhook", w.name).
+				WithError(err).
+				Error("Encoding webhook payload failed")
+			continue
+		}
+
+		var b backoff.BackOff = backoff.NewExponentialBackOff()
+		b = backoff.WithMaxRetries(b, uint64(w.retries))
+		b = backoff.WithContext(b, w.stopping)
+
+		start := time.Now()
+		err = backoff.RetryNotify(func() error {
+			return w.send(body)
+		}, b, func(err error, d time.Duration) {
+			log.
+				WithField("component", "webhook").
+				WithField("webhook", w.name).
+				WithField("deliveryId", payload.DeliveryID).
+				WithError(err).
+				Warnf("Webhook delivery failed, retrying in %s", d)
+		})
+		if err != nil {
+			metrics.Add(metricWebhookFailedDeliveries, 1)
+			log.
+				WithField("component", "webhook").
+				WithField("webhook", w.name).
+				WithField("deliveryId", payload.DeliveryID).
+				WithField("events", len(batch)).
+				WithError(err).
+				Error("Webhook delivery failed")
+			continue
+		}
+
+		metrics.Add(metricWebhookDeliveries, 1)
+		log.
+			WithField("component", "webhook").
+			WithField("webhook", w.name).
+			WithField("deliveryId", payload.DeliveryID).
+			WithField("events", len(batch)).
+			WithField("duration", time.Since(start).Milliseconds()).
+			Debug("Delivered webhook")
+	}
+}
+
+// send posts the body once, client errors are not retried.
+func (w *Webhook) send(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return backoff.Permanent(fmt.Errorf("building request: %w", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(w.secret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(WebhookTimestampHeader, timestamp)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+signWebhook(w.secret, timestamp, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		err := fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return backoff.Permanent(err)
+		}
+		return err
+	}
+	return nil
+}
+
+func signWebhook(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature verifies the signature header of a webhook delivery for receivers written in Go.
+// Deliveries with a timestamp older than maxAge are rejected.
+func VerifyWebhookSignature(secret string, header http.Header, body []byte, maxAge time.Duration) error {
+	timestamp := header.Get(WebhookTimestampHeader)
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp: %w", err)
+	}
+	if time.Since(time.Unix(unix, 0)) > maxAge {
+		return errors.New("timestamp too old")
+	}
+
+	expected := "sha256=" + signWebhook([]byte(secret), timestamp, body)
+	if !hmac.Equal([]byte(header.Get(WebhookSignatureHeader)), []byte(expected)) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package grazer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func TestWebhook(t *testing.T) {
+	var (
+		mx       sync.Mutex
+		attempts int
+		payloads []WebhookPayload
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := VerifyWebhookSignature("a-secret", r.Header, body, time.Minute); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		mx.Lock()
+		defer mx.Unlock()
+		attempts++
+		// The first delivery is retried
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var payload WebhookPayload
+		_ = json.Unmarshal(body, &payload)
+		payloads = append(payloads, payload)
+	}))
+	defer receiver.Close()
+
+	next := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body revalidateRequestBody
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if len(body.Documents) > 0 && body.Documents[0].RoutePath == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer next.Close()
+
+	h := NewHandler(HandlerOpts{
+		RevalidateToken: "a-token",
+		Revalidator:     NewRevalidator(RevalidatorOpts{URL: next.URL}),
+		DocumentSource:  staticDocuments{{RoutePath: "/"}, {RoutePath: "/broken"}},
+		Webhooks: []*Webhook{NewWebhook(WebhookOpts{
+			Name:          "chat",
+			URL:           receiver.URL,
+			Secret:        "a-secret",
+			Events:        []EventType{EventJobFinished, EventPathFailed},
+			BatchInterval: 10 * time.Millisecond,
+			Retries:       1,
+		})},
+	})
+	defer h.ShutdownAndWait()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/revalidate?wait=true", strings.NewReader(`{"documents":[{"routePath":"/"},{"routePath":"/broken"}],"skipFullRevalidate":true}`))
+	req.Header.Set("Authorization", "Bearer a-token")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var events []Event
+	require.Eventually(t, func() bool {
+		mx.Lock()
+		defer mx.Unlock()
+
+		events = nil
+		for _, payload := range payloads {
+			assert.Equal(t, "chat", payload.Webhook)
+			events = append(events, payload.Events...)
+		}
+		return len(events) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, EventPathFailed, events[0].Type)
+	assert.Equal(t, "/broken", events[0].RoutePath)
+	assert.Equal(t, EventJobFinished, events[1].Type)
+	require.NotNil(t, events[1].Job)
+	assert.Equal(t, JobStateFailed, events[1].Job.State)
+	assert.Len(t, events[1].Job.Paths, 2)
+}
+
+func TestWebhook_noRetries(t *testing.T) {
+	var (
+		mx       sync.Mutex
+		payloads []WebhookPayload
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WebhookPayload
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		mx.Lock()
+		payloads = append(payloads, payload)
+		mx.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	w := NewWebhook(WebhookOpts{
+		Name:          "chat",
+		URL:           receiver.URL,
+		Events:        []EventType{EventPathFailed},
+		BatchInterval: 10 * time.Millisecond,
+	})
+	events := make(chan Event)
+	w.start(events)
+	defer w.wait()
+	defer close(events)
+
+	received := func(n int) func() bool {
+		return func() bool {
+			mx.Lock()
+			defer mx.Unlock()
+			return len(payloads) == n
+		}
+	}
+
+	// The failed delivery of the first batch is not retried, so the next request delivers the second batch
+	events <- Event{Type: EventPathFailed, RoutePath: "/a"}
+	require.Eventually(t, received(1), time.Second, 5*time.Millisecond)
+	events <- Event{Type: EventPathFailed, RoutePath: "/b"}
+	require.Eventually(t, received(2), time.Second, 5*time.Millisecond)
+
+	mx.Lock()
+	defer mx.Unlock()
+	assert.Equal(t, "/b", payloads[1].Events[0].RoutePath)
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"webhook":"chat"}`)
+	header := http.Header{}
+	header.Set(WebhookTimestampHeader, "1700000000")
+	header.Set(WebhookSignatureHeader, "sha256="+signWebhook([]byte("a-secret"), "1700000000", body))
+
+	assert.EqualError(t, VerifyWebhookSignature("a-secret", header, body, time.Minute), "timestamp too old")
+	assert.NoError(t, VerifyWebhookSignature("a-secret", header, body, time.Since(time.Unix(1700000000, 0))+time.Minute))
+	assert.EqualError(t, VerifyWebhookSignature("other", header, body, time.Since(time.Unix(1700000000, 0))+time.Minute), "invalid signature")
+}
+
+func TestParseWebhookEvents(t *testing.T) {
+	events, err := ParseWebhookEvents("jobFinished, upstreamUnreachable")
+	require.NoError(t, err)
+	assert.Equal(t, []EventType{EventJobFinished, EventUpstreamUnreachable}, events)
+
+	_, err = ParseWebhookEvents("jobFinished,unknown")
+	assert.Error(t, err)
+}
+
+type toggleHealthChecker struct {
+	err error
+}
+
+func (c *toggleHealthChecker) CheckHealth(_ context.Context) error {
+	return c.err
+}
+
+func Test_upstreamMonitor(t *testing.T) {
+	checker := &toggleHealthChecker{}
+	events := newEventBroker()
+	ch, unsubscribe := events.subscribe(10)
+	defer unsubscribe()
+
+	m := &upstreamMonitor{
+		checkers:    []namedHealthChecker{{name: "source", checker: checker}},
+		events:      events,
+		unreachable: make(map[string]bool),
+	}
+
+	m.check(context.Background())
+	checker.err = errors.New("connection refused")
+	m.check(context.Background())
+	m.check(context.Background())
+	checker.err = nil
+	m.check(context.Background())
+	unsubscribe()
+
+	var received []Event
+	for event := range ch {
+		received = append(received, event)
+	}
+	require.Len(t, received, 2)
+	assert.Equal(t, EventUpstreamUnreachable, received[0].Type)
+	assert.Equal(t, "source", received[0].Upstream)
+	assert.Equal(t, "connection refused", received[0].Error)
+	assert.Equal(t, EventUpstreamRecovered, received[1].Type)
+}
+
+// healthCheckedDocuments counts health checks of a static document source.
+type healthCheckedDocuments struct {
+	staticDocuments
+	checks atomic.Int32
+}
+
+func (d *healthCheckedDocuments) CheckHealth(_ context.Context) error {
+	d.checks.Add(1)
+	return nil
+}
+
+func TestHandler_statusReportsMonitoredUpstreams(t *testing.T) {
+	source := &healthCheckedDocuments{staticDocuments: staticDocuments{{RoutePath: "/"}}}
+	h := NewHandler(HandlerOpts{
+		DocumentSource: source,
+	})
+	defer h.ShutdownAndWait()
+
+	// Upstreams are not probed by the status
+	assert.Empty(t, h.Status(context.Background()).Upstreams)
+	assert.Equal(t, int32(0), source.checks.Load())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.MonitorUpstreams(ctx, time.Hour)
+	require.Eventually(t, func() bool {
+		return len(h.Status(context.Background()).Upstreams) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	upstreams := h.Status(context.Background()).Upstreams
+	assert.Equal(t, "source", upstreams[0].Name)
+	assert.True(t, upstreams[0].Reachable)
+	assert.Equal(t, int32(1), source.checks.Load())
+}